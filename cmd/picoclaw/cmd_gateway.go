@@ -21,6 +21,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/devices"
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
+	"github.com/sipeed/picoclaw/pkg/httpapi"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/state"
@@ -197,6 +198,14 @@ func gatewayCmd() {
 	}
 
 	healthServer := health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
+	if cfg.Gateway.API.Enabled {
+		if len(cfg.Gateway.API.Tokens) == 0 {
+			fmt.Println("⚠ Warning: gateway API enabled without tokens; all API requests will be rejected")
+		}
+		healthServer.Handle("/v1/", httpapi.NewHandler(agentLoop, cfg.Gateway.API.Tokens))
+		fmt.Printf("✓ OpenAI-compatible API available at http://%s:%d/v1/chat/completions\n",
+			cfg.Gateway.Host, cfg.Gateway.Port)
+	}
 	go func() {
 		if err := healthServer.Start(); err != nil && err != http.ErrServerClosed {
 			logger.ErrorCF("health", "Health server error", map[string]any{"error": err.Error()})
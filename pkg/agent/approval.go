@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// setupApproval puts every agent's tools behind the configured approval
+// policy. Prompts go to the chat the turn came from.
+func (al *AgentLoop) setupApproval() {
+	policy, err := tools.NewApprovalPolicy(al.cfg.Tools.Approval)
+	if err != nil {
+		// LoadConfig validates the rules, so this only happens with a
+		// hand-built config. Refuse rather than run tools unchecked.
+		logger.ErrorCF("agent", "Invalid tool approval rules; all tool calls will be refused",
+			map[string]any{"error": err.Error()})
+		policy, _ = tools.NewApprovalPolicy(config.ApprovalConfig{
+			Rules: []config.ApprovalRule{{Tool: "*", Action: tools.ApprovalDeny}},
+		})
+	}
+	policy.SetRequester(al.requestApproval)
+
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			agent.Tools.SetApprovalPolicy(policy)
+		}
+	}
+	al.approval = policy
+}
+
+// requestApproval sends the prompt of req with buttons when the channel
+// supports them, and as a plain message explaining the reply otherwise.
+func (al *AgentLoop) requestApproval(ctx context.Context, req tools.ApprovalRequest) error {
+	prompt := req.Prompt()
+	if al.channelManager != nil {
+		if channel, ok := al.channelManager.GetChannel(req.Channel); ok {
+			if prompter, ok := channel.(channels.ApprovalPrompter); ok {
+				return prompter.SendApprovalPrompt(ctx, req.ChatID, req.ID, prompt)
+			}
+		}
+	}
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: req.Channel,
+		ChatID:  req.ChatID,
+		Content: fmt.Sprintf("%s\nReply /approve %s or /reject %s.", prompt, req.ID, req.ID),
+	})
+	return nil
+}
+
+// handleApprovalReply resolves "/approve [id]" and "/reject [id]" messages
+// and reports whether msg was one. Only requests raised in the same chat
+// can be answered; without an ID, the chat's only pending request is.
+func (al *AgentLoop) handleApprovalReply(msg bus.InboundMessage) bool {
+	if al.approval == nil {
+		return false
+	}
+	fields := strings.Fields(msg.Content)
+	if len(fields) == 0 || len(fields) > 2 {
+		return false
+	}
+	// Telegram appends the bot's name to commands sent in groups.
+	cmd, _, _ := strings.Cut(fields[0], "@")
+	var approved bool
+	switch cmd {
+	case "/approve":
+		approved = true
+	case "/reject":
+	default:
+		return false
+	}
+	var id string
+	if len(fields) == 2 {
+		id = fields[1]
+	}
+
+	var reply string
+	req, err := al.approval.Resolve(msg.Channel, msg.ChatID, id, approved)
+	switch {
+	case err != nil:
+		reply = fmt.Sprintf("Cannot %s: %v", strings.TrimPrefix(cmd, "/"), err)
+	case approved:
+		reply = fmt.Sprintf("Approved %s [%s].", req.Tool, req.ID)
+	default:
+		reply = fmt.Sprintf("Rejected %s [%s].", req.Tool, req.ID)
+	}
+	if err == nil {
+		logger.InfoCF("agent", "Tool call approval answered", map[string]any{
+			"id":       req.ID,
+			"tool":     req.Tool,
+			"approved": approved,
+			"by":       msg.SenderID,
+		})
+	}
+
+	al.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: msg.Channel,
+		ChatID:  msg.ChatID,
+		Content: reply,
+	})
+	return true
+}
@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestRun_ApprovalReplyResumesPausedTurn(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "agent-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Tools: config.ToolsConfig{
+			Approval: config.ApprovalConfig{
+				Enabled: true,
+				Rules:   []config.ApprovalRule{{Tool: "mock_exec_ctx", Action: "ask"}},
+			},
+		},
+	}
+
+	msgBus := bus.NewMessageBus()
+	provider := &toolCallMockProvider{toolName: "mock_exec_ctx", finalResponse: "done"}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	ctxTool := &mockExecCtxTool{}
+	al.RegisterTool(ctxTool)
+
+	ctx, cancel := context.WithTimeout(context.Background(), responseTimeout)
+	defer cancel()
+	go al.Run(ctx)
+
+	msgBus.PublishInbound(bus.InboundMessage{Channel: "telegram", SenderID: "user-1", ChatID: "chat-1", Content: "hello"})
+
+	prompt, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("Expected an approval prompt")
+	}
+	match := regexp.MustCompile(`/approve (\w+)`).FindStringSubmatch(prompt.Content)
+	if prompt.ChatID != "chat-1" || match == nil {
+		t.Fatalf("Unexpected approval prompt: %+v", prompt)
+	}
+
+	// The reply reaches the loop while the turn of the same session is paused.
+	msgBus.PublishInbound(bus.InboundMessage{Channel: "telegram", SenderID: "user-2", ChatID: "chat-1", Content: "/approve " + match[1]})
+
+	replies := map[string]bool{}
+	for len(replies) < 2 {
+		msg, ok := msgBus.SubscribeOutbound(ctx)
+		if !ok {
+			t.Fatalf("Expected a confirmation and the final reply, got %v", replies)
+		}
+		replies[msg.Content] = true
+	}
+	if !replies["done"] || !replies["Approved mock_exec_ctx ["+match[1]+"]."] {
+		t.Errorf("Unexpected replies: %v", replies)
+	}
+	if ctxTool.execCtx.ChatID != "chat-1" {
+		t.Error("Expected the approved tool to run")
+	}
+}
@@ -0,0 +1,266 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Defaults for config.CompressionConfig fields left unset.
+const (
+	defaultChunkSizeTokens  = 1200
+	defaultContinuityBuffer = 4
+	defaultMinChunkMessages = 4
+	defaultColdStorageDir   = "memory/chunks"
+	defaultSummaryMaxTokens = 4096
+)
+
+// chunkIndexFile lists every archived chunk of a cold storage directory.
+const chunkIndexFile = "index.json"
+
+// compressionSettings returns cfg with defaults filled in for unset fields.
+func compressionSettings(cfg config.CompressionConfig) config.CompressionConfig {
+	if cfg.ChunkSizeTokens <= 0 {
+		cfg.ChunkSizeTokens = defaultChunkSizeTokens
+	}
+	if cfg.ContinuityBuffer <= 0 {
+		cfg.ContinuityBuffer = defaultContinuityBuffer
+	}
+	if cfg.MinChunkMessages <= 0 {
+		cfg.MinChunkMessages = defaultMinChunkMessages
+	}
+	if strings.TrimSpace(cfg.ColdStorageDir) == "" {
+		cfg.ColdStorageDir = defaultColdStorageDir
+	}
+	if cfg.SummaryMaxTokens <= 0 {
+		cfg.SummaryMaxTokens = defaultSummaryMaxTokens
+	}
+	return cfg
+}
+
+// ArchivedChunk is a slice of conversation history moved out of the session
+// into cold storage, together with its summary.
+type ArchivedChunk struct {
+	ID            string              `json:"id"`
+	AgentID       string              `json:"agent_id"`
+	SessionKey    string              `json:"session_key"`
+	Archived      time.Time           `json:"archived"`
+	TokenEstimate int                 `json:"token_estimate"`
+	Summary       string              `json:"summary"`
+	Messages      []providers.Message `json:"messages"`
+}
+
+// ChunkIndexEntry describes one archived chunk in the index.
+type ChunkIndexEntry struct {
+	ID            string    `json:"id"`
+	AgentID       string    `json:"agent_id"`
+	SessionKey    string    `json:"session_key"`
+	Archived      time.Time `json:"archived"`
+	MessageCount  int       `json:"message_count"`
+	TokenEstimate int       `json:"token_estimate"`
+	Summary       string    `json:"summary"`
+	JSONFile      string    `json:"json_file"`
+	MarkdownFile  string    `json:"markdown_file"`
+}
+
+// ChunkArchive stores archived conversation chunks on disk. Each chunk is
+// written as <id>.json (raw messages) and <id>.md (readable transcript), and
+// listed in index.json.
+type ChunkArchive struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewChunkArchive creates an archive rooted at dir. Relative directories are
+// resolved against the agent workspace.
+func NewChunkArchive(workspace, dir string) *ChunkArchive {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workspace, dir)
+	}
+	return &ChunkArchive{dir: dir}
+}
+
+// Dir returns the cold storage directory.
+func (a *ChunkArchive) Dir() string {
+	return a.dir
+}
+
+// Save writes the chunk files and adds the chunk to the index.
+func (a *ChunkArchive) Save(chunk ArchivedChunk) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		return fmt.Errorf("creating cold storage dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(chunk, "", "  ")
+	if err != nil {
+		return err
+	}
+	jsonFile := chunk.ID + ".json"
+	if err := writeFileAtomic(filepath.Join(a.dir, jsonFile), data); err != nil {
+		return err
+	}
+	mdFile := chunk.ID + ".md"
+	if err := writeFileAtomic(filepath.Join(a.dir, mdFile), []byte(formatChunkMarkdown(chunk))); err != nil {
+		return err
+	}
+
+	index, err := a.readIndex()
+	if err != nil {
+		return err
+	}
+	index = append(index, ChunkIndexEntry{
+		ID:            chunk.ID,
+		AgentID:       chunk.AgentID,
+		SessionKey:    chunk.SessionKey,
+		Archived:      chunk.Archived,
+		MessageCount:  len(chunk.Messages),
+		TokenEstimate: chunk.TokenEstimate,
+		Summary:       chunk.Summary,
+		JSONFile:      jsonFile,
+		MarkdownFile:  mdFile,
+	})
+	data, err = json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(a.dir, chunkIndexFile), data)
+}
+
+// Index returns all archived chunks in the order they were archived.
+func (a *ChunkArchive) Index() ([]ChunkIndexEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.readIndex()
+}
+
+// Load reads an archived chunk by ID.
+func (a *ChunkArchive) Load(id string) (*ArchivedChunk, error) {
+	if id == "" || !filepath.IsLocal(id) || strings.ContainsAny(id, `/\`) {
+		return nil, os.ErrInvalid
+	}
+	data, err := os.ReadFile(filepath.Join(a.dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var chunk ArchivedChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil, fmt.Errorf("parsing chunk %s: %w", id, err)
+	}
+	return &chunk, nil
+}
+
+func (a *ChunkArchive) readIndex() ([]ChunkIndexEntry, error) {
+	data, err := os.ReadFile(filepath.Join(a.dir, chunkIndexFile))
+	if os.IsNotExist(err) {
+		return []ChunkIndexEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index []ChunkIndexEntry
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parsing chunk index: %w", err)
+	}
+	return index, nil
+}
+
+// newChunkID builds a sortable, filesystem-safe chunk ID.
+func newChunkID(now time.Time, sessionKey string, seq int) string {
+	return fmt.Sprintf("%s-%s-%03d", now.UTC().Format("20060102-150405.000"), safeFileName(sessionKey), seq)
+}
+
+func formatChunkMarkdown(chunk ArchivedChunk) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Conversation archive %s\n\n", chunk.ID)
+	fmt.Fprintf(&sb, "- Agent: %s\n", chunk.AgentID)
+	fmt.Fprintf(&sb, "- Session: %s\n", chunk.SessionKey)
+	fmt.Fprintf(&sb, "- Archived: %s\n", chunk.Archived.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "- Messages: %d\n\n", len(chunk.Messages))
+
+	if chunk.Summary != "" {
+		sb.WriteString("## Summary\n\n")
+		sb.WriteString(chunk.Summary)
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString("## Transcript\n\n")
+	for _, m := range chunk.Messages {
+		switch {
+		case m.Role == "tool":
+			fmt.Fprintf(&sb, "**tool (%s):** %s\n\n", m.ToolCallID, m.Content)
+		case len(m.ToolCalls) > 0:
+			names := make([]string, 0, len(m.ToolCalls))
+			for _, tc := range m.ToolCalls {
+				names = append(names, tc.Name)
+			}
+			fmt.Fprintf(&sb, "**%s:** %s\n_calls: %s_\n\n", m.Role, m.Content, strings.Join(names, ", "))
+		default:
+			fmt.Fprintf(&sb, "**%s:** %s\n\n", m.Role, m.Content)
+		}
+	}
+	return sb.String()
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".chunk-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0o644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// chunkHistory splits messages into chunks of roughly maxTokens each. A chunk
+// is only closed once it holds at least minMessages messages, and new chunks
+// always start at a user message so tool calls stay with their results. A
+// short remainder is folded into the previous chunk.
+func chunkHistory(messages []providers.Message, maxTokens, minMessages int) [][]providers.Message {
+	var chunks [][]providers.Message
+	start, tokens := 0, 0
+
+	for i, m := range messages {
+		if i > start && m.Role == "user" && tokens >= maxTokens && i-start >= minMessages {
+			chunks = append(chunks, messages[start:i])
+			start, tokens = i, 0
+		}
+		tokens += estimateMessageTokens(m)
+	}
+
+	if start < len(messages) {
+		rest := messages[start:]
+		if len(chunks) > 0 && len(rest) < minMessages {
+			last := len(chunks) - 1
+			chunks[last] = messages[start-len(chunks[last]):]
+		} else {
+			chunks = append(chunks, rest)
+		}
+	}
+	return chunks
+}
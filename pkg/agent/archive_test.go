@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func conversation(turns int, words int) []providers.Message {
+	text := strings.Repeat("word ", words)
+	var msgs []providers.Message
+	for i := 0; i < turns; i++ {
+		msgs = append(msgs,
+			providers.Message{Role: "user", Content: text},
+			providers.Message{Role: "assistant", Content: text},
+		)
+	}
+	return msgs
+}
+
+func TestChunkHistory_SplitsOnUserTurns(t *testing.T) {
+	msgs := conversation(6, 50) // 250 chars per message ≈ 100 tokens
+	msgs = append(msgs[:3], append([]providers.Message{
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "c1", Name: "exec"}}},
+		{Role: "tool", ToolCallID: "c1", Content: "ok"},
+	}, msgs[3:]...)...)
+
+	chunks := chunkHistory(msgs, 300, 2)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+
+	total := 0
+	for i, chunk := range chunks {
+		total += len(chunk)
+		if chunk[0].Role != "user" {
+			t.Errorf("chunk %d starts with %q, want user", i, chunk[0].Role)
+		}
+	}
+	if total != len(msgs) {
+		t.Errorf("chunks cover %d messages, want %d", total, len(msgs))
+	}
+}
+
+func TestChunkHistory_FoldsShortRemainder(t *testing.T) {
+	msgs := append(conversation(2, 200), providers.Message{Role: "user", Content: "tail"})
+
+	chunks := chunkHistory(msgs, 100, 2)
+	last := chunks[len(chunks)-1]
+	if len(last) < 2 {
+		t.Errorf("expected short remainder to be folded into the previous chunk, got %d-message chunk", len(last))
+	}
+	if last[len(last)-1].Content != "tail" {
+		t.Errorf("expected tail message in the last chunk")
+	}
+}
+
+func TestChunkArchive_SaveIndexLoad(t *testing.T) {
+	workspace := t.TempDir()
+	archive := NewChunkArchive(workspace, "memory/chunks")
+
+	chunk := ArchivedChunk{
+		ID:         newChunkID(time.Now(), "telegram:123", 0),
+		AgentID:    "main",
+		SessionKey: "telegram:123",
+		Archived:   time.Now(),
+		Summary:    "User asked about the weather.",
+		Messages:   conversation(1, 3),
+	}
+	if err := archive.Save(chunk); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	if strings.ContainsAny(chunk.ID, ":/") {
+		t.Errorf("chunk ID %q is not filesystem safe", chunk.ID)
+	}
+	md, err := os.ReadFile(filepath.Join(workspace, "memory", "chunks", chunk.ID+".md"))
+	if err != nil {
+		t.Fatalf("expected markdown file: %v", err)
+	}
+	if !strings.Contains(string(md), "User asked about the weather.") || !strings.Contains(string(md), "**user:**") {
+		t.Errorf("unexpected markdown:\n%s", md)
+	}
+
+	index, err := archive.Index()
+	if err != nil {
+		t.Fatalf("Index() error: %v", err)
+	}
+	if len(index) != 1 || index[0].ID != chunk.ID || index[0].MessageCount != 2 {
+		t.Fatalf("unexpected index: %+v", index)
+	}
+
+	loaded, err := archive.Load(chunk.ID)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if len(loaded.Messages) != 2 || loaded.Summary != chunk.Summary {
+		t.Errorf("unexpected loaded chunk: %+v", loaded)
+	}
+
+	if _, err := archive.Load("../secrets"); err == nil {
+		t.Error("expected Load to reject path traversal")
+	}
+}
+
+func TestSummarizeSession_ArchivesChunksAndUpdatesRollingSummary(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Compression: config.CompressionConfig{
+			ChunkSizeTokens:  200,
+			ContinuityBuffer: 2,
+			MinChunkMessages: 2,
+		},
+	}
+
+	provider := &simpleMockProvider{response: "summary text"}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+
+	sessionKey := "telegram:42"
+	history := conversation(6, 100)
+	agent.Sessions.GetOrCreate(sessionKey)
+	agent.Sessions.SetHistory(sessionKey, history)
+
+	al.summarizeSession(agent, sessionKey)
+
+	remaining := agent.Sessions.GetHistory(sessionKey)
+	if len(remaining) != 2 {
+		t.Errorf("expected continuity buffer of 2 messages, got %d", len(remaining))
+	}
+	if got := agent.Sessions.GetRollingSummary(sessionKey); got == "" {
+		t.Error("expected rolling summary to be set")
+	}
+
+	index, err := agent.Archive.Index()
+	if err != nil {
+		t.Fatalf("Index() error: %v", err)
+	}
+	if len(index) < 2 {
+		t.Fatalf("expected history to be archived in several chunks, got %d", len(index))
+	}
+	archived := 0
+	for _, entry := range index {
+		archived += entry.MessageCount
+		if entry.Summary != "summary text" {
+			t.Errorf("chunk %s summary = %q", entry.ID, entry.Summary)
+		}
+	}
+	if archived != len(history)-2 {
+		t.Errorf("archived %d messages, want %d", archived, len(history)-2)
+	}
+	if agent.Archive.Dir() != filepath.Join(tmpDir, "memory", "chunks") {
+		t.Errorf("archive dir = %s", agent.Archive.Dir())
+	}
+}
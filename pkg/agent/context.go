@@ -2,6 +2,7 @@ package agent
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -12,6 +13,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type ContextBuilder struct {
@@ -19,6 +21,7 @@ type ContextBuilder struct {
 	skillsLoader *skills.SkillsLoader
 	memory       *MemoryStore
 	tools        *tools.ToolRegistry // Direct reference to tool registry
+	memorySearch bool                // Memory is recalled with memory_search instead of inlined
 }
 
 func getGlobalConfigDir() string {
@@ -48,13 +51,19 @@ func (cb *ContextBuilder) SetToolsRegistry(registry *tools.ToolRegistry) {
 	cb.tools = registry
 }
 
-func (cb *ContextBuilder) getIdentity() string {
+// SetMemorySearch switches the prompt from inlining memory files to pointing
+// the agent at the memory_search and memory_save tools.
+func (cb *ContextBuilder) SetMemorySearch(enabled bool) {
+	cb.memorySearch = enabled
+}
+
+func (cb *ContextBuilder) getIdentity(registry *tools.ToolRegistry) string {
 	now := time.Now().Format("2006-01-02 15:04 (Monday)")
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
 	runtime := fmt.Sprintf("%s %s, Go %s", runtime.GOOS, runtime.GOARCH, runtime.Version())
 
 	// Build tools section dynamically
-	toolsSection := cb.buildToolsSection()
+	toolsSection := buildToolsSection(registry)
 
 	return fmt.Sprintf(`# picoclaw 🦞
 
@@ -80,16 +89,24 @@ Your workspace is at: %s
 
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
-3. **Memory** - When interacting with me if something seems memorable, update %s/memory/MEMORY.md`,
-		now, runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, workspacePath)
+3. **Memory** - %s`,
+		now, runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, cb.memoryRule(workspacePath))
 }
 
-func (cb *ContextBuilder) buildToolsSection() string {
-	if cb.tools == nil {
+func (cb *ContextBuilder) memoryRule(workspacePath string) string {
+	if cb.memorySearch {
+		return "When interacting with me if something seems memorable, save it with memory_save. " +
+			"Before answering questions about me or earlier conversations, recall with memory_search."
+	}
+	return fmt.Sprintf("When interacting with me if something seems memorable, update %s/memory/MEMORY.md", workspacePath)
+}
+
+func buildToolsSection(registry *tools.ToolRegistry) string {
+	if registry == nil {
 		return ""
 	}
 
-	summaries := cb.tools.GetSummaries()
+	summaries := registry.GetSummaries()
 	if len(summaries) == 0 {
 		return ""
 	}
@@ -109,10 +126,25 @@ func (cb *ContextBuilder) buildToolsSection() string {
 }
 
 func (cb *ContextBuilder) BuildSystemPrompt() string {
+	return cb.buildSystemPrompt(cb.tools)
+}
+
+// BuildSubagentPrompt builds the system prompt for this agent running as a
+// subagent with the given tools. Its final answer goes back to the agent that
+// spawned it rather than to the user.
+func (cb *ContextBuilder) BuildSubagentPrompt(registry *tools.ToolRegistry) string {
+	return cb.buildSystemPrompt(registry) + "\n\n---\n\n" + `# Subagent
+
+You are running as a subagent. Another agent gave you the task in the next message.
+Complete it independently using your tools, then reply with a clear summary of what you did and found.
+Your reply is returned to that agent, not shown to the user.`
+}
+
+func (cb *ContextBuilder) buildSystemPrompt(registry *tools.ToolRegistry) string {
 	parts := []string{}
 
 	// Core identity section
-	parts = append(parts, cb.getIdentity())
+	parts = append(parts, cb.getIdentity(registry))
 
 	// Bootstrap files
 	bootstrapContent := cb.LoadBootstrapFiles()
@@ -131,8 +163,11 @@ The following skills extend your capabilities. To use a skill, read its SKILL.md
 	}
 
 	// Memory context
-	memoryContext := cb.memory.GetMemoryContext()
-	if memoryContext != "" {
+	if cb.memorySearch {
+		parts = append(parts, "# Memory\n\n"+
+			"Long-term memory, daily notes and archived conversations are not loaded into this prompt. "+
+			"Use memory_search to recall relevant snippets when you need them.")
+	} else if memoryContext := cb.memory.GetMemoryContext(); memoryContext != "" {
 		parts = append(parts, "# Memory\n\n"+memoryContext)
 	}
 
@@ -207,15 +242,56 @@ func (cb *ContextBuilder) BuildMessages(
 	messages = append(messages, history...)
 
 	if strings.TrimSpace(currentMessage) != "" {
-		messages = append(messages, providers.Message{
+		msg := providers.Message{
 			Role:    "user",
 			Content: currentMessage,
-		})
+		}
+		if images := imageParts(media); len(images) > 0 {
+			msg.Parts = append([]providers.ContentPart{
+				{Type: providers.ContentPartText, Text: currentMessage},
+			}, images...)
+		}
+		messages = append(messages, msg)
 	}
 
 	return messages
 }
 
+// imageParts turns the image entries of an inbound message's media (local
+// paths or URLs) into content parts. Other media such as audio is skipped;
+// channels already describe it in the message text.
+func imageParts(media []string) []providers.ContentPart {
+	var parts []providers.ContentPart
+	for _, item := range media {
+		var source *providers.ImageSource
+		if strings.HasPrefix(item, "http://") || strings.HasPrefix(item, "https://") {
+			if utils.IsImageFile(item, "") {
+				source = &providers.ImageSource{URL: item}
+			}
+		} else if utils.IsImageFile(item, sniffContentType(item)) {
+			source = &providers.ImageSource{Path: item}
+		}
+		if source != nil {
+			parts = append(parts, providers.ContentPart{Type: providers.ContentPartImage, Image: source})
+		}
+	}
+	return parts
+}
+
+// sniffContentType detects the content type of a local file from its first bytes,
+// for downloads whose names carry no useful extension.
+func sniffContentType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	return http.DetectContentType(buf[:n])
+}
+
 func sanitizeHistoryForProvider(history []providers.Message) []providers.Message {
 	if len(history) == 0 {
 		return history
@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultMaxConcurrentSessions is used when agents.defaults.max_concurrent_sessions is unset.
+const defaultMaxConcurrentSessions = 4
+
+// sessionDispatcher runs inbound messages on a bounded pool of workers.
+// Messages sharing a session key are handled strictly in arrival order, one at
+// a time, while different sessions run in parallel. Sessions with pending work
+// are served round-robin (one message per turn) so a busy chat cannot starve
+// the others.
+type sessionDispatcher struct {
+	handle  func(ctx context.Context, msg routedMessage)
+	workers int
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	pending   map[string][]routedMessage // queued messages per session key
+	scheduled map[string]bool            // session is in ready or being processed
+	ready     []string                   // sessions waiting for a free worker
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func newSessionDispatcher(workers int, handle func(ctx context.Context, msg routedMessage)) *sessionDispatcher {
+	if workers <= 0 {
+		workers = defaultMaxConcurrentSessions
+	}
+	d := &sessionDispatcher{
+		handle:    handle,
+		workers:   workers,
+		pending:   make(map[string][]routedMessage),
+		scheduled: make(map[string]bool),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+// Start launches the worker goroutines. Workers exit once Close is called.
+func (d *sessionDispatcher) Start(ctx context.Context) {
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.worker(ctx)
+	}
+}
+
+// Dispatch queues msg behind any earlier messages of the same session.
+func (d *sessionDispatcher) Dispatch(sessionKey string, msg routedMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+
+	d.pending[sessionKey] = append(d.pending[sessionKey], msg)
+	if !d.scheduled[sessionKey] {
+		d.scheduled[sessionKey] = true
+		d.ready = append(d.ready, sessionKey)
+		d.cond.Signal()
+	}
+}
+
+// Close stops accepting messages, drops anything still queued and waits for
+// in-flight messages to finish.
+func (d *sessionDispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.pending = make(map[string][]routedMessage)
+	d.ready = nil
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+func (d *sessionDispatcher) worker(ctx context.Context) {
+	defer d.wg.Done()
+	for {
+		sessionKey, msg, ok := d.next()
+		if !ok {
+			return
+		}
+		d.handle(ctx, msg)
+		d.finish(sessionKey)
+	}
+}
+
+// next blocks until a session has work, then pops its oldest message.
+func (d *sessionDispatcher) next() (string, routedMessage, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for len(d.ready) == 0 && !d.closed {
+		d.cond.Wait()
+	}
+	if d.closed {
+		return "", routedMessage{}, false
+	}
+
+	sessionKey := d.ready[0]
+	d.ready = d.ready[1:]
+
+	queue := d.pending[sessionKey]
+	msg := queue[0]
+	d.pending[sessionKey] = queue[1:]
+	return sessionKey, msg, true
+}
+
+// finish releases the session and re-queues it at the back of the ready list
+// if more messages arrived while it was being processed.
+func (d *sessionDispatcher) finish(sessionKey string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.pending[sessionKey]) > 0 && !d.closed {
+		d.ready = append(d.ready, sessionKey)
+		d.cond.Signal()
+		return
+	}
+	delete(d.pending, sessionKey)
+	delete(d.scheduled, sessionKey)
+}
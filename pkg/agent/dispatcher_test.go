@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestSessionDispatcher_SerializesSameSession(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		order    []string
+		inFlight atomic.Int32
+		overlap  atomic.Bool
+		done     sync.WaitGroup
+	)
+
+	d := newSessionDispatcher(4, func(ctx context.Context, msg routedMessage) {
+		defer done.Done()
+		if inFlight.Add(1) > 1 {
+			overlap.Store(true)
+		}
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		order = append(order, msg.Content)
+		mu.Unlock()
+		inFlight.Add(-1)
+	})
+	d.Start(context.Background())
+	defer d.Close()
+
+	want := []string{"1", "2", "3", "4", "5"}
+	done.Add(len(want))
+	for _, content := range want {
+		d.Dispatch("session-a", routedMessage{InboundMessage: bus.InboundMessage{Content: content}})
+	}
+	done.Wait()
+
+	if overlap.Load() {
+		t.Error("messages of the same session were processed concurrently")
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestSessionDispatcher_RunsSessionsInParallel(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan string, 2)
+	var done sync.WaitGroup
+
+	d := newSessionDispatcher(2, func(ctx context.Context, msg routedMessage) {
+		defer done.Done()
+		started <- msg.ChatID
+		<-release
+	})
+	d.Start(context.Background())
+	defer d.Close()
+
+	done.Add(2)
+	d.Dispatch("session-a", routedMessage{InboundMessage: bus.InboundMessage{ChatID: "a"}})
+	d.Dispatch("session-b", routedMessage{InboundMessage: bus.InboundMessage{ChatID: "b"}})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("second session did not start while the first was blocked")
+		}
+	}
+	close(release)
+	done.Wait()
+}
+
+func TestSessionDispatcher_FairAcrossSessions(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+		done  sync.WaitGroup
+	)
+	gate := make(chan struct{})
+
+	d := newSessionDispatcher(1, func(ctx context.Context, msg routedMessage) {
+		defer done.Done()
+		<-gate
+		mu.Lock()
+		order = append(order, msg.ChatID)
+		mu.Unlock()
+	})
+
+	done.Add(4)
+	d.Dispatch("busy", routedMessage{InboundMessage: bus.InboundMessage{ChatID: "busy"}})
+	d.Dispatch("busy", routedMessage{InboundMessage: bus.InboundMessage{ChatID: "busy"}})
+	d.Dispatch("busy", routedMessage{InboundMessage: bus.InboundMessage{ChatID: "busy"}})
+	d.Dispatch("quiet", routedMessage{InboundMessage: bus.InboundMessage{ChatID: "quiet"}})
+
+	d.Start(context.Background())
+	defer d.Close()
+	close(gate)
+	done.Wait()
+
+	// With a single worker, the quiet session must be served right after the
+	// busy session's first message instead of waiting behind its whole backlog.
+	if order[1] != "quiet" {
+		t.Errorf("order = %v, want quiet session served second", order)
+	}
+}
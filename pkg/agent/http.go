@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// httpChannel is the channel name of turns arriving through the HTTP API.
+const httpChannel = "http"
+
+// httpCommands are the commands API clients may run. The others change state
+// shared by every chat, such as the default agent's model, so over the API
+// they are passed to the agent as ordinary text.
+var httpCommands = map[string]bool{
+	"/show":  true,
+	"/list":  true,
+	"/tasks": true,
+}
+
+// ProcessHTTP runs one turn for the OpenAI-compatible HTTP API and returns
+// the reply. agentID selects the agent; when it is empty or unknown the
+// message is routed like any other "http" message. sessionID names the
+// conversation, which keeps its own history, and turns of the same
+// conversation run one at a time. When onDelta is set, it receives the
+// answer, in the chunks the model streamed it in, as soon as the LLM call
+// that gave it returns and before the turn is wrapped up.
+func (al *AgentLoop) ProcessHTTP(
+	ctx context.Context,
+	agentID, sessionID, content string,
+	onDelta func(text string),
+) (string, error) {
+	msg := bus.InboundMessage{
+		Channel:  httpChannel,
+		SenderID: sessionID,
+		ChatID:   sessionID,
+		Content:  content,
+		Metadata: map[string]string{"peer_kind": "direct", "peer_id": sessionID},
+	}
+
+	agent, ok := al.registry.GetAgent(agentID)
+	if agentID == "" || !ok {
+		agent, _, _ = al.resolveRoute(msg)
+	}
+	// Each API conversation is its own session, whatever the DM scope.
+	sessionKey := routing.BuildAgentPeerSessionKey(routing.SessionKeyParams{
+		AgentID: agent.ID,
+		Channel: httpChannel,
+		Peer:    &routing.RoutePeer{Kind: "direct", ID: sessionID},
+		DMScope: routing.DMScopePerChannelPeer,
+	})
+
+	unlock := al.httpSessions.lock(sessionKey)
+	defer unlock()
+
+	if fields := strings.Fields(content); len(fields) > 0 && httpCommands[fields[0]] {
+		if response, handled := al.handleCommand(ctx, msg); handled {
+			return response, nil
+		}
+	}
+
+	ctx, _ = tools.WithSentTracker(ctx)
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         msg.Channel,
+		ChatID:          msg.ChatID,
+		SenderID:        msg.SenderID,
+		Metadata:        msg.Metadata,
+		UserMessage:     content,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		EnableSummary:   true,
+		SendResponse:    false,
+		OnDelta:         onDelta,
+		RouteModel:      true,
+	})
+}
+
+// ListAgentIDs returns the IDs of all configured agents.
+func (al *AgentLoop) ListAgentIDs() []string {
+	return al.registry.ListAgentIDs()
+}
+
+// keyedMutex serializes work per key. Entries are dropped once unused.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock blocks until key is free and returns the function that releases it.
+func (k *keyedMutex) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyedLock)
+	}
+	l := k.locks[key]
+	if l == nil {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		k.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
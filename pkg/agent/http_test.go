@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestProcessHTTP_StreamsAndKeepsSessionsApart(t *testing.T) {
+	provider := &streamingMockProvider{response: "one two three"}
+	al, _ := newStreamingTestLoop(t, false, provider)
+
+	var deltas []string
+	reply, err := al.ProcessHTTP(context.Background(), "", "alice", "count", func(text string) {
+		deltas = append(deltas, text)
+	})
+	if err != nil {
+		t.Fatalf("ProcessHTTP() error: %v", err)
+	}
+	if reply != "one two three" || strings.Join(deltas, "") != reply {
+		t.Errorf("reply = %q, deltas = %q", reply, deltas)
+	}
+	if len(deltas) != 3 {
+		t.Errorf("expected the answer in the chunks the model streamed, got %q", deltas)
+	}
+
+	if _, err := al.ProcessHTTP(context.Background(), "main", "bob", "hello", nil); err != nil {
+		t.Fatalf("ProcessHTTP() error: %v", err)
+	}
+
+	agent := al.registry.GetDefaultAgent()
+	for session, want := range map[string]string{"alice": "count", "bob": "hello"} {
+		history := agent.Sessions.GetHistory("agent:main:http:direct:" + session)
+		if len(history) != 2 || history[0].Content != want {
+			t.Errorf("session %s history = %+v", session, history)
+		}
+	}
+}
+
+// chattyToolProvider says something before a tool call, then answers
+type chattyToolProvider struct {
+	calls int
+}
+
+func (m *chattyToolProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.calls++
+	if m.calls == 1 {
+		return &providers.LLMResponse{
+			Content: "Let me look. ",
+			ToolCalls: []providers.ToolCall{{
+				ID:        "call-1",
+				Type:      "function",
+				Name:      "list_dir",
+				Arguments: map[string]any{"path": "."},
+			}},
+		}, nil
+	}
+	return &providers.LLMResponse{Content: "Found it."}, nil
+}
+
+func (m *chattyToolProvider) ChatStream(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+	onDelta func(providers.StreamDelta),
+) (*providers.LLMResponse, error) {
+	resp, err := m.Chat(ctx, messages, tools, model, opts)
+	onDelta(providers.StreamDelta{Content: resp.Content})
+	return resp, err
+}
+
+func (m *chattyToolProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestProcessHTTP_DeltasCarryOnlyTheAnswer(t *testing.T) {
+	al, _ := newStreamingTestLoop(t, false, &chattyToolProvider{})
+
+	var deltas []string
+	reply, err := al.ProcessHTTP(context.Background(), "", "alice", "find it", func(text string) {
+		deltas = append(deltas, text)
+	})
+	if err != nil {
+		t.Fatalf("ProcessHTTP() error: %v", err)
+	}
+	if reply != "Found it." || strings.Join(deltas, "") != reply {
+		t.Errorf("reply = %q, deltas = %q", reply, deltas)
+	}
+}
+
+func TestProcessHTTP_CannotSwitchModel(t *testing.T) {
+	provider := &streamingMockProvider{response: "ok"}
+	al, _ := newStreamingTestLoop(t, false, provider)
+
+	if _, err := al.ProcessHTTP(context.Background(), "", "alice", "/switch model to other", nil); err != nil {
+		t.Fatalf("ProcessHTTP() error: %v", err)
+	}
+	if got := al.registry.GetDefaultAgent().CurrentModel(); got != "test-model" {
+		t.Errorf("model = %q, want test-model unchanged", got)
+	}
+
+	reply, err := al.ProcessHTTP(context.Background(), "", "alice", "/show model", nil)
+	if err != nil || reply != "Current model: test-model" {
+		t.Errorf("/show model = %q, %v", reply, err)
+	}
+}
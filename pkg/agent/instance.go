@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
@@ -15,22 +16,47 @@ import (
 // AgentInstance represents a fully configured agent with its own workspace,
 // session manager, context builder, and tool registry.
 type AgentInstance struct {
-	ID             string
-	Name           string
-	Model          string
-	Fallbacks      []string
-	Workspace      string
-	MaxIterations  int
-	MaxTokens      int
-	Temperature    float64
-	ContextWindow  int
-	Provider       providers.LLMProvider
-	Sessions       *session.SessionManager
-	ContextBuilder *ContextBuilder
-	Tools          *tools.ToolRegistry
-	Subagents      *config.SubagentsConfig
-	SkillsFilter   []string
-	Candidates     []providers.FallbackCandidate
+	ID              string
+	Name            string
+	Model           string
+	Fallbacks       []string
+	Workspace       string
+	MaxIterations   int
+	MaxTokens       int
+	Temperature     float64
+	ContextWindow   int
+	Provider        providers.LLMProvider
+	Sessions        *session.SessionManager
+	ContextBuilder  *ContextBuilder
+	Tools           *tools.ToolRegistry
+	Subagents       *config.SubagentsConfig
+	SkillsFilter    []string
+	MCPServers      []string // MCP servers whose tools this agent gets; nil for all
+	Candidates      []providers.FallbackCandidate
+	ImageCandidates []providers.FallbackCandidate
+	Archive         *ChunkArchive
+	Processes       *tools.ProcessManager
+	SubagentManager *tools.SubagentManager // background subagents this agent spawned
+	Router          *ModelRouter           // nil unless model routing is enabled
+
+	modelMu sync.RWMutex // guards Model once turns are running
+}
+
+// CurrentModel returns the agent's model. Use it instead of reading Model
+// directly while turns may be running, since /switch can change it.
+func (a *AgentInstance) CurrentModel() string {
+	a.modelMu.RLock()
+	defer a.modelMu.RUnlock()
+	return a.Model
+}
+
+// SetModel replaces the agent's model and returns the previous one.
+func (a *AgentInstance) SetModel(model string) string {
+	a.modelMu.Lock()
+	defer a.modelMu.Unlock()
+	old := a.Model
+	a.Model = model
+	return old
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -48,11 +74,25 @@ func NewAgentInstance(
 
 	restrict := defaults.RestrictToWorkspace
 	toolsRegistry := tools.NewToolRegistry()
+	toolsRegistry.SetOutputLimit(tools.OutputLimit{
+		MaxChars: cfg.Tools.Output.MaxChars,
+		Dir:      filepath.Join(workspace, "tool-output"),
+	})
 	toolsRegistry.Register(tools.NewReadFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewWriteFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewListDirTool(workspace, restrict))
-	toolsRegistry.Register(tools.NewExecToolWithConfig(workspace, restrict, cfg))
+	toolsRegistry.Register(tools.NewGrepTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewGlobTool(workspace, restrict))
+	processes := tools.NewProcessManager()
+	execTool := tools.NewExecToolWithConfig(workspace, restrict, cfg)
+	execTool.SetProcessManager(processes)
+	// Long output is saved to tool-output/ by the registry, so exec only
+	// needs a cap that keeps a runaway command from exhausting memory.
+	execTool.SetMaxOutputChars(1 << 20)
+	toolsRegistry.Register(execTool)
+	toolsRegistry.Register(tools.NewProcessTool(processes))
 	toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict))
+	toolsRegistry.Register(tools.NewApplyPatchTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict))
 
 	sessionsDir := filepath.Join(workspace, "sessions")
@@ -61,16 +101,26 @@ func NewAgentInstance(
 	contextBuilder := NewContextBuilder(workspace)
 	contextBuilder.SetToolsRegistry(toolsRegistry)
 
+	archive := NewChunkArchive(workspace, compressionSettings(cfg.Compression).ColdStorageDir)
+	if cfg.Tools.Memory.Enabled {
+		index := newMemoryIndex(cfg, workspace, archive)
+		toolsRegistry.Register(tools.NewMemorySearchTool(index, cfg.Tools.Memory.MaxResults))
+		toolsRegistry.Register(tools.NewMemorySaveTool(contextBuilder.memory))
+		contextBuilder.SetMemorySearch(true)
+	}
+
 	agentID := routing.DefaultAgentID
 	agentName := ""
 	var subagents *config.SubagentsConfig
 	var skillsFilter []string
+	var mcpServers []string
 
 	if agentCfg != nil {
 		agentID = routing.NormalizeAgentID(agentCfg.ID)
 		agentName = agentCfg.Name
 		subagents = agentCfg.Subagents
 		skillsFilter = agentCfg.Skills
+		mcpServers = agentCfg.MCPServers
 	}
 
 	maxIter := defaults.MaxToolIterations
@@ -93,25 +143,38 @@ func NewAgentInstance(
 		Primary:   model,
 		Fallbacks: fallbacks,
 	}
-	candidates := providers.ResolveCandidates(modelCfg, defaults.Provider)
+	candidates := providers.ResolveModelListCandidates(modelCfg, defaults.Provider, cfg.ModelList)
+
+	var imageCandidates []providers.FallbackCandidate
+	if strings.TrimSpace(defaults.ImageModel) != "" {
+		imageCandidates = providers.ResolveModelListCandidates(providers.ModelConfig{
+			Primary:   defaults.ImageModel,
+			Fallbacks: defaults.ImageModelFallbacks,
+		}, defaults.Provider, cfg.ModelList)
+	}
 
 	return &AgentInstance{
-		ID:             agentID,
-		Name:           agentName,
-		Model:          model,
-		Fallbacks:      fallbacks,
-		Workspace:      workspace,
-		MaxIterations:  maxIter,
-		MaxTokens:      maxTokens,
-		Temperature:    temperature,
-		ContextWindow:  maxTokens,
-		Provider:       provider,
-		Sessions:       sessionsManager,
-		ContextBuilder: contextBuilder,
-		Tools:          toolsRegistry,
-		Subagents:      subagents,
-		SkillsFilter:   skillsFilter,
-		Candidates:     candidates,
+		ID:              agentID,
+		Name:            agentName,
+		Model:           model,
+		Fallbacks:       fallbacks,
+		Workspace:       workspace,
+		MaxIterations:   maxIter,
+		MaxTokens:       maxTokens,
+		Temperature:     temperature,
+		ContextWindow:   maxTokens,
+		Provider:        provider,
+		Sessions:        sessionsManager,
+		ContextBuilder:  contextBuilder,
+		Tools:           toolsRegistry,
+		Subagents:       subagents,
+		SkillsFilter:    skillsFilter,
+		MCPServers:      mcpServers,
+		Candidates:      candidates,
+		ImageCandidates: imageCandidates,
+		Archive:         archive,
+		Processes:       processes,
+		Router:          newModelRouter(defaults.ModelRouting, model, defaults.Provider, cfg.ModelList),
 	}
 }
 
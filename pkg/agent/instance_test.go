@@ -2,6 +2,8 @@ package agent
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -93,3 +95,41 @@ func TestNewAgentInstance_DefaultsTemperatureWhenUnset(t *testing.T) {
 		t.Fatalf("Temperature = %f, want %f", agent.Temperature, 0.7)
 	}
 }
+
+func TestNewAgentInstance_MemoryToolsReplaceInlinedMemory(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "memory"), 0o755)
+	os.WriteFile(filepath.Join(tmpDir, "memory", "MEMORY.md"), []byte("Secret handshake is two taps."), 0o644)
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace: tmpDir,
+				Model:     "test-model",
+			},
+		},
+	}
+
+	agent := NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	if _, ok := agent.Tools.Get("memory_search"); ok {
+		t.Fatal("memory_search should not be registered when disabled")
+	}
+	if prompt := agent.ContextBuilder.BuildSystemPrompt(); !strings.Contains(prompt, "two taps") {
+		t.Error("expected memory to be inlined when memory tools are disabled")
+	}
+
+	cfg.Tools.Memory.Enabled = true
+	agent = NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	for _, name := range []string{"memory_search", "memory_save"} {
+		if _, ok := agent.Tools.Get(name); !ok {
+			t.Errorf("expected %s to be registered", name)
+		}
+	}
+	prompt := agent.ContextBuilder.BuildSystemPrompt()
+	if strings.Contains(prompt, "two taps") {
+		t.Error("memory should not be inlined when memory_search is available")
+	}
+	if !strings.Contains(prompt, "memory_search") {
+		t.Error("expected the prompt to point at memory_search")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,7 +21,9 @@ import (
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/egress"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/skills"
@@ -37,26 +40,43 @@ type AgentLoop struct {
 	running        atomic.Bool
 	summarizing    sync.Map
 	fallback       *providers.FallbackChain
+	models         *providers.ModelRegistry
 	channelManager *channels.Manager
+	httpSessions   keyedMutex            // serializes HTTP API turns per session
+	mcp            *mcp.Manager          // nil when no MCP servers are configured
+	approval       *tools.ApprovalPolicy // nil when tool approval is disabled
 }
 
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string // Session identifier for history/context
-	Channel         string // Target channel for tool execution
-	ChatID          string // Target chat ID for tool execution
-	UserMessage     string // User message content (may include prefix)
-	DefaultResponse string // Response when LLM returns empty
-	EnableSummary   bool   // Whether to trigger summarization
-	SendResponse    bool   // Whether to send response via bus
-	NoHistory       bool   // If true, don't load session history (for heartbeat)
+	SessionKey      string            // Session identifier for history/context
+	Channel         string            // Target channel for tool execution
+	ChatID          string            // Target chat ID for tool execution
+	SenderID        string            // Sender of the message, exposed to tools
+	Metadata        map[string]string // Inbound message metadata, exposed to tools
+	UserMessage     string            // User message content (may include prefix)
+	Media           []string          // Inbound media (local paths or URLs); images are sent to the model
+	DefaultResponse string            // Response when LLM returns empty
+	EnableSummary   bool              // Whether to trigger summarization
+	SendResponse    bool              // Whether to send response via bus
+	NoHistory       bool              // If true, don't load session history (for heartbeat)
+	OnDelta         func(text string) // If set, receives the answer's deltas once its LLM call returns (HTTP API)
+	RouteModel      bool              // Pick the model by the turn's complexity when model routing is enabled
 }
 
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
 	registry := NewAgentRegistry(cfg, provider)
 
 	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	registerSharedTools(cfg, msgBus, registry)
+
+	var mcpManager *mcp.Manager
+	if len(cfg.Tools.MCP.Servers) > 0 {
+		mcpManager = mcp.NewManager(cfg.Tools.MCP.Servers)
+		mcpManager.Start(context.Background(), func(client *mcp.Client) {
+			registerMCPTools(registry, client)
+		})
+	}
 
 	// Set up shared fallback chain
 	cooldown := providers.NewCooldownTracker()
@@ -69,23 +89,48 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		stateManager = state.NewManager(defaultAgent.Workspace)
 	}
 
-	return &AgentLoop{
+	al := &AgentLoop{
 		bus:         msgBus,
 		cfg:         cfg,
 		registry:    registry,
 		state:       stateManager,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
+		models:      providers.NewModelRegistry(cfg, cooldown),
+		mcp:         mcpManager,
+	}
+	al.registerSubagentTools()
+	if cfg.Tools.Approval.Enabled {
+		al.setupApproval()
+	}
+	return al
+}
+
+// registerMCPTools gives the server's tools to each agent that may use it.
+// It runs again when a server that was down at startup connects later.
+func registerMCPTools(registry *AgentRegistry, client *mcp.Client) {
+	for _, agentID := range registry.ListAgentIDs() {
+		agent, ok := registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		if agent.MCPServers != nil && !slices.Contains(agent.MCPServers, client.Name()) {
+			continue
+		}
+		for _, tool := range client.Tools() {
+			tools.RegisterMCPTool(agent.Tools, client, tool)
+		}
 	}
 }
 
-// registerSharedTools registers tools that are shared across all agents (web, message, spawn).
+// registerSharedTools registers tools that are shared across all agents (web, message, skills).
 func registerSharedTools(
 	cfg *config.Config,
 	msgBus *bus.MessageBus,
 	registry *AgentRegistry,
-	provider providers.LLMProvider,
 ) {
+	egressPolicy := egress.NewPolicy(cfg.Tools.Egress)
+
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
@@ -105,7 +150,9 @@ func registerSharedTools(
 		}); searchTool != nil {
 			agent.Tools.Register(searchTool)
 		}
-		agent.Tools.Register(tools.NewWebFetchTool(50000))
+		fetchTool := tools.NewWebFetchTool(50000)
+		fetchTool.SetEgressPolicy(egressPolicy)
+		agent.Tools.Register(fetchTool)
 
 		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
 		agent.Tools.Register(tools.NewI2CTool())
@@ -127,6 +174,7 @@ func registerSharedTools(
 		registryMgr := skills.NewRegistryManagerFromConfig(skills.RegistryConfig{
 			MaxConcurrentSearches: cfg.Tools.Skills.MaxConcurrentSearches,
 			ClawHub:               skills.ClawHubConfig(cfg.Tools.Skills.Registries.ClawHub),
+			Egress:                egressPolicy,
 		})
 		searchCache := skills.NewSearchCache(
 			cfg.Tools.Skills.SearchCache.MaxSize,
@@ -135,16 +183,6 @@ func registerSharedTools(
 		agent.Tools.Register(tools.NewFindSkillsTool(registryMgr, searchCache))
 		agent.Tools.Register(tools.NewInstallSkillTool(registryMgr, agent.Workspace))
 
-		// Spawn tool with allowlist checker
-		subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace, msgBus)
-		subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
-		spawnTool := tools.NewSpawnTool(subagentManager)
-		currentAgentID := agentID
-		spawnTool.SetAllowlistChecker(func(targetAgentID string) bool {
-			return registry.CanSpawnSubagent(currentAgentID, targetAgentID)
-		})
-		agent.Tools.Register(spawnTool)
-
 		// Update context builder with the complete tools registry
 		agent.ContextBuilder.SetToolsRegistry(agent.Tools)
 	}
@@ -153,6 +191,10 @@ func registerSharedTools(
 func (al *AgentLoop) Run(ctx context.Context) error {
 	al.running.Store(true)
 
+	dispatcher := newSessionDispatcher(al.cfg.Agents.Defaults.MaxConcurrentSessions, al.handleInbound)
+	dispatcher.Start(ctx)
+	defer dispatcher.Close()
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
@@ -163,41 +205,95 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				continue
 			}
 
-			response, err := al.processMessage(ctx, msg)
-			if err != nil {
-				response = fmt.Sprintf("Error processing message: %v", err)
+			// Answers to approval prompts must not queue behind the turn
+			// that is waiting for them.
+			if al.handleApprovalReply(msg) {
+				continue
 			}
 
-			if response != "" {
-				// Check if the message tool already sent a response during this round.
-				// If so, skip publishing to avoid duplicate messages to the user.
-				// Use default agent's tools to check (message tool is shared).
-				alreadySent := false
-				defaultAgent := al.registry.GetDefaultAgent()
-				if defaultAgent != nil {
-					if tool, ok := defaultAgent.Tools.Get("message"); ok {
-						if mt, ok := tool.(*tools.MessageTool); ok {
-							alreadySent = mt.HasSentInRound()
-						}
-					}
-				}
-
-				if !alreadySent {
-					al.bus.PublishOutbound(bus.OutboundMessage{
-						Channel: msg.Channel,
-						ChatID:  msg.ChatID,
-						Content: response,
-					})
-				}
-			}
+			routed := al.routeMessage(msg)
+			dispatcher.Dispatch(routed.sessionKey, routed)
 		}
 	}
 
 	return nil
 }
 
+// routedMessage is an inbound message with the agent and session it was
+// routed to. Bindings can depend on the time of day, so a message is routed
+// once and the result used both to queue it and to process it.
+type routedMessage struct {
+	bus.InboundMessage
+	agent      *AgentInstance
+	sessionKey string
+	route      routing.ResolvedRoute
+}
+
+// routeMessage resolves the agent and session that handle msg. System
+// messages are routed into the default agent's main session.
+func (al *AgentLoop) routeMessage(msg bus.InboundMessage) routedMessage {
+	if msg.Channel == "system" {
+		routed := routedMessage{InboundMessage: msg, agent: al.registry.GetDefaultAgent(), sessionKey: msg.Channel}
+		if routed.agent != nil {
+			routed.sessionKey = routing.BuildAgentMainSessionKey(routed.agent.ID)
+		}
+		return routed
+	}
+	agent, sessionKey, route := al.resolveRoute(msg)
+	return routedMessage{InboundMessage: msg, agent: agent, sessionKey: sessionKey, route: route}
+}
+
+// handleInbound processes one routed inbound message and publishes the reply.
+func (al *AgentLoop) handleInbound(ctx context.Context, routed routedMessage) {
+	msg := routed.InboundMessage
+
+	// Each round gets its own tracker so concurrent sessions don't share
+	// the message tool's "already sent" state.
+	ctx, messageSent := tools.WithSentTracker(ctx)
+	// A reply streamed during the round is replaced by the round's own reply
+	// and by nothing else sent to the chat.
+	ctx, streamed := withStreamedReply(ctx)
+
+	// Channels hand over downloaded images with the message; drop them once the turn is done.
+	defer utils.RemoveMediaFiles(msg.Media)
+
+	response, err := al.processRouted(ctx, routed)
+	replaceID := streamed.take()
+	if err != nil {
+		// Keep what was streamed and report the error below it.
+		response = fmt.Sprintf("Error processing message: %v", err)
+		replaceID = ""
+	}
+
+	// If the message tool already sent a response during this round,
+	// skip publishing to avoid duplicate messages to the user.
+	if response != "" && !messageSent() {
+		al.bus.PublishOutbound(bus.OutboundMessage{
+			Channel:          msg.Channel,
+			ChatID:           msg.ChatID,
+			Content:          response,
+			ReplaceMessageID: replaceID,
+		})
+	}
+}
+
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
+	if al.mcp != nil {
+		al.mcp.Close()
+	}
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		if agent.Processes != nil {
+			agent.Processes.Shutdown()
+		}
+		if agent.SubagentManager != nil {
+			agent.SubagentManager.Shutdown()
+		}
+	}
 }
 
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
@@ -265,7 +361,16 @@ func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, cha
 	})
 }
 
+// processMessage routes msg and runs its turn.
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	return al.processRouted(ctx, al.routeMessage(msg))
+}
+
+// processRouted runs the turn for a message with the agent and session it
+// was routed to.
+func (al *AgentLoop) processRouted(ctx context.Context, routed routedMessage) (string, error) {
+	msg := routed.InboundMessage
+
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -291,7 +396,31 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		return response, nil
 	}
 
-	// Route to determine agent and session key
+	agent, sessionKey := routed.agent, routed.sessionKey
+	logger.InfoCF("agent", "Routed message",
+		map[string]any{
+			"agent_id":    agent.ID,
+			"session_key": sessionKey,
+			"matched_by":  routed.route.MatchedBy,
+		})
+
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         msg.Channel,
+		ChatID:          msg.ChatID,
+		SenderID:        msg.SenderID,
+		Metadata:        msg.Metadata,
+		UserMessage:     msg.Content,
+		Media:           msg.Media,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		EnableSummary:   true,
+		SendResponse:    false,
+		RouteModel:      true,
+	})
+}
+
+// resolveRoute determines the agent and session key that handle msg.
+func (al *AgentLoop) resolveRoute(msg bus.InboundMessage) (*AgentInstance, string, routing.ResolvedRoute) {
 	route := al.registry.ResolveRoute(routing.RouteInput{
 		Channel:    msg.Channel,
 		AccountID:  msg.Metadata["account_id"],
@@ -299,6 +428,11 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		ParentPeer: extractParentPeer(msg),
 		GuildID:    msg.Metadata["guild_id"],
 		TeamID:     msg.Metadata["team_id"],
+
+		Content:     msg.Content,
+		SenderID:    msg.SenderID,
+		SenderRoles: splitRoles(msg.Metadata["sender_roles"]),
+		HasMedia:    len(msg.Media) > 0,
 	})
 
 	agent, ok := al.registry.GetAgent(route.AgentID)
@@ -312,22 +446,7 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		sessionKey = msg.SessionKey
 	}
 
-	logger.InfoCF("agent", "Routed message",
-		map[string]any{
-			"agent_id":    agent.ID,
-			"session_key": sessionKey,
-			"matched_by":  route.MatchedBy,
-		})
-
-	return al.runAgentLoop(ctx, agent, processOptions{
-		SessionKey:      sessionKey,
-		Channel:         msg.Channel,
-		ChatID:          msg.ChatID,
-		UserMessage:     msg.Content,
-		DefaultResponse: "I've completed processing but have no response to give.",
-		EnableSummary:   true,
-		SendResponse:    false,
-	})
+	return agent, sessionKey, route
 }
 
 func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
@@ -379,6 +498,7 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		SessionKey:      sessionKey,
 		Channel:         originChannel,
 		ChatID:          originChatID,
+		SenderID:        msg.SenderID,
 		UserMessage:     fmt.Sprintf("[System: %s] %s", msg.SenderID, msg.Content),
 		DefaultResponse: "Background task completed.",
 		EnableSummary:   false,
@@ -399,29 +519,26 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		}
 	}
 
-	// 1. Update tool contexts
-	al.updateToolContexts(agent, opts.Channel, opts.ChatID)
-
-	// 2. Build messages (skip history for heartbeat)
+	// 1. Build messages (skip history for heartbeat)
 	var history []providers.Message
 	var summary string
 	if !opts.NoHistory {
 		history = agent.Sessions.GetHistory(opts.SessionKey)
-		summary = agent.Sessions.GetSummary(opts.SessionKey)
+		summary = agent.Sessions.GetRollingSummary(opts.SessionKey)
 	}
 	messages := agent.ContextBuilder.BuildMessages(
 		history,
 		summary,
 		opts.UserMessage,
-		nil,
+		opts.Media,
 		opts.Channel,
 		opts.ChatID,
 	)
 
-	// 3. Save user message to session
+	// 2. Save user message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
 
-	// 4. Run LLM iteration loop
+	// 3. Run LLM iteration loop
 	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
 	if err != nil {
 		return "", err
@@ -430,30 +547,31 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	// If last tool had ForUser content and we already sent it, we might not need to send final response
 	// This is controlled by the tool's Silent flag and ForUser content
 
-	// 5. Handle empty response
+	// 4. Handle empty response
 	if finalContent == "" {
 		finalContent = opts.DefaultResponse
 	}
 
-	// 6. Save final assistant message to session
+	// 5. Save final assistant message to session
 	agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
 	agent.Sessions.Save(opts.SessionKey)
 
-	// 7. Optional: summarization
+	// 6. Optional: summarization
 	if opts.EnableSummary {
 		al.maybeSummarize(agent, opts.SessionKey, opts.Channel, opts.ChatID)
 	}
 
-	// 8. Optional: send response via bus
+	// 7. Optional: send response via bus
 	if opts.SendResponse {
 		al.bus.PublishOutbound(bus.OutboundMessage{
-			Channel: opts.Channel,
-			ChatID:  opts.ChatID,
-			Content: finalContent,
+			Channel:          opts.Channel,
+			ChatID:           opts.ChatID,
+			Content:          finalContent,
+			ReplaceMessageID: streamedReplyFrom(ctx).take(),
 		})
 	}
 
-	// 9. Log response
+	// 8. Log response
 	responsePreview := utils.Truncate(finalContent, 120)
 	logger.InfoCF("agent", fmt.Sprintf("Response: %s", responsePreview),
 		map[string]any{
@@ -466,7 +584,22 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	return finalContent, nil
 }
 
-// runLLMIteration executes the LLM call loop with tool handling.
+// chatCandidate sends one fallback attempt to the backend serving it.
+// Candidates resolved from model_list go through the model registry, which
+// picks among the entries sharing the name; anything else goes to the
+// agent's default provider as before.
+func (al *AgentLoop) chatCandidate(
+	ctx context.Context,
+	agent *AgentInstance,
+	candidate providers.FallbackCandidate,
+	call func(ctx context.Context, provider providers.LLMProvider, model string) (*providers.LLMResponse, error),
+) (*providers.LLMResponse, error) {
+	if candidate.ModelName != "" {
+		return al.models.Do(ctx, candidate.ModelName, call)
+	}
+	return call(ctx, agent.Provider, candidate.Model)
+}
+
 func (al *AgentLoop) runLLMIteration(
 	ctx context.Context,
 	agent *AgentInstance,
@@ -476,9 +609,53 @@ func (al *AgentLoop) runLLMIteration(
 	iteration := 0
 	var finalContent string
 
+	// OnDelta callers cannot take text back, so they get the answer's deltas
+	// once the call that produced it is known to be the last, rather than
+	// text that turns out to precede tool calls or comes from a failed
+	// fallback attempt.
+	var stream streamSink
+	var answer *answerSink
+	if opts.OnDelta != nil {
+		answer = &answerSink{onDelta: opts.OnDelta}
+		stream = answer
+	} else if publisher := al.newStreamPublisher(ctx, agent, opts); publisher != nil {
+		defer publisher.Close()
+		stream = publisher
+	}
+
+	// Pick the model for this turn. Replies from the small model are not
+	// streamed, since they are discarded when the turn is escalated.
+	tier := agentTier(agent)
+	if opts.RouteModel && agent.Router != nil {
+		tier = al.routeTurn(ctx, agent, messages, opts)
+	}
+	turnStream := stream
+	if tier.name == tierSmall {
+		messages[0].Content += smallTierNote
+		turnStream = nil
+	}
+	smallToolCalls := make(map[string]bool)
+	escalate := func(reason string) {
+		logger.InfoCF("agent", "Escalating turn to the agent's model",
+			map[string]any{
+				"agent_id":  agent.ID,
+				"from":      tier.model,
+				"to":        agent.CurrentModel(),
+				"reason":    reason,
+				"iteration": iteration,
+			})
+		tier = agentTier(agent)
+		turnStream = stream
+		messages[0].Content = strings.TrimSuffix(messages[0].Content, smallTierNote)
+	}
+
 	for iteration < agent.MaxIterations {
 		iteration++
 
+		if tier.name == tierSmall && iteration > agent.Router.maxSmallIterations {
+			escalate("iterations")
+		}
+
 		logger.DebugCF("agent", "LLM iteration",
 			map[string]any{
 				"agent_id":  agent.ID,
@@ -494,7 +671,7 @@ func (al *AgentLoop) runLLMIteration(
 			map[string]any{
 				"agent_id":          agent.ID,
 				"iteration":         iteration,
-				"model":             agent.Model,
+				"model":             tier.model,
 				"messages_count":    len(messages),
 				"tools_count":       len(providerToolDefs),
 				"max_tokens":        agent.MaxTokens,
@@ -514,16 +691,29 @@ func (al *AgentLoop) runLLMIteration(
 		var response *providers.LLMResponse
 		var err error
 
+		llmOptions := map[string]any{
+			"max_tokens":  agent.MaxTokens,
+			"temperature": agent.Temperature,
+		}
+		chatCandidate := func(ctx context.Context, candidate providers.FallbackCandidate) (*providers.LLMResponse, error) {
+			return al.chatCandidate(ctx, agent, candidate,
+				func(ctx context.Context, p providers.LLMProvider, model string) (*providers.LLMResponse, error) {
+					return chat(ctx, p, turnStream, messages, providerToolDefs, model, llmOptions)
+				})
+		}
+
 		callLLM := func() (*providers.LLMResponse, error) {
-			if len(agent.Candidates) > 1 && al.fallback != nil {
-				fbResult, fbErr := al.fallback.Execute(ctx, agent.Candidates,
-					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]any{
-							"max_tokens":  agent.MaxTokens,
-							"temperature": agent.Temperature,
-						})
-					},
-				)
+			if len(agent.ImageCandidates) > 0 && al.fallback != nil && hasImages(messages) {
+				fbResult, fbErr := al.fallback.ExecuteImage(ctx, agent.ImageCandidates, chatCandidate)
+				if fbErr != nil {
+					return nil, fbErr
+				}
+				logger.InfoCF("agent", fmt.Sprintf("Image request served by %s/%s", fbResult.Provider, fbResult.Model),
+					map[string]any{"agent_id": agent.ID, "iteration": iteration, "attempts": len(fbResult.Attempts) + 1})
+				return fbResult.Response, nil
+			}
+			if len(tier.candidates) > 1 && al.fallback != nil {
+				fbResult, fbErr := al.fallback.Execute(ctx, tier.candidates, chatCandidate)
 				if fbErr != nil {
 					return nil, fbErr
 				}
@@ -534,10 +724,10 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, map[string]any{
-				"max_tokens":  agent.MaxTokens,
-				"temperature": agent.Temperature,
-			})
+			if len(tier.candidates) == 1 && tier.candidates[0].ModelName != "" {
+				return chatCandidate(ctx, tier.candidates[0])
+			}
+			return chat(ctx, agent.Provider, turnStream, messages, providerToolDefs, tier.model, llmOptions)
 		}
 
 		// Retry loop for context/token errors
@@ -570,16 +760,24 @@ func (al *AgentLoop) runLLMIteration(
 
 				al.forceCompression(agent, opts.SessionKey)
 				newHistory := agent.Sessions.GetHistory(opts.SessionKey)
-				newSummary := agent.Sessions.GetSummary(opts.SessionKey)
+				newSummary := agent.Sessions.GetRollingSummary(opts.SessionKey)
 				messages = agent.ContextBuilder.BuildMessages(
 					newHistory, newSummary, "",
 					nil, opts.Channel, opts.ChatID,
 				)
+				if tier.name == tierSmall {
+					messages[0].Content += smallTierNote
+				}
 				continue
 			}
 			break
 		}
 
+		if err != nil && tier.name == tierSmall {
+			escalate("error: " + err.Error())
+			iteration--
+			continue
+		}
 		if err != nil {
 			logger.ErrorCF("agent", "LLM call failed",
 				map[string]any{
@@ -589,10 +787,18 @@ func (al *AgentLoop) runLLMIteration(
 				})
 			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
+		if tier.name == tierSmall && strings.Contains(response.Content, escalateMarker) {
+			escalate("low_confidence")
+			iteration--
+			continue
+		}
 
 		// Check if no tool calls - we're done
 		if len(response.ToolCalls) == 0 {
 			finalContent = response.Content
+			if answer != nil && finalContent != "" {
+				answer.release(finalContent)
+			}
 			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
 				map[string]any{
 					"agent_id":      agent.ID,
@@ -653,40 +859,46 @@ func (al *AgentLoop) runLLMIteration(
 		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
 
 		// Execute tool calls
-		for _, tc := range normalizedToolCalls {
-			argsJSON, _ := json.Marshal(tc.Arguments)
-			argsPreview := utils.Truncate(string(argsJSON), 200)
-			logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
-				map[string]any{
-					"agent_id":  agent.ID,
-					"tool":      tc.Name,
-					"iteration": iteration,
-				})
+		execCtx := tools.ExecutionContext{
+			Channel:    opts.Channel,
+			ChatID:     opts.ChatID,
+			SenderID:   opts.SenderID,
+			SessionKey: opts.SessionKey,
+			AgentID:    agent.ID,
+			Metadata:   opts.Metadata,
+		}
+		// Concurrency-safe calls may run in parallel; results stay in call order.
+		results := agent.Tools.ExecuteToolCalls(normalizedToolCalls, al.cfg.Agents.Defaults.MaxParallelToolCalls,
+			func(tc providers.ToolCall) *tools.ToolResult {
+				argsJSON, _ := json.Marshal(tc.Arguments)
+				argsPreview := utils.Truncate(string(argsJSON), 200)
+				logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+					map[string]any{
+						"agent_id":  agent.ID,
+						"tool":      tc.Name,
+						"iteration": iteration,
+					})
 
-			// Create async callback for tools that implement AsyncTool
-			// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
-			// Instead, they notify the agent via PublishInbound, and the agent decides
-			// whether to forward the result to the user (in processSystemMessage).
-			asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
-				// Log the async completion but don't send directly to user
-				// The agent will handle user notification via processSystemMessage
-				if !result.Silent && result.ForUser != "" {
-					logger.InfoCF("agent", "Async tool completed, agent will handle notification",
-						map[string]any{
-							"tool":        tc.Name,
-							"content_len": len(result.ForUser),
-						})
+				// Async callback for tools that report completion later
+				// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
+				// Instead, they notify the agent via PublishInbound, and the agent decides
+				// whether to forward the result to the user (in processSystemMessage).
+				asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
+					// Log the async completion but don't send directly to user
+					// The agent will handle user notification via processSystemMessage
+					if !result.Silent && result.ForUser != "" {
+						logger.InfoCF("agent", "Async tool completed, agent will handle notification",
+							map[string]any{
+								"tool":        tc.Name,
+								"content_len": len(result.ForUser),
+							})
+					}
 				}
-			}
 
-			toolResult := agent.Tools.ExecuteWithContext(
-				ctx,
-				tc.Name,
-				tc.Arguments,
-				opts.Channel,
-				opts.ChatID,
-				asyncCallback,
-			)
+				return agent.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, execCtx, asyncCallback)
+			})
+		for i, tc := range normalizedToolCalls {
+			toolResult := results[i]
 
 			// Send ForUser content to user immediately if not Silent
 			if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
@@ -718,29 +930,13 @@ func (al *AgentLoop) runLLMIteration(
 			// Save tool result message to session
 			agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
 		}
-	}
 
-	return finalContent, iteration, nil
-}
-
-// updateToolContexts updates the context for tools that need channel/chatID info.
-func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID string) {
-	// Use ContextualTool interface instead of type assertions
-	if tool, ok := agent.Tools.Get("message"); ok {
-		if mt, ok := tool.(tools.ContextualTool); ok {
-			mt.SetContext(channel, chatID)
-		}
-	}
-	if tool, ok := agent.Tools.Get("spawn"); ok {
-		if st, ok := tool.(tools.ContextualTool); ok {
-			st.SetContext(channel, chatID)
-		}
-	}
-	if tool, ok := agent.Tools.Get("subagent"); ok {
-		if st, ok := tool.(tools.ContextualTool); ok {
-			st.SetContext(channel, chatID)
+		if tier.name == tierSmall && repeatsToolCall(smallToolCalls, assistantMsg.ToolCalls) {
+			escalate("loop")
 		}
 	}
+
+	return finalContent, iteration, nil
 }
 
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
@@ -809,6 +1005,22 @@ func (al *AgentLoop) forceCompression(agent *AgentInstance, sessionKey string) {
 	newHistory = append(newHistory, keptConversation...)
 	newHistory = append(newHistory, history[len(history)-1]) // Last message
 
+	// Archive what is dropped so the raw conversation stays auditable.
+	dropped := conversation[:mid]
+	err := agent.Archive.Save(ArchivedChunk{
+		ID:            newChunkID(time.Now(), sessionKey, 0),
+		AgentID:       agent.ID,
+		SessionKey:    sessionKey,
+		Archived:      time.Now(),
+		TokenEstimate: al.estimateTokens(dropped),
+		Summary:       "Dropped by emergency compression; not summarized.",
+		Messages:      dropped,
+	})
+	if err != nil {
+		logger.ErrorCF("agent", "Failed to archive messages dropped by forced compression",
+			map[string]any{"session_key": sessionKey, "error": err.Error()})
+	}
+
 	// Update session
 	agent.Sessions.SetHistory(sessionKey, newHistory)
 	agent.Sessions.Save(sessionKey)
@@ -848,6 +1060,16 @@ func (al *AgentLoop) GetStartupInfo() map[string]any {
 	return info
 }
 
+// hasImages reports whether any message in the request carries an image part.
+func hasImages(messages []providers.Message) bool {
+	for _, msg := range messages {
+		if msg.HasImages() {
+			return true
+		}
+	}
+	return false
+}
+
 // formatMessagesForLog formats messages for logging
 func formatMessagesForLog(messages []providers.Message) string {
 	if len(messages) == 0 {
@@ -899,32 +1121,99 @@ func formatToolsForLog(toolDefs []providers.ToolDefinition) string {
 	return sb.String()
 }
 
-// summarizeSession summarizes the conversation history for a session.
+// summarizeSession moves old history out of the session. Everything before the
+// continuity buffer is split into chunks by token budget; each chunk is
+// summarized, archived to cold storage with its raw messages, and folded into
+// the session's rolling summary. Messages are only dropped from the session
+// once their chunk has been archived.
 func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
 
+	settings := compressionSettings(al.cfg.Compression)
 	history := agent.Sessions.GetHistory(sessionKey)
-	summary := agent.Sessions.GetSummary(sessionKey)
 
-	// Keep last 4 messages for continuity
-	if len(history) <= 4 {
+	// Keep the last messages for continuity, without starting on orphaned tool results.
+	cut := len(history) - settings.ContinuityBuffer
+	for cut > 0 && history[cut].Role == "tool" {
+		cut--
+	}
+	if cut <= 0 {
+		return
+	}
+
+	chunks := chunkHistory(history[:cut], settings.ChunkSizeTokens, settings.MinChunkMessages)
+	rolling := agent.Sessions.GetRollingSummary(sessionKey)
+	now := time.Now()
+	archivedMessages := 0
+	archivedChunks := 0
+
+	for i, chunk := range chunks {
+		summary, err := al.summarizeChunk(ctx, agent, chunk)
+		if err != nil {
+			logger.WarnCF("agent", "Chunk summarization failed, keeping remaining history",
+				map[string]any{"session_key": sessionKey, "error": err.Error()})
+			break
+		}
+
+		record := ArchivedChunk{
+			ID:            newChunkID(now, sessionKey, i),
+			AgentID:       agent.ID,
+			SessionKey:    sessionKey,
+			Archived:      now,
+			TokenEstimate: al.estimateTokens(chunk),
+			Summary:       summary,
+			Messages:      chunk,
+		}
+		if err := agent.Archive.Save(record); err != nil {
+			logger.ErrorCF("agent", "Failed to archive conversation chunk, keeping remaining history",
+				map[string]any{"session_key": sessionKey, "error": err.Error()})
+			break
+		}
+		archivedMessages += len(chunk)
+		archivedChunks++
+
+		if summary != "" {
+			rolling = al.mergeRollingSummary(ctx, agent, rolling, summary, settings.SummaryMaxTokens)
+		}
+	}
+
+	if archivedMessages == 0 {
 		return
 	}
 
-	toSummarize := history[:len(history)-4]
+	agent.Sessions.SetRollingSummary(sessionKey, rolling)
+	agent.Sessions.DropOldest(sessionKey, archivedMessages)
+	agent.Sessions.Save(sessionKey)
+
+	logger.InfoCF("agent", "Archived conversation history",
+		map[string]any{
+			"agent_id":    agent.ID,
+			"session_key": sessionKey,
+			"chunks":      archivedChunks,
+			"messages":    archivedMessages,
+			"archive_dir": agent.Archive.Dir(),
+			"summary_len": len(rolling),
+		})
+}
 
-	// Oversized Message Guard
+// summarizeChunk summarizes the user and assistant turns of one chunk.
+// Messages larger than half the context window are left out of the prompt;
+// they remain available verbatim in the archive.
+func (al *AgentLoop) summarizeChunk(
+	ctx context.Context,
+	agent *AgentInstance,
+	chunk []providers.Message,
+) (string, error) {
 	maxMessageTokens := agent.ContextWindow / 2
-	validMessages := make([]providers.Message, 0)
+	validMessages := make([]providers.Message, 0, len(chunk))
 	omitted := false
 
-	for _, m := range toSummarize {
+	for _, m := range chunk {
 		if m.Role != "user" && m.Role != "assistant" {
 			continue
 		}
-		msgTokens := len(m.Content) / 2
-		if msgTokens > maxMessageTokens {
+		if estimateMessageTokens(m) > maxMessageTokens {
 			omitted = true
 			continue
 		}
@@ -932,52 +1221,52 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	}
 
 	if len(validMessages) == 0 {
-		return
+		return "", nil
 	}
 
-	// Multi-Part Summarization
-	var finalSummary string
-	if len(validMessages) > 10 {
-		mid := len(validMessages) / 2
-		part1 := validMessages[:mid]
-		part2 := validMessages[mid:]
-
-		s1, _ := al.summarizeBatch(ctx, agent, part1, "")
-		s2, _ := al.summarizeBatch(ctx, agent, part2, "")
-
-		mergePrompt := fmt.Sprintf(
-			"Merge these two conversation summaries into one cohesive summary:\n\n1: %s\n\n2: %s",
-			s1,
-			s2,
-		)
-		resp, err := agent.Provider.Chat(
-			ctx,
-			[]providers.Message{{Role: "user", Content: mergePrompt}},
-			nil,
-			agent.Model,
-			map[string]any{
-				"max_tokens":  1024,
-				"temperature": 0.3,
-			},
-		)
-		if err == nil {
-			finalSummary = resp.Content
-		} else {
-			finalSummary = s1 + " " + s2
-		}
-	} else {
-		finalSummary, _ = al.summarizeBatch(ctx, agent, validMessages, summary)
+	summary, err := al.summarizeBatch(ctx, agent, validMessages, "")
+	if err != nil {
+		return "", err
 	}
+	if omitted && summary != "" {
+		summary += "\n[Note: Some oversized messages were omitted from this summary; see the archived chunk.]"
+	}
+	return summary, nil
+}
 
-	if omitted && finalSummary != "" {
-		finalSummary += "\n[Note: Some oversized messages were omitted from this summary for efficiency.]"
+// mergeRollingSummary folds a chunk summary into the session's rolling summary,
+// keeping it within maxTokens. If the merge call fails the summaries are
+// concatenated so nothing is lost.
+func (al *AgentLoop) mergeRollingSummary(
+	ctx context.Context,
+	agent *AgentInstance,
+	rolling, chunkSummary string,
+	maxTokens int,
+) string {
+	if strings.TrimSpace(rolling) == "" {
+		return chunkSummary
 	}
 
-	if finalSummary != "" {
-		agent.Sessions.SetSummary(sessionKey, finalSummary)
-		agent.Sessions.TruncateHistory(sessionKey, 4)
-		agent.Sessions.Save(sessionKey)
+	prompt := fmt.Sprintf(
+		"Update the running summary of a conversation with the summary of the next segment. "+
+			"Keep durable facts, decisions, preferences and open tasks; drop details that no longer matter. "+
+			"Stay under about %d tokens.\n\nRUNNING SUMMARY:\n%s\n\nNEXT SEGMENT:\n%s",
+		maxTokens, rolling, chunkSummary,
+	)
+	resp, err := agent.Provider.Chat(
+		ctx,
+		[]providers.Message{{Role: "user", Content: prompt}},
+		nil,
+		agent.CurrentModel(),
+		map[string]any{
+			"max_tokens":  maxTokens,
+			"temperature": 0.3,
+		},
+	)
+	if err != nil || strings.TrimSpace(resp.Content) == "" {
+		return rolling + "\n\n" + chunkSummary
 	}
+	return resp.Content
 }
 
 // summarizeBatch summarizes a batch of messages.
@@ -1004,7 +1293,7 @@ func (al *AgentLoop) summarizeBatch(
 		ctx,
 		[]providers.Message{{Role: "user", Content: prompt}},
 		nil,
-		agent.Model,
+		agent.CurrentModel(),
 		map[string]any{
 			"max_tokens":  1024,
 			"temperature": 0.3,
@@ -1028,6 +1317,11 @@ func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
 	return totalChars * 2 / 5
 }
 
+// estimateMessageTokens applies the estimateTokens heuristic to a single message.
+func estimateMessageTokens(m providers.Message) int {
+	return utf8.RuneCountInString(m.Content) * 2 / 5
+}
+
 func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage) (string, bool) {
 	content := strings.TrimSpace(msg.Content)
 	if !strings.HasPrefix(content, "/") {
@@ -1053,7 +1347,7 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 			if defaultAgent == nil {
 				return "No default agent configured", true
 			}
-			return fmt.Sprintf("Current model: %s", defaultAgent.Model), true
+			return fmt.Sprintf("Current model: %s", defaultAgent.CurrentModel()), true
 		case "channel":
 			return fmt.Sprintf("Current channel: %s", msg.Channel), true
 		case "agents":
@@ -1099,8 +1393,7 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 			if defaultAgent == nil {
 				return "No default agent configured", true
 			}
-			oldModel := defaultAgent.Model
-			defaultAgent.Model = value
+			oldModel := defaultAgent.SetModel(value)
 			return fmt.Sprintf("Switched model from %s to %s", oldModel, value), true
 		case "channel":
 			if al.channelManager == nil {
@@ -1113,6 +1406,36 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		default:
 			return fmt.Sprintf("Unknown switch target: %s", target), true
 		}
+
+	case "/tasks":
+		agent, _, _ := al.resolveRoute(msg)
+		if agent == nil || agent.SubagentManager == nil {
+			return "Subagents are not available", true
+		}
+		manager := agent.SubagentManager
+		if len(args) == 0 {
+			return tools.FormatSubagentTasks(manager.TasksFor(msg.Channel, msg.ChatID)), true
+		}
+
+		cancel := args[0] == "cancel"
+		taskID := args[0]
+		if cancel {
+			if len(args) < 2 {
+				return "Usage: /tasks [<task_id>|cancel <task_id>]", true
+			}
+			taskID = args[1]
+		}
+		task, ok := manager.GetTask(taskID)
+		if !ok || task.OriginChannel != msg.Channel || task.OriginChatID != msg.ChatID {
+			return fmt.Sprintf("Task %s not found", taskID), true
+		}
+		if !cancel {
+			return tools.FormatSubagentTask(task), true
+		}
+		if !manager.Cancel(taskID) {
+			return fmt.Sprintf("Task %s is not running (status: %s)", taskID, task.Status), true
+		}
+		return fmt.Sprintf("Cancelled task %s", taskID), true
 	}
 
 	return "", false
@@ -1135,6 +1458,14 @@ func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	return &routing.RoutePeer{Kind: peerKind, ID: peerID}
 }
 
+// splitRoles splits the comma-separated sender_roles metadata of a message.
+func splitRoles(roles string) []string {
+	if roles == "" {
+		return nil
+	}
+	return strings.Split(roles, ",")
+}
+
 // extractParentPeer extracts the parent peer (reply-to) from inbound message metadata.
 func extractParentPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	parentKind := msg.Metadata["parent_peer_kind"]
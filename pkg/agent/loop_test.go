@@ -2,7 +2,10 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -187,8 +190,8 @@ func TestToolRegistry_ToolRegistration(t *testing.T) {
 	}
 }
 
-// TestToolContext_Updates verifies tool context is updated with channel/chatID
-func TestToolContext_Updates(t *testing.T) {
+// TestToolExecution_ReceivesExecutionContext verifies tools see the turn's execution context
+func TestToolExecution_ReceivesExecutionContext(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-test-*")
 	if err != nil {
 		t.Fatalf("Failed to create temp dir: %v", err)
@@ -207,15 +210,198 @@ func TestToolContext_Updates(t *testing.T) {
 	}
 
 	msgBus := bus.NewMessageBus()
-	provider := &simpleMockProvider{response: "OK"}
-	_ = NewAgentLoop(cfg, msgBus, provider)
+	provider := &toolCallMockProvider{toolName: "mock_exec_ctx", finalResponse: "done"}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	ctxTool := &mockExecCtxTool{}
+	al.RegisterTool(ctxTool)
+
+	helper := testHelper{al: al}
+	response := helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user-1",
+		ChatID:   "chat-1",
+		Content:  "hello",
+		Metadata: map[string]string{"peer_kind": "direct"},
+	})
+	if response != "done" {
+		t.Fatalf("Expected 'done', got %q", response)
+	}
+
+	got := ctxTool.execCtx
+	if got.Channel != "telegram" || got.ChatID != "chat-1" || got.SenderID != "user-1" {
+		t.Errorf("Unexpected routing in execution context: %+v", got)
+	}
+	if got.AgentID != "main" || got.SessionKey == "" {
+		t.Errorf("Expected agent and session in execution context, got %+v", got)
+	}
+	if got.Metadata["peer_kind"] != "direct" {
+		t.Errorf("Expected metadata in execution context, got %v", got.Metadata)
+	}
+}
+
+// TestProcessMessage_FallbackUsesCandidateProvider verifies each fallback attempt reaches its own backend
+func TestProcessMessage_FallbackUsesCandidateProvider(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limit exceeded"}`, http.StatusTooManyRequests)
+	}))
+	defer primary.Close()
+
+	var backupModel string
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		backupModel, _ = body["model"].(string)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"from backup"},"finish_reason":"stop"}]}`)
+	}))
+	defer backup.Close()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "primary",
+				ModelFallbacks:    []string{"backup"},
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "primary", Model: "openai/gpt-4o", APIBase: primary.URL},
+			{ModelName: "backup", Model: "deepseek/deepseek-chat", APIBase: backup.URL},
+		},
+	}
+
+	provider := &recordingMockProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	helper := testHelper{al: al}
+
+	response := helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user-1",
+		ChatID:   "chat-1",
+		Content:  "hello",
+	})
+
+	if response != "from backup" {
+		t.Errorf("Expected backup response, got %q", response)
+	}
+	if backupModel != "deepseek-chat" {
+		t.Errorf("Expected backup to receive its own model ID, got %q", backupModel)
+	}
+	if provider.lastModel != "" {
+		t.Errorf("Expected the default provider not to be called, got model %q", provider.lastModel)
+	}
+}
+
+// TestProcessMessage_FallbackBetweenAliasesOfOneModel verifies two model_list
+// entries for the same model each get their own attempt at their own endpoint
+func TestProcessMessage_FallbackBetweenAliasesOfOneModel(t *testing.T) {
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limit exceeded"}`, http.StatusTooManyRequests)
+	}))
+	defer direct.Close()
+
+	proxyCalls := 0
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyCalls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"from proxy"},"finish_reason":"stop"}]}`)
+	}))
+	defer proxy.Close()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "gpt4o-direct",
+				ModelFallbacks:    []string{"gpt4o-proxy"},
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "gpt4o-direct", Model: "openai/gpt-4o", APIBase: direct.URL},
+			{ModelName: "gpt4o-proxy", Model: "openai/gpt-4o", APIBase: proxy.URL},
+		},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &recordingMockProvider{})
+	helper := testHelper{al: al}
 
-	// Verify that ContextualTool interface is defined and can be implemented
-	// This test validates the interface contract exists
-	ctxTool := &mockContextualTool{}
+	response := helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user-1",
+		ChatID:   "chat-1",
+		Content:  "hello",
+	})
 
-	// Verify the tool implements the interface correctly
-	var _ tools.ContextualTool = ctxTool
+	if response != "from proxy" {
+		t.Errorf("Expected the proxy's response, got %q", response)
+	}
+	if proxyCalls != 1 {
+		t.Errorf("Expected one call through the proxy, got %d", proxyCalls)
+	}
+}
+
+// TestProcessMessage_RoutesImagesToImageModel verifies inbound images reach the image model as content parts
+func TestProcessMessage_RoutesImagesToImageModel(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "agent-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	imgPath := filepath.Join(tmpDir, "screenshot.png")
+	if err := os.WriteFile(imgPath, []byte("\x89PNG\r\n\x1a\n0000"), 0o644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				ImageModel:        "vision-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	msgBus := bus.NewMessageBus()
+	provider := &recordingMockProvider{}
+	al := NewAgentLoop(cfg, msgBus, provider)
+	helper := testHelper{al: al}
+
+	helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user-1",
+		ChatID:   "chat-1",
+		Content:  "what is this?\n[image: photo]",
+		Media:    []string{imgPath},
+	})
+
+	if provider.lastModel != "vision-model" {
+		t.Errorf("Expected image turn to use vision-model, got %q", provider.lastModel)
+	}
+	last := provider.lastMessages[len(provider.lastMessages)-1]
+	if !last.HasImages() {
+		t.Fatalf("Expected image part in user message, got %+v", last)
+	}
+	if last.Parts[1].Image.Path != imgPath {
+		t.Errorf("Expected image path %q, got %+v", imgPath, last.Parts[1].Image)
+	}
+
+	helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user-1",
+		ChatID:   "chat-1",
+		Content:  "thanks",
+	})
+	if provider.lastModel != "test-model" {
+		t.Errorf("Expected text turn to use test-model, got %q", provider.lastModel)
+	}
 }
 
 // TestToolRegistry_GetDefinitions verifies tool definitions can be retrieved
@@ -366,6 +552,28 @@ func (m *simpleMockProvider) GetDefaultModel() string {
 	return "mock-model"
 }
 
+// recordingMockProvider records the model and messages of the last call
+type recordingMockProvider struct {
+	lastModel    string
+	lastMessages []providers.Message
+}
+
+func (m *recordingMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.lastModel = model
+	m.lastMessages = messages
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (m *recordingMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
 // mockCustomTool is a simple mock tool for registration testing
 type mockCustomTool struct{}
 
@@ -388,34 +596,61 @@ func (m *mockCustomTool) Execute(ctx context.Context, args map[string]any) *tool
 	return tools.SilentResult("Custom tool executed")
 }
 
-// mockContextualTool tracks context updates
-type mockContextualTool struct {
-	lastChannel string
-	lastChatID  string
+// toolCallMockProvider requests one tool call, then answers with finalResponse
+type toolCallMockProvider struct {
+	toolName      string
+	finalResponse string
+	calls         int
+}
+
+func (m *toolCallMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.calls++
+	if m.calls == 1 {
+		return &providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{
+				ID:        "call-1",
+				Type:      "function",
+				Name:      m.toolName,
+				Arguments: map[string]any{},
+			}},
+		}, nil
+	}
+	return &providers.LLMResponse{Content: m.finalResponse}, nil
 }
 
-func (m *mockContextualTool) Name() string {
-	return "mock_contextual"
+func (m *toolCallMockProvider) GetDefaultModel() string {
+	return "mock-model"
 }
 
-func (m *mockContextualTool) Description() string {
-	return "Mock contextual tool"
+// mockExecCtxTool records the execution context it was called with
+type mockExecCtxTool struct {
+	execCtx tools.ExecutionContext
 }
 
-func (m *mockContextualTool) Parameters() map[string]any {
+func (m *mockExecCtxTool) Name() string {
+	return "mock_exec_ctx"
+}
+
+func (m *mockExecCtxTool) Description() string {
+	return "Mock tool recording its execution context"
+}
+
+func (m *mockExecCtxTool) Parameters() map[string]any {
 	return map[string]any{
 		"type":       "object",
 		"properties": map[string]any{},
 	}
 }
 
-func (m *mockContextualTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
-	return tools.SilentResult("Contextual tool executed")
-}
-
-func (m *mockContextualTool) SetContext(channel, chatID string) {
-	m.lastChannel = channel
-	m.lastChatID = chatID
+func (m *mockExecCtxTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	m.execCtx = tools.GetExecutionContext(ctx)
+	return tools.SilentResult("recorded")
 }
 
 // testHelper executes a message and returns the response
@@ -631,3 +866,70 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		t.Errorf("Expected history to be compressed (len < 8), got %d", len(finalHistory))
 	}
 }
+
+func TestSwitchModel_SafeWhileTurnsRun(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "ok"})
+	agent := al.registry.GetDefaultAgent()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			al.handleCommand(context.Background(), bus.InboundMessage{
+				Channel: "cli",
+				ChatID:  "direct",
+				Content: fmt.Sprintf("/switch model to model-%d", i),
+			})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = agentTier(agent).model
+	}
+	<-done
+
+	if got := agent.CurrentModel(); got != "model-99" {
+		t.Errorf("CurrentModel() = %q, want model-99", got)
+	}
+	msg := bus.InboundMessage{Channel: "cli", ChatID: "direct", Content: "/show model"}
+	if got, _ := al.handleCommand(context.Background(), msg); got != "Current model: model-99" {
+		t.Errorf("/show model = %q", got)
+	}
+}
+
+func TestHandleInbound_UsesRouteResolvedAtDispatch(t *testing.T) {
+	provider := &recordingProvider{}
+	al := newSubagentTestLoop(t, provider)
+	main, _ := al.registry.GetAgent("main")
+	coder, _ := al.registry.GetAgent("coder")
+
+	// The message was routed to coder when it was queued, say by a schedule
+	// binding that no longer matches once a worker picks it up.
+	routed := al.routeMessage(bus.InboundMessage{Channel: "cli", SenderID: "user", ChatID: "direct", Content: "hi"})
+	if routed.agent != main {
+		t.Fatalf("routed to %s, want main", routed.agent.ID)
+	}
+	mainKey := routed.sessionKey
+	routed.agent, routed.sessionKey = coder, "agent:coder:main"
+
+	al.handleInbound(context.Background(), routed)
+
+	if len(provider.calls) != 1 || provider.calls[0].model != "coder-model" {
+		t.Fatalf("expected one call to coder-model, got %+v", provider.calls)
+	}
+	if history := coder.Sessions.GetHistory("agent:coder:main"); len(history) == 0 {
+		t.Error("expected the turn in the session chosen at dispatch")
+	}
+	if history := main.Sessions.GetHistory(mainKey); len(history) != 0 {
+		t.Errorf("expected nothing in main's session, got %d messages", len(history))
+	}
+}
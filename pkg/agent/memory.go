@@ -7,11 +7,18 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 // MemoryStore manages persistent memory for the agent.
@@ -21,6 +28,7 @@ type MemoryStore struct {
 	workspace  string
 	memoryDir  string
 	memoryFile string
+	mu         sync.Mutex // serializes appends from concurrent sessions
 }
 
 // NewMemoryStore creates a new MemoryStore with the given workspace path.
@@ -61,6 +69,18 @@ func (ms *MemoryStore) WriteLongTerm(content string) error {
 	return os.WriteFile(ms.memoryFile, []byte(content), 0o644)
 }
 
+// AppendLongTerm appends content to the long-term memory file as a new paragraph.
+func (ms *MemoryStore) AppendLongTerm(content string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	existing := strings.TrimRight(ms.ReadLongTerm(), "\n")
+	if existing == "" {
+		return ms.WriteLongTerm(content + "\n")
+	}
+	return ms.WriteLongTerm(existing + "\n\n" + content + "\n")
+}
+
 // ReadToday reads today's daily note.
 // Returns empty string if the file doesn't exist.
 func (ms *MemoryStore) ReadToday() string {
@@ -74,6 +94,9 @@ func (ms *MemoryStore) ReadToday() string {
 // AppendToday appends content to today's daily note.
 // If the file doesn't exist, it creates a new file with a date header.
 func (ms *MemoryStore) AppendToday(content string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
 	todayFile := ms.getTodayFile()
 
 	// Ensure month directory exists
@@ -149,3 +172,58 @@ func (ms *MemoryStore) GetMemoryContext() string {
 
 	return sb.String()
 }
+
+// newMemoryIndex builds the search index behind memory_search over the
+// memory directory and the conversation archive. When an embedding model is
+// configured, search blends keyword and semantic similarity.
+func newMemoryIndex(cfg *config.Config, workspace string, archive *ChunkArchive) *memory.Index {
+	index := memory.NewIndex(workspace, "memory", archive.Dir())
+
+	modelName := strings.TrimSpace(cfg.Tools.Memory.EmbeddingModel)
+	if modelName == "" {
+		return index
+	}
+	modelCfg, err := cfg.GetModelConfig(modelName)
+	if err != nil {
+		logger.WarnCF("agent", "Embedding model not found, memory search uses keywords only",
+			map[string]any{"model": modelName, "error": err.Error()})
+		return index
+	}
+	provider, modelID, err := providers.CreateProviderFromConfig(modelCfg)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to create embedding provider, memory search uses keywords only",
+			map[string]any{"model": modelName, "error": err.Error()})
+		return index
+	}
+	embedder, ok := provider.(providers.EmbeddingProvider)
+	if !ok {
+		logger.WarnCF("agent", "Provider does not support embeddings, memory search uses keywords only",
+			map[string]any{"model": modelName})
+		return index
+	}
+
+	cacheFile := filepath.Join(workspace, "memory", ".embeddings", safeFileName(modelName)+".json")
+	index.SetEmbedder(&providerEmbedder{provider: embedder, model: modelID}, cacheFile)
+	return index
+}
+
+// providerEmbedder adapts an EmbeddingProvider to memory.Embedder.
+type providerEmbedder struct {
+	provider providers.EmbeddingProvider
+	model    string
+}
+
+func (e *providerEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.provider.Embed(ctx, texts, e.model)
+}
+
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
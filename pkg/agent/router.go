@@ -0,0 +1,284 @@
+package agent
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	tierSmall = "small"
+	tierLarge = "large"
+
+	// escalateMarker is what the small model replies with when a request is
+	// beyond it.
+	escalateMarker = "[ESCALATE]"
+)
+
+// smallTierNote is appended to the system prompt of turns sent to the small
+// model so it can hand requests it is unsure about to the agent's model.
+const smallTierNote = "\n\n---\n\n# Escalation\n\n" +
+	"If answering this request well needs more reasoning, knowledge or care than you can give it, " +
+	"reply with exactly " + escalateMarker + " and nothing else; a stronger model will take over."
+
+const classifierPrompt = `You route chat messages to a language model. Reply with one word:
+SIMPLE if a small, fast model can answer the message well (greetings, thanks, small talk, quick facts, short rewording),
+COMPLEX if it needs careful reasoning, code, tools, research, planning or long writing.`
+
+// defaultComplexKeywords mark a turn as needing the agent's own model.
+var defaultComplexKeywords = []string{
+	"analyze", "analyse", "architecture", "code", "compare", "debug", "design", "explain why",
+	"fix", "implement", "optimize", "plan", "prove", "refactor", "research", "review",
+	"step by step", "summarize", "translate",
+}
+
+// toolHints are words suggesting the turn needs tools, which small models
+// tend to use poorly.
+var toolHints = []string{
+	"cron", "directory", "download", "execute", "file", "folder", "install", "look up",
+	"remind", "run", "schedule", "search", "workspace",
+}
+
+var (
+	chitchatRe = regexp.MustCompile(`(?i)^(hi|hello|hey|yo|thanks|thank you|thx|ty|ok|okay|k|yes|yep|no|nope|sure|` +
+		`great|cool|nice|awesome|perfect|got it|good (morning|afternoon|evening|night)|bye|see you|lol|haha)` +
+		`( (there|again|so much|a lot))?[\s!.?]*$`)
+	urlRe = regexp.MustCompile(`https?://`)
+)
+
+// modelTier is the model a turn is sent to, with its fallbacks.
+type modelTier struct {
+	name       string
+	model      string
+	candidates []providers.FallbackCandidate
+}
+
+// ModelRouter picks, per turn, between a small model and the agent's own.
+type ModelRouter struct {
+	small              modelTier
+	classifier         *modelTier
+	maxSimpleChars     int
+	maxSmallIterations int
+	keywords           []string
+}
+
+// newModelRouter returns the router for an agent whose own model is model,
+// or nil when routing is disabled or would always pick the same model.
+func newModelRouter(
+	cfg config.ModelRoutingConfig,
+	model, defaultProvider string,
+	modelList []config.ModelConfig,
+) *ModelRouter {
+	smallModel := strings.TrimSpace(cfg.SmallModel)
+	if !cfg.Enabled || smallModel == "" || smallModel == model {
+		return nil
+	}
+
+	r := &ModelRouter{
+		small: modelTier{
+			name:  tierSmall,
+			model: smallModel,
+			candidates: providers.ResolveModelListCandidates(providers.ModelConfig{
+				Primary:   smallModel,
+				Fallbacks: cfg.SmallModelFallbacks,
+			}, defaultProvider, modelList),
+		},
+		maxSimpleChars:     cfg.MaxSimpleChars,
+		maxSmallIterations: cfg.MaxSmallIterations,
+		keywords:           defaultComplexKeywords,
+	}
+	if r.maxSimpleChars <= 0 {
+		r.maxSimpleChars = 200
+	}
+	if r.maxSmallIterations <= 0 {
+		r.maxSmallIterations = 4
+	}
+	for _, k := range cfg.ComplexKeywords {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			r.keywords = append(r.keywords, k)
+		}
+	}
+	if classifier := strings.TrimSpace(cfg.ClassifierModel); classifier != "" {
+		r.classifier = &modelTier{
+			name:       "classifier",
+			model:      classifier,
+			candidates: providers.ResolveModelListCandidates(providers.ModelConfig{Primary: classifier}, defaultProvider, modelList),
+		}
+	}
+	return r
+}
+
+// classify applies the routing rules to a turn. decided is false when no
+// rule applies and the turn could go either way.
+func (r *ModelRouter) classify(content string, media []string, messages []providers.Message) (string, string, bool) {
+	text := strings.TrimSpace(content)
+	lower := strings.ToLower(text)
+
+	switch {
+	case len(media) > 0 || hasImages(messages[len(messages)-1:]):
+		return tierLarge, "attachments", true
+	case chitchatRe.MatchString(text):
+		return tierSmall, "chitchat", true
+	case len(text) > r.maxSimpleChars:
+		return tierLarge, "length", true
+	case strings.Contains(text, "```"):
+		return tierLarge, "code", true
+	case urlRe.MatchString(text):
+		return tierLarge, "tools:url", true
+	}
+	for _, k := range r.keywords {
+		if containsWordPrefix(lower, k) {
+			return tierLarge, "keyword:" + k, true
+		}
+	}
+	for _, k := range toolHints {
+		if containsWordPrefix(lower, k) {
+			return tierLarge, "tools:" + k, true
+		}
+	}
+	if previousTurnUsedTools(messages) {
+		return tierLarge, "follow_up", true
+	}
+	return tierSmall, "short", false
+}
+
+// containsWordPrefix reports whether s contains a word starting with word:
+// an occurrence of word not preceded by a letter. Unlike whole-word matching,
+// "debug" also matches "debugging".
+func containsWordPrefix(s, word string) bool {
+	for i := 0; ; {
+		j := strings.Index(s[i:], word)
+		if j < 0 {
+			return false
+		}
+		j += i
+		if j == 0 || !isLetter(s[j-1]) {
+			return true
+		}
+		i = j + 1
+	}
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// previousTurnUsedTools reports whether the turn before the current user
+// message called tools, which makes the new message likely a follow-up to
+// that work.
+func previousTurnUsedTools(messages []providers.Message) bool {
+	for i := len(messages) - 2; i > 0; i-- {
+		switch {
+		case messages[i].Role == "user":
+			return false
+		case messages[i].Role == "tool" || len(messages[i].ToolCalls) > 0:
+			return true
+		}
+	}
+	return false
+}
+
+// agentTier is the agent's own model and fallbacks.
+func agentTier(agent *AgentInstance) modelTier {
+	return modelTier{name: tierLarge, model: agent.CurrentModel(), candidates: agent.Candidates}
+}
+
+// routeTurn picks the tier for a turn, asking the classifier model when the
+// rules can't decide, and logs the choice.
+func (al *AgentLoop) routeTurn(
+	ctx context.Context,
+	agent *AgentInstance,
+	messages []providers.Message,
+	opts processOptions,
+) modelTier {
+	r := agent.Router
+	tier, reason, decided := r.classify(opts.UserMessage, opts.Media, messages)
+	if !decided && r.classifier != nil {
+		tier, reason = al.classifyWithModel(ctx, agent, opts.UserMessage)
+	}
+
+	chosen := agentTier(agent)
+	if tier == tierSmall {
+		chosen = r.small
+	}
+	logger.InfoCF("agent", "Model routed",
+		map[string]any{
+			"agent_id":    agent.ID,
+			"session_key": opts.SessionKey,
+			"tier":        chosen.name,
+			"model":       chosen.model,
+			"reason":      reason,
+		})
+	return chosen
+}
+
+// classifyWithModel asks the classifier model whether content is simple.
+// Anything but a clear SIMPLE goes to the large tier.
+func (al *AgentLoop) classifyWithModel(ctx context.Context, agent *AgentInstance, content string) (string, string) {
+	messages := []providers.Message{
+		{Role: "system", Content: classifierPrompt},
+		{Role: "user", Content: content},
+	}
+	options := map[string]any{"max_tokens": 8, "temperature": 0.0}
+	call := func(ctx context.Context, p providers.LLMProvider, model string) (*providers.LLMResponse, error) {
+		return p.Chat(ctx, messages, nil, model, options)
+	}
+
+	response, err := al.chatTier(ctx, agent, *agent.Router.classifier, call)
+	if err != nil {
+		logger.WarnCF("agent", "Routing classifier failed",
+			map[string]any{
+				"agent_id": agent.ID,
+				"error":    err.Error(),
+			})
+		return tierLarge, "classifier_error"
+	}
+	if strings.Contains(strings.ToUpper(response.Content), "SIMPLE") {
+		return tierSmall, "classifier"
+	}
+	return tierLarge, "classifier"
+}
+
+// chatTier sends one request to tier, trying its fallbacks in turn.
+func (al *AgentLoop) chatTier(
+	ctx context.Context,
+	agent *AgentInstance,
+	tier modelTier,
+	call func(ctx context.Context, provider providers.LLMProvider, model string) (*providers.LLMResponse, error),
+) (*providers.LLMResponse, error) {
+	chatCandidate := func(ctx context.Context, candidate providers.FallbackCandidate) (*providers.LLMResponse, error) {
+		return al.chatCandidate(ctx, agent, candidate, call)
+	}
+	if len(tier.candidates) > 1 && al.fallback != nil {
+		result, err := al.fallback.Execute(ctx, tier.candidates, chatCandidate)
+		if err != nil {
+			return nil, err
+		}
+		return result.Response, nil
+	}
+	if len(tier.candidates) == 1 && tier.candidates[0].ModelName != "" {
+		return chatCandidate(ctx, tier.candidates[0])
+	}
+	return call(ctx, agent.Provider, tier.model)
+}
+
+// repeatsToolCall reports whether calls repeats a tool call already in seen,
+// by name and arguments, and adds calls to seen.
+func repeatsToolCall(seen map[string]bool, calls []providers.ToolCall) bool {
+	repeated := false
+	for _, tc := range calls {
+		key := tc.Name
+		if tc.Function != nil {
+			key += tc.Function.Arguments
+		}
+		if seen[key] {
+			repeated = true
+		}
+		seen[key] = true
+	}
+	return repeated
+}
@@ -0,0 +1,253 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestModelRouter_Classify(t *testing.T) {
+	r := newModelRouter(config.ModelRoutingConfig{
+		Enabled:         true,
+		SmallModel:      "small-model",
+		ComplexKeywords: []string{"contract"},
+	}, "big-model", "", nil)
+	if r == nil {
+		t.Fatal("expected a router")
+	}
+
+	user := func(content string) []providers.Message {
+		return []providers.Message{{Role: "system"}, {Role: "user", Content: content}}
+	}
+	tests := []struct {
+		content string
+		media   []string
+		history []providers.Message
+		tier    string
+		reason  string
+		decided bool
+	}{
+		{content: "Thanks!", tier: tierSmall, reason: "chitchat", decided: true},
+		{content: "good morning", tier: tierSmall, reason: "chitchat", decided: true},
+		{content: "what is this?", media: []string{"a.png"}, tier: tierLarge, reason: "attachments", decided: true},
+		{content: strings.Repeat("word ", 60), tier: tierLarge, reason: "length", decided: true},
+		{content: "please refactor the parser", tier: tierLarge, reason: "keyword:refactor", decided: true},
+		{content: "check this contract", tier: tierLarge, reason: "keyword:contract", decided: true},
+		{content: "what's in notes.txt? open the file", tier: tierLarge, reason: "tools:file", decided: true},
+		{content: "see https://example.com", tier: tierLarge, reason: "tools:url", decided: true},
+		{content: "what's the capital of France?", tier: tierSmall, reason: "short", decided: false},
+		{content: "I'm fine, and you?", tier: tierSmall, reason: "short", decided: false},
+		{
+			content: "and the other one?",
+			history: []providers.Message{
+				{Role: "system"},
+				{Role: "user", Content: "list the logs"},
+				{Role: "assistant", ToolCalls: []providers.ToolCall{{Name: "list_dir"}}},
+				{Role: "tool", Content: "a.log"},
+				{Role: "assistant", Content: "There is a.log"},
+				{Role: "user", Content: "and the other one?"},
+			},
+			tier: tierLarge, reason: "follow_up", decided: true,
+		},
+	}
+	for _, tt := range tests {
+		messages := tt.history
+		if messages == nil {
+			messages = user(tt.content)
+		}
+		tier, reason, decided := r.classify(tt.content, tt.media, messages)
+		if tier != tt.tier || reason != tt.reason || decided != tt.decided {
+			t.Errorf("classify(%q) = %s, %s, %v; want %s, %s, %v",
+				tt.content, tier, reason, decided, tt.tier, tt.reason, tt.decided)
+		}
+	}
+
+	if newModelRouter(config.ModelRoutingConfig{Enabled: true, SmallModel: "big-model"}, "big-model", "", nil) != nil {
+		t.Error("expected no router when the small model is the agent's own")
+	}
+}
+
+// tieredProvider answers according to the model asked.
+type tieredProvider struct {
+	mu     sync.Mutex
+	models []string
+	answer func(model string, messages []providers.Message) (*providers.LLMResponse, error)
+}
+
+func (p *tieredProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	defs []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	p.models = append(p.models, model)
+	p.mu.Unlock()
+	return p.answer(model, messages)
+}
+
+func (p *tieredProvider) GetDefaultModel() string {
+	return "big-model"
+}
+
+func newRoutingTestLoop(t *testing.T, provider providers.LLMProvider, routing config.ModelRoutingConfig) *AgentLoop {
+	t.Helper()
+	routing.Enabled = true
+	routing.SmallModel = "small-model"
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "big-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				ModelRouting:      routing,
+			},
+		},
+	}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+}
+
+func routingTestMessage(content string) bus.InboundMessage {
+	return bus.InboundMessage{Channel: "telegram", SenderID: "user-1", ChatID: "chat-1", Content: content}
+}
+
+func TestModelRouting_EscalatesFromSmallModel(t *testing.T) {
+	provider := &tieredProvider{answer: func(model string, messages []providers.Message) (*providers.LLMResponse, error) {
+		question := messages[len(messages)-1].Content
+		switch {
+		case model == "big-model":
+			return &providers.LLMResponse{Content: "big: " + question}, nil
+		case strings.Contains(question, "hard"):
+			if !strings.Contains(messages[0].Content, escalateMarker) {
+				t.Error("expected the small model to be told how to escalate")
+			}
+			return &providers.LLMResponse{Content: escalateMarker}, nil
+		case strings.Contains(question, "broken"):
+			return nil, errors.New("small model unavailable")
+		}
+		return &providers.LLMResponse{Content: "small: " + question}, nil
+	}}
+	al := newRoutingTestLoop(t, provider, config.ModelRoutingConfig{})
+
+	tests := []struct {
+		content string
+		want    string
+		models  string
+	}{
+		{"thanks!", "small: thanks!", "small-model"},
+		{"a hard question?", "big: a hard question?", "small-model,big-model"},
+		{"a broken question?", "big: a broken question?", "small-model,big-model"},
+		{"please debug my parser", "big: please debug my parser", "big-model"},
+	}
+	for _, tt := range tests {
+		provider.mu.Lock()
+		provider.models = nil
+		provider.mu.Unlock()
+
+		got, err := al.processMessage(context.Background(), routingTestMessage(tt.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.content, got, tt.want)
+		}
+		if models := strings.Join(provider.models, ","); models != tt.models {
+			t.Errorf("%q: models = %s, want %s", tt.content, models, tt.models)
+		}
+	}
+}
+
+func TestModelRouting_EscalatesLoopingSmallModel(t *testing.T) {
+	provider := &tieredProvider{answer: func(model string, messages []providers.Message) (*providers.LLMResponse, error) {
+		if model == "big-model" {
+			return &providers.LLMResponse{Content: "done"}, nil
+		}
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call",
+			Name:      "list_dir",
+			Arguments: map[string]any{"path": "."},
+		}}}, nil
+	}}
+	al := newRoutingTestLoop(t, provider, config.ModelRoutingConfig{})
+
+	got, err := al.processMessage(context.Background(), routingTestMessage("how are things going?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "done" {
+		t.Errorf("got %q, want done", got)
+	}
+	if models := strings.Join(provider.models, ","); models != "small-model,small-model,big-model" {
+		t.Errorf("models = %s, want the small model twice, then the big one", models)
+	}
+}
+
+func TestModelRouting_KeepsSmallTierNoteAfterCompression(t *testing.T) {
+	calls := 0
+	provider := &tieredProvider{answer: func(model string, messages []providers.Message) (*providers.LLMResponse, error) {
+		if model == "big-model" {
+			return &providers.LLMResponse{Content: "big"}, nil
+		}
+		calls++
+		if !strings.Contains(messages[0].Content, escalateMarker) {
+			t.Errorf("call %d: expected the small model to be told how to escalate", calls)
+		}
+		if calls == 1 {
+			return nil, errors.New("context length exceeded")
+		}
+		return &providers.LLMResponse{Content: "small"}, nil
+	}}
+	al := newRoutingTestLoop(t, provider, config.ModelRoutingConfig{})
+
+	got, err := al.processMessage(context.Background(), routingTestMessage("thanks!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "small" {
+		t.Errorf("got %q, want the small model's answer after the retry", got)
+	}
+}
+
+func TestModelRouting_AsksClassifier(t *testing.T) {
+	provider := &tieredProvider{answer: func(model string, messages []providers.Message) (*providers.LLMResponse, error) {
+		if model == "classifier-model" {
+			if strings.Contains(messages[1].Content, "capital") {
+				return &providers.LLMResponse{Content: "SIMPLE"}, nil
+			}
+			return &providers.LLMResponse{Content: "COMPLEX"}, nil
+		}
+		return &providers.LLMResponse{Content: model}, nil
+	}}
+	al := newRoutingTestLoop(t, provider, config.ModelRoutingConfig{ClassifierModel: "classifier-model"})
+
+	for content, want := range map[string]string{
+		"what's the capital of Peru?": "small-model",
+		"is P equal to NP?":           "big-model",
+		"thanks":                      "small-model", // decided by the rules, no classifier call
+	} {
+		got, err := al.processMessage(context.Background(), routingTestMessage(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%q: answered by %s, want %s", content, got, want)
+		}
+	}
+	classifierCalls := 0
+	for _, model := range provider.models {
+		if model == "classifier-model" {
+			classifierCalls++
+		}
+	}
+	if classifierCalls != 2 {
+		t.Errorf("classifier called %d times, want 2", classifierCalls)
+	}
+}
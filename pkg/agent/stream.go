@@ -0,0 +1,295 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// defaultStreamEditInterval is used when a channel reports no edit interval.
+const defaultStreamEditInterval = time.Second
+
+// streamCursor is appended to an in-flight message while tokens are still arriving.
+const streamCursor = " ▌"
+
+// streamFinalizeTimeout bounds the edit that removes the cursor when a
+// publisher closes, which runs even if the turn was cancelled.
+const streamFinalizeTimeout = 10 * time.Second
+
+// streamPublisher shows a reply progressively on a channel that can edit
+// messages. Deltas are buffered and flushed at most once per edit interval
+// by a background goroutine, so slow platform APIs never stall the model
+// stream. On Close the message loses its cursor and its ID is handed to the
+// turn's streamedReply, so that only the turn's own final reply, sent
+// through the normal outbound path, replaces it.
+type streamPublisher struct {
+	editor   channels.MessageEditor
+	chatID   string
+	interval time.Duration
+	ctx      context.Context
+	reply    *streamedReply
+
+	mu   sync.Mutex
+	text strings.Builder
+
+	// Owned by the run goroutine.
+	messageID string
+	shown     string
+	failed    bool
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newStreamPublisher returns a running publisher for the turn, or nil when
+// streaming is disabled or either the provider or the channel lacks support.
+func (al *AgentLoop) newStreamPublisher(
+	ctx context.Context,
+	agent *AgentInstance,
+	opts processOptions,
+) *streamPublisher {
+	if !al.cfg.Agents.Defaults.Streaming || al.channelManager == nil || opts.ChatID == "" {
+		return nil
+	}
+	if constants.IsInternalChannel(opts.Channel) {
+		return nil
+	}
+	if _, ok := agent.Provider.(providers.StreamingProvider); !ok {
+		return nil
+	}
+	channel, ok := al.channelManager.GetChannel(opts.Channel)
+	if !ok {
+		return nil
+	}
+	editor, ok := channel.(channels.MessageEditor)
+	if !ok {
+		return nil
+	}
+
+	p := &streamPublisher{
+		editor:   editor,
+		chatID:   opts.ChatID,
+		interval: editor.EditInterval(),
+		ctx:      ctx,
+		reply:    streamedReplyFrom(ctx),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if p.interval <= 0 {
+		p.interval = defaultStreamEditInterval
+	}
+	go p.run(ctx)
+	return p
+}
+
+// OnDelta buffers streamed text. Tool-call fragments are not shown.
+func (p *streamPublisher) OnDelta(delta providers.StreamDelta) {
+	if delta.Content == "" {
+		return
+	}
+	p.mu.Lock()
+	p.text.WriteString(delta.Content)
+	p.mu.Unlock()
+}
+
+// Reset discards buffered text so the next LLM call replaces what is shown.
+func (p *streamPublisher) Reset() {
+	p.mu.Lock()
+	p.text.Reset()
+	p.mu.Unlock()
+}
+
+// Close stops the publisher, waits for an edit in progress to finish and
+// shows the text received so far without the cursor.
+func (p *streamPublisher) Close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+
+	if p.messageID == "" || p.failed {
+		return
+	}
+	p.mu.Lock()
+	text := p.text.String()
+	p.mu.Unlock()
+	if strings.TrimSpace(text) == "" {
+		text = p.shown
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(p.ctx), streamFinalizeTimeout)
+	defer cancel()
+	if err := p.editor.EditMessage(ctx, p.chatID, p.messageID, text); err != nil {
+		logger.WarnCF("agent", "Failed to finalize streamed message",
+			map[string]any{"chat_id": p.chatID, "error": err.Error()})
+	}
+	if p.reply != nil {
+		p.reply.set(p.messageID)
+	}
+}
+
+func (p *streamPublisher) run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.flush(ctx)
+		}
+	}
+}
+
+func (p *streamPublisher) flush(ctx context.Context) {
+	if p.failed {
+		return
+	}
+
+	p.mu.Lock()
+	text := p.text.String()
+	p.mu.Unlock()
+
+	if strings.TrimSpace(text) == "" || text == p.shown {
+		return
+	}
+
+	var err error
+	if p.messageID == "" {
+		p.messageID, err = p.editor.StartMessage(ctx, p.chatID, text+streamCursor)
+	} else {
+		err = p.editor.EditMessage(ctx, p.chatID, p.messageID, text+streamCursor)
+	}
+	if err != nil {
+		// Stop editing for the rest of the turn; the final reply is still sent normally.
+		p.failed = true
+		logger.WarnCF("agent", "Streaming edit failed, falling back to a single reply",
+			map[string]any{"chat_id": p.chatID, "error": err.Error()})
+		return
+	}
+	p.shown = text
+}
+
+// streamedReply carries the ID of the message a turn streamed its reply
+// into, so the turn's final reply can replace it. It belongs to one turn;
+// other messages sent to the chat never touch the streamed message.
+type streamedReply struct {
+	mu        sync.Mutex
+	messageID string
+}
+
+type streamedReplyKey struct{}
+
+// withStreamedReply returns a context whose turn records its streamed message.
+func withStreamedReply(ctx context.Context) (context.Context, *streamedReply) {
+	r := &streamedReply{}
+	return context.WithValue(ctx, streamedReplyKey{}, r), r
+}
+
+// streamedReplyFrom returns the turn's streamedReply, or nil if ctx has none.
+func streamedReplyFrom(ctx context.Context) *streamedReply {
+	r, _ := ctx.Value(streamedReplyKey{}).(*streamedReply)
+	return r
+}
+
+func (r *streamedReply) set(messageID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messageID = messageID
+}
+
+// take returns the streamed message ID once, so a single reply replaces it.
+func (r *streamedReply) take() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.messageID
+	r.messageID = ""
+	return id
+}
+
+// streamSink receives reply text while the model generates it.
+type streamSink interface {
+	OnDelta(delta providers.StreamDelta)
+	Reset()
+}
+
+// answerSink streams the turn's answer to an OnDelta caller, who cannot
+// take text back. Each LLM call's text is held until the call returns, and
+// passed on chunk by chunk only if the call turns out to be the answer
+// rather than text leading up to tool calls.
+type answerSink struct {
+	onDelta func(text string)
+
+	mu   sync.Mutex
+	held []string
+}
+
+// OnDelta holds streamed text. Tool-call fragments are not shown.
+func (s *answerSink) OnDelta(delta providers.StreamDelta) {
+	if delta.Content == "" {
+		return
+	}
+	s.mu.Lock()
+	s.held = append(s.held, delta.Content)
+	s.mu.Unlock()
+}
+
+// Reset drops the text held for the previous call.
+func (s *answerSink) Reset() {
+	s.mu.Lock()
+	s.held = nil
+	s.mu.Unlock()
+}
+
+// release passes on the text held for the call that gave answer, then any
+// part of answer the stream lacked: all of it when the provider does not
+// stream.
+func (s *answerSink) release(answer string) {
+	s.mu.Lock()
+	held := s.held
+	s.held = nil
+	s.mu.Unlock()
+
+	sent := strings.Join(held, "")
+	if strings.HasPrefix(answer, sent) {
+		for _, text := range held {
+			s.onDelta(text)
+		}
+	} else {
+		sent = ""
+	}
+	if rest := answer[len(sent):]; rest != "" {
+		s.onDelta(rest)
+	}
+}
+
+// chat calls the provider, streaming the reply through stream when both
+// the provider and the turn support it.
+func chat(
+	ctx context.Context,
+	provider providers.LLMProvider,
+	stream streamSink,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	if sp, ok := provider.(providers.StreamingProvider); ok && stream != nil {
+		stream.Reset()
+		return sp.ChatStream(ctx, messages, tools, model, options, stream.OnDelta)
+	}
+	return provider.Chat(ctx, messages, tools, model, options)
+}
@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// editorChannel is a channel that records in-flight message edits
+type editorChannel struct {
+	mu     sync.Mutex
+	starts []string
+	edits  []string
+}
+
+func (c *editorChannel) Name() string                                            { return "editor" }
+func (c *editorChannel) Start(ctx context.Context) error                         { return nil }
+func (c *editorChannel) Stop(ctx context.Context) error                          { return nil }
+func (c *editorChannel) Send(ctx context.Context, msg bus.OutboundMessage) error { return nil }
+func (c *editorChannel) IsRunning() bool                                         { return true }
+func (c *editorChannel) IsAllowed(senderID string) bool                          { return true }
+func (c *editorChannel) EditInterval() time.Duration                             { return 5 * time.Millisecond }
+
+func (c *editorChannel) StartMessage(ctx context.Context, chatID, content string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.starts = append(c.starts, content)
+	return fmt.Sprintf("msg-%d", len(c.starts)), nil
+}
+
+func (c *editorChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.edits = append(c.edits, content)
+	return nil
+}
+
+// streamingMockProvider streams its response word by word
+type streamingMockProvider struct {
+	response string
+	streamed bool
+}
+
+func (m *streamingMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{Content: m.response}, nil
+}
+
+func (m *streamingMockProvider) ChatStream(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+	onDelta func(providers.StreamDelta),
+) (*providers.LLMResponse, error) {
+	m.streamed = true
+	for _, word := range strings.SplitAfter(m.response, " ") {
+		onDelta(providers.StreamDelta{Content: word})
+		time.Sleep(15 * time.Millisecond)
+	}
+	return &providers.LLMResponse{Content: m.response}, nil
+}
+
+func (m *streamingMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func newStreamingTestLoop(t *testing.T, streaming bool, provider providers.LLMProvider) (*AgentLoop, *editorChannel) {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "agent-test-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Streaming:         streaming,
+			},
+		},
+	}
+
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, provider)
+	cm, err := channels.NewManager(cfg, msgBus)
+	if err != nil {
+		t.Fatalf("Failed to create channel manager: %v", err)
+	}
+	ch := &editorChannel{}
+	cm.RegisterChannel("editor", ch)
+	al.SetChannelManager(cm)
+	return al, ch
+}
+
+func TestStreaming_EditsInFlightMessage(t *testing.T) {
+	provider := &streamingMockProvider{response: "one two three four five"}
+	al, ch := newStreamingTestLoop(t, true, provider)
+
+	helper := testHelper{al: al}
+	response := helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "editor",
+		SenderID: "user-1",
+		ChatID:   "chat-1",
+		Content:  "count",
+	})
+
+	if response != "one two three four five" {
+		t.Errorf("Expected full response, got %q", response)
+	}
+	if !provider.streamed {
+		t.Fatal("Expected provider to be called through ChatStream")
+	}
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if len(ch.starts) != 1 {
+		t.Fatalf("Expected one in-flight message, got %d", len(ch.starts))
+	}
+	if len(ch.edits) == 0 {
+		t.Fatal("Expected progressive edits of the in-flight message")
+	}
+	inFlight := append(ch.starts, ch.edits[:len(ch.edits)-1]...)
+	for _, text := range inFlight {
+		if !strings.HasSuffix(text, streamCursor) {
+			t.Errorf("Expected in-flight text to end with the cursor, got %q", text)
+		}
+	}
+	if last := ch.edits[len(ch.edits)-1]; last != "one two three four five" {
+		t.Errorf("Expected the last edit to show the text without the cursor, got %q", last)
+	}
+}
+
+func TestStreaming_FinalReplyReplacesOnlyItsOwnMessage(t *testing.T) {
+	provider := &streamingMockProvider{response: "one two three four five"}
+	al, _ := newStreamingTestLoop(t, true, provider)
+
+	ctx, cancel := context.WithTimeout(context.Background(), responseTimeout)
+	defer cancel()
+	al.handleInbound(ctx, al.routeMessage(bus.InboundMessage{
+		Channel:  "editor",
+		SenderID: "user-1",
+		ChatID:   "chat-1",
+		Content:  "count",
+	}))
+
+	reply, ok := al.bus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("Expected the final reply")
+	}
+	if reply.Content != "one two three four five" || reply.ReplaceMessageID != "msg-1" {
+		t.Errorf("Expected the reply to replace msg-1, got %+v", reply)
+	}
+
+	// A later turn in the chat streams into, and replaces, its own message.
+	al.handleInbound(ctx, al.routeMessage(bus.InboundMessage{
+		Channel:  "system",
+		SenderID: "subagent:subagent-1",
+		ChatID:   "editor:chat-1",
+		Content:  "Task 'x' completed.",
+	}))
+	reply, ok = al.bus.SubscribeOutbound(ctx)
+	if !ok || reply.ReplaceMessageID != "msg-2" {
+		t.Errorf("Expected the later reply to replace msg-2, got %+v", reply)
+	}
+}
+
+func TestStreaming_DisabledUsesChat(t *testing.T) {
+	provider := &streamingMockProvider{response: "hello there"}
+	al, ch := newStreamingTestLoop(t, false, provider)
+
+	helper := testHelper{al: al}
+	helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "editor",
+		SenderID: "user-1",
+		ChatID:   "chat-1",
+		Content:  "hi",
+	})
+
+	if provider.streamed {
+		t.Error("Expected Chat to be used when streaming is disabled")
+	}
+	if len(ch.starts) != 0 {
+		t.Errorf("Expected no in-flight message, got %v", ch.starts)
+	}
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// registerSubagentTools gives every agent spawn and tasks tools, and a delegate
+// tool when it may call other agents. Subagents run as the agent they are
+// spawned for, with its model, fallbacks, workspace, prompt and tools, and may
+// only nest as deep as max_subagent_depth allows. Each agent's task records
+// are kept in its workspace under subagents/.
+func (al *AgentLoop) registerSubagentTools() {
+	maxDepth := al.cfg.Agents.Defaults.MaxSubagentDepth
+	if maxDepth <= 0 {
+		maxDepth = tools.DefaultMaxSubagentDepth
+	}
+
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+
+		subagentManager := tools.NewSubagentManager(agent.Provider, agent.Model, agent.Workspace, al.bus)
+		subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
+		subagentManager.SetMaxDepth(maxDepth)
+		subagentManager.SetResolver(func(targetAgentID string, depth int) (*tools.SubagentProfile, error) {
+			return al.subagentProfile(agent, targetAgentID, depth, maxDepth)
+		})
+		storePath := filepath.Join(agent.Workspace, "subagents", agentID+".json")
+		if err := subagentManager.SetStorePath(storePath); err != nil {
+			logger.WarnCF("agent", "Failed to load subagent tasks",
+				map[string]any{
+					"agent_id": agentID,
+					"error":    err.Error(),
+				})
+		}
+		agent.SubagentManager = subagentManager
+
+		spawnTool := tools.NewSpawnTool(subagentManager)
+		currentAgentID := agentID
+		spawnTool.SetAllowlistChecker(func(targetAgentID string) bool {
+			return al.registry.CanSpawnSubagent(currentAgentID, targetAgentID)
+		})
+		agent.Tools.Register(spawnTool)
+		agent.Tools.Register(tools.NewTasksTool(subagentManager))
+
+		targets := make(map[string]string)
+		for _, targetID := range al.registry.ListAgentIDs() {
+			if targetID == agentID || !al.registry.CanSpawnSubagent(agentID, targetID) {
+				continue
+			}
+			if target, ok := al.registry.GetAgent(targetID); ok {
+				targets[targetID] = target.Name
+			}
+		}
+		if len(targets) > 0 {
+			agent.Tools.Register(tools.NewDelegateTool(subagentManager, agentID, targets))
+		}
+	}
+}
+
+// subagentProfile resolves what a subagent spawned by parent for
+// targetAgentID runs as. Its tools are the target's, limited to the parent's
+// subagents.tools list when set, and without spawn or delegate once depth
+// reaches maxDepth.
+func (al *AgentLoop) subagentProfile(
+	parent *AgentInstance,
+	targetAgentID string,
+	depth, maxDepth int,
+) (*tools.SubagentProfile, error) {
+	target := parent
+	if targetAgentID != "" {
+		var ok bool
+		if target, ok = al.registry.GetAgent(targetAgentID); !ok {
+			return nil, fmt.Errorf("agent %q not found", targetAgentID)
+		}
+	}
+
+	var allowed []string
+	if parent.Subagents != nil {
+		allowed = parent.Subagents.Tools
+	}
+	registry := target.Tools.Filter(func(name string) bool {
+		if (name == "spawn" || name == "delegate") && depth >= maxDepth {
+			return false
+		}
+		return len(allowed) == 0 || slices.Contains(allowed, name)
+	})
+
+	return &tools.SubagentProfile{
+		Provider:      target.Provider,
+		Model:         target.CurrentModel(),
+		Chat:          al.subagentChat(target),
+		SystemPrompt:  target.ContextBuilder.BuildSubagentPrompt(registry),
+		Tools:         registry,
+		MaxIterations: target.MaxIterations,
+		LLMOptions: map[string]any{
+			"max_tokens":  target.MaxTokens,
+			"temperature": target.Temperature,
+		},
+	}, nil
+}
+
+// subagentChat sends subagent requests through agent's fallback candidates,
+// the same way the agent's own turns are sent, without streaming.
+func (al *AgentLoop) subagentChat(agent *AgentInstance) tools.ChatFunc {
+	return func(
+		ctx context.Context,
+		messages []providers.Message,
+		defs []providers.ToolDefinition,
+		model string,
+		options map[string]any,
+	) (*providers.LLMResponse, error) {
+		call := func(ctx context.Context, p providers.LLMProvider, model string) (*providers.LLMResponse, error) {
+			return p.Chat(ctx, messages, defs, model, options)
+		}
+		return al.chatTier(ctx, agent, agentTier(agent), call)
+	}
+}
@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// recordingProvider answers every request and remembers the ones it saw.
+type recordingProvider struct {
+	mu    sync.Mutex
+	calls []recordedCall
+	seen  chan struct{}
+}
+
+type recordedCall struct {
+	model  string
+	system string
+	tools  []string
+}
+
+func (p *recordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	defs []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	call := recordedCall{model: model, system: messages[0].Content}
+	for _, def := range defs {
+		call.tools = append(call.tools, def.Function.Name)
+	}
+	p.mu.Lock()
+	p.calls = append(p.calls, call)
+	p.mu.Unlock()
+	select {
+	case p.seen <- struct{}{}:
+	default:
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (p *recordingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func newSubagentTestLoop(t *testing.T, provider providers.LLMProvider) *AgentLoop {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Agents.List = []config.AgentConfig{
+		{
+			ID:      "main",
+			Default: true,
+			Subagents: &config.SubagentsConfig{
+				AllowAgents: []string{"coder"},
+				Tools:       []string{"read_file", "spawn"},
+			},
+		},
+		{
+			ID:        "coder",
+			Workspace: t.TempDir(),
+			Model:     &config.AgentModelConfig{Primary: "coder-model"},
+		},
+	}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+}
+
+func TestSubagentProfile_RunsAsTargetAgent(t *testing.T) {
+	al := newSubagentTestLoop(t, &mockProvider{})
+	main, _ := al.registry.GetAgent("main")
+	coder, _ := al.registry.GetAgent("coder")
+
+	profile, err := al.subagentProfile(main, "coder", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Model != "coder-model" {
+		t.Errorf("Model = %q, want coder-model", profile.Model)
+	}
+	if got := profile.Tools.List(); len(got) != 1 || got[0] != "read_file" {
+		t.Errorf("expected only read_file at the depth limit, got %v", got)
+	}
+	if !strings.Contains(profile.SystemPrompt, coder.Workspace) || !strings.Contains(profile.SystemPrompt, "# Subagent") {
+		t.Errorf("expected the coder's subagent prompt, got:\n%s", profile.SystemPrompt)
+	}
+	if _, ok := coder.Tools.Get("exec"); !ok {
+		t.Error("filtering must not change the target agent's own tools")
+	}
+
+	profile, err = al.subagentProfile(main, "", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := profile.Tools.Get("spawn"); !ok {
+		t.Error("expected spawn to stay available below the depth limit")
+	}
+
+	if _, err := al.subagentProfile(main, "ghost", 1, 1); err == nil {
+		t.Error("expected an unknown agent to be rejected")
+	}
+}
+
+func TestSpawn_UsesTargetAgent(t *testing.T) {
+	provider := &recordingProvider{seen: make(chan struct{}, 1)}
+	al := newSubagentTestLoop(t, provider)
+	main, _ := al.registry.GetAgent("main")
+
+	result := main.Tools.Execute(context.Background(), "spawn", map[string]any{
+		"task":     "refactor the parser",
+		"agent_id": "coder",
+	})
+	if result.IsError {
+		t.Fatalf("spawn failed: %s", result.ForLLM)
+	}
+
+	select {
+	case <-provider.seen:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subagent never called the provider")
+	}
+	provider.mu.Lock()
+	call := provider.calls[0]
+	provider.mu.Unlock()
+	if call.model != "coder-model" {
+		t.Errorf("model = %q, want coder-model", call.model)
+	}
+	if strings.Join(call.tools, ",") != "read_file" {
+		t.Errorf("tools = %v, want [read_file]", call.tools)
+	}
+}
+
+func TestTasksCommand(t *testing.T) {
+	provider := &recordingProvider{seen: make(chan struct{}, 1)}
+	al := newSubagentTestLoop(t, provider)
+	main, _ := al.registry.GetAgent("main")
+
+	result := main.Tools.Execute(context.Background(), "spawn", map[string]any{
+		"task":     "summarize the logs",
+		"label":    "logs",
+		"agent_id": "coder",
+	})
+	if result.IsError {
+		t.Fatalf("spawn failed: %s", result.ForLLM)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if task, ok := main.SubagentManager.GetTask("subagent-1"); ok && task.Status == "completed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subagent did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	msg := bus.InboundMessage{Channel: "cli", ChatID: "direct", Content: "/tasks"}
+	if got, _ := al.handleCommand(context.Background(), msg); !strings.Contains(got, "subagent-1 [completed") {
+		t.Errorf("unexpected /tasks output:\n%s", got)
+	}
+	msg.Content = "/tasks subagent-1"
+	if got, _ := al.handleCommand(context.Background(), msg); !strings.Contains(got, "Result:\ndone") {
+		t.Errorf("unexpected /tasks subagent-1 output:\n%s", got)
+	}
+	msg.Content = "/tasks cancel subagent-1"
+	if got, _ := al.handleCommand(context.Background(), msg); !strings.Contains(got, "not running") {
+		t.Errorf("unexpected /tasks cancel output:\n%s", got)
+	}
+	msg.ChatID = "other"
+	msg.Content = "/tasks subagent-1"
+	if got, _ := al.handleCommand(context.Background(), msg); !strings.Contains(got, "not found") {
+		t.Errorf("expected another chat's task to be hidden, got:\n%s", got)
+	}
+}
+
+func TestDelegateTool_RegisteredForAllowedAgents(t *testing.T) {
+	al := newSubagentTestLoop(t, &mockProvider{})
+	main, _ := al.registry.GetAgent("main")
+	coder, _ := al.registry.GetAgent("coder")
+
+	tool, ok := main.Tools.Get("delegate")
+	if !ok {
+		t.Fatal("expected main to get a delegate tool")
+	}
+	if !strings.Contains(tool.Description(), "Available agents: coder.") {
+		t.Errorf("unexpected description: %s", tool.Description())
+	}
+	if _, ok := coder.Tools.Get("delegate"); ok {
+		t.Error("expected no delegate tool for an agent that may not call others")
+	}
+
+	main.Subagents.Tools = nil
+	profile, err := al.subagentProfile(main, "", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := profile.Tools.Get("delegate"); ok {
+		t.Error("expected delegate to be removed at the depth limit")
+	}
+}
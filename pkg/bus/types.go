@@ -14,6 +14,9 @@ type OutboundMessage struct {
 	Channel string `json:"channel"`
 	ChatID  string `json:"chat_id"`
 	Content string `json:"content"`
+	// ReplaceMessageID is the message the reply was streamed into, which
+	// channels edit to show the reply instead of sending a new message.
+	ReplaceMessageID string `json:"replace_message_id,omitempty"`
 }
 
 type MessageHandler func(InboundMessage) error
@@ -2,9 +2,10 @@ package channels
 
 import (
 	"context"
-	"strings"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/routing"
 )
 
 type Channel interface {
@@ -16,6 +17,26 @@ type Channel interface {
 	IsAllowed(senderID string) bool
 }
 
+// MessageEditor is implemented by channels that can update a message after
+// sending it. The agent uses it to show a reply while it is being generated;
+// the final reply then arrives through Send with ReplaceMessageID set to the
+// in-flight message, which Send edits instead of sending a new message.
+type MessageEditor interface {
+	// StartMessage posts the first text of an in-flight reply and returns its message ID.
+	StartMessage(ctx context.Context, chatID, content string) (string, error)
+	// EditMessage replaces the text of a message returned by StartMessage.
+	EditMessage(ctx context.Context, chatID, messageID, content string) error
+	// EditInterval is the minimum delay between two edits of the same message.
+	EditInterval() time.Duration
+}
+
+// ApprovalPrompter is implemented by channels that can show an approval
+// request with buttons. Pressing one delivers "/approve <id>" or
+// "/reject <id>" from the pressing user, as if they had typed it.
+type ApprovalPrompter interface {
+	SendApprovalPrompt(ctx context.Context, chatID, approvalID, text string) error
+}
+
 type BaseChannel struct {
 	config    any
 	bus       *bus.MessageBus
@@ -47,33 +68,10 @@ func (c *BaseChannel) IsAllowed(senderID string) bool {
 		return true
 	}
 
-	// Extract parts from compound senderID like "123456|username"
-	idPart := senderID
-	userPart := ""
-	if idx := strings.Index(senderID, "|"); idx > 0 {
-		idPart = senderID[:idx]
-		userPart = senderID[idx+1:]
-	}
-
+	// Either side may use the compound "id|username" form, which keeps
+	// legacy Telegram allowlist entries working.
 	for _, allowed := range c.allowList {
-		// Strip leading "@" from allowed value for username matching
-		trimmed := strings.TrimPrefix(allowed, "@")
-		allowedID := trimmed
-		allowedUser := ""
-		if idx := strings.Index(trimmed, "|"); idx > 0 {
-			allowedID = trimmed[:idx]
-			allowedUser = trimmed[idx+1:]
-		}
-
-		// Support either side using "id|username" compound form.
-		// This keeps backward compatibility with legacy Telegram allowlist entries.
-		if senderID == allowed ||
-			idPart == allowed ||
-			senderID == trimmed ||
-			idPart == trimmed ||
-			idPart == allowedID ||
-			(allowedUser != "" && senderID == allowedUser) ||
-			(userPart != "" && (userPart == allowed || userPart == trimmed || userPart == allowedUser)) {
+		if routing.SenderMatches(senderID, allowed) {
 			return true
 		}
 	}
@@ -20,6 +20,7 @@ import (
 const (
 	transcriptionTimeout = 30 * time.Second
 	sendTimeout          = 10 * time.Second
+	discordMaxMessageLen = 2000
 )
 
 type DiscordChannel struct {
@@ -126,7 +127,16 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 		return nil
 	}
 
-	chunks := utils.SplitMessage(msg.Content, 2000) // Split messages into chunks, Discord length limit: 2000 chars
+	chunks := utils.SplitMessage(msg.Content, discordMaxMessageLen) // Split messages into chunks, Discord length limit: 2000 chars
+
+	// Replace the message the reply was streamed into with its first chunk.
+	if msg.ReplaceMessageID != "" {
+		if err := c.EditMessage(ctx, channelID, msg.ReplaceMessageID, chunks[0]); err == nil {
+			chunks = chunks[1:]
+		} else {
+			logger.WarnCF("discord", "Failed to finalize streamed message", map[string]any{"error": err.Error()})
+		}
+	}
 
 	for _, chunk := range chunks {
 		if err := c.sendChunk(ctx, channelID, chunk); err != nil {
@@ -159,6 +169,35 @@ func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content strin
 	}
 }
 
+func (c *DiscordChannel) StartMessage(ctx context.Context, chatID, content string) (string, error) {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	m, err := c.session.ChannelMessageSend(chatID, utils.Truncate(content, discordMaxMessageLen),
+		discordgo.WithContext(sendCtx))
+	if err != nil {
+		return "", fmt.Errorf("failed to send discord message: %w", err)
+	}
+	return m.ID, nil
+}
+
+func (c *DiscordChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	_, err := c.session.ChannelMessageEdit(chatID, messageID, utils.Truncate(content, discordMaxMessageLen),
+		discordgo.WithContext(sendCtx))
+	if err != nil {
+		return fmt.Errorf("failed to edit discord message: %w", err)
+	}
+	return nil
+}
+
+// EditInterval stays within Discord's limit of 5 edits per 5 seconds per channel.
+func (c *DiscordChannel) EditInterval() time.Duration {
+	return 1200 * time.Millisecond
+}
+
 // appendContent safely appends content to existing text
 func appendContent(content, suffix string) string {
 	if content == "" {
@@ -304,6 +343,9 @@ func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.Messag
 		"peer_kind":    peerKind,
 		"peer_id":      peerID,
 	}
+	if m.Member != nil && len(m.Member.Roles) > 0 {
+		metadata["sender_roles"] = strings.Join(m.Member.Roles, ",")
+	}
 
 	c.HandleMessage(senderID, m.ChannelID, content, mediaPaths, metadata)
 }
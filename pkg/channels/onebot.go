@@ -642,7 +642,10 @@ func (c *OneBotChannel) parseMessageSegments(raw json.RawMessage, selfID int64)
 					})
 					if localPath != "" {
 						media = append(media, localPath)
-						localFiles = append(localFiles, localPath)
+						// Images are read by the agent after this handler returns; it removes them when done.
+						if segType != "image" {
+							localFiles = append(localFiles, localPath)
+						}
 						textParts = append(textParts, fmt.Sprintf("[%s]", segType))
 					}
 				}
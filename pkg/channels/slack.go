@@ -119,6 +119,16 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("invalid slack chat ID: %s", msg.ChatID)
 	}
 
+	// Replace the message the reply was streamed into.
+	if msg.ReplaceMessageID != "" {
+		if err := c.EditMessage(ctx, msg.ChatID, msg.ReplaceMessageID, msg.Content); err != nil {
+			logger.WarnCF("slack", "Failed to finalize streamed message", map[string]any{"error": err.Error()})
+		} else {
+			c.ackMessage(msg.ChatID)
+			return nil
+		}
+	}
+
 	opts := []slack.MsgOption{
 		slack.MsgOptionText(msg.Content, false),
 	}
@@ -132,22 +142,63 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("failed to send slack message: %w", err)
 	}
 
-	if ref, ok := c.pendingAcks.LoadAndDelete(msg.ChatID); ok {
+	c.ackMessage(msg.ChatID)
+
+	logger.DebugCF("slack", "Message sent", map[string]any{
+		"channel_id": channelID,
+		"thread_ts":  threadTS,
+	})
+
+	return nil
+}
+
+// ackMessage marks the user's message as answered.
+func (c *SlackChannel) ackMessage(chatID string) {
+	if ref, ok := c.pendingAcks.LoadAndDelete(chatID); ok {
 		msgRef := ref.(slackMessageRef)
 		c.api.AddReaction("white_check_mark", slack.ItemRef{
 			Channel:   msgRef.ChannelID,
 			Timestamp: msgRef.Timestamp,
 		})
 	}
+}
 
-	logger.DebugCF("slack", "Message sent", map[string]any{
-		"channel_id": channelID,
-		"thread_ts":  threadTS,
-	})
+func (c *SlackChannel) StartMessage(ctx context.Context, chatID, content string) (string, error) {
+	channelID, threadTS := parseSlackChatID(chatID)
+	if channelID == "" {
+		return "", fmt.Errorf("invalid slack chat ID: %s", chatID)
+	}
 
+	opts := []slack.MsgOption{slack.MsgOptionText(content, false)}
+	if threadTS != "" {
+		opts = append(opts, slack.MsgOptionTS(threadTS))
+	}
+
+	_, ts, err := c.api.PostMessageContext(ctx, channelID, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to send slack message: %w", err)
+	}
+	return ts, nil
+}
+
+func (c *SlackChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
+	channelID, _ := parseSlackChatID(chatID)
+	if channelID == "" {
+		return fmt.Errorf("invalid slack chat ID: %s", chatID)
+	}
+
+	_, _, _, err := c.api.UpdateMessageContext(ctx, channelID, messageID, slack.MsgOptionText(content, false))
+	if err != nil {
+		return fmt.Errorf("failed to update slack message: %w", err)
+	}
 	return nil
 }
 
+// EditInterval keeps chat.update calls within Slack's tier 3 rate limit.
+func (c *SlackChannel) EditInterval() time.Duration {
+	return 1500 * time.Millisecond
+}
+
 func (c *SlackChannel) eventLoop() {
 	for {
 		select {
@@ -163,14 +214,102 @@ func (c *SlackChannel) eventLoop() {
 			case socketmode.EventTypeSlashCommand:
 				c.handleSlashCommand(event)
 			case socketmode.EventTypeInteractive:
-				if event.Request != nil {
-					c.socketClient.Ack(*event.Request)
-				}
+				c.handleInteractive(event)
 			}
 		}
 	}
 }
 
+// Action IDs of the approval prompt buttons. The action block's ID carries
+// the chat ID the request came from, so the answer is routed back to it.
+const (
+	slackApproveActionID = "approval_approve"
+	slackRejectActionID  = "approval_reject"
+)
+
+// SendApprovalPrompt posts text with Approve and Reject buttons.
+func (c *SlackChannel) SendApprovalPrompt(ctx context.Context, chatID, approvalID, text string) error {
+	channelID, threadTS := parseSlackChatID(chatID)
+	if channelID == "" {
+		return fmt.Errorf("invalid slack chat ID: %s", chatID)
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil, nil),
+		slack.NewActionBlock(chatID,
+			slack.NewButtonBlockElement(slackApproveActionID, approvalID,
+				slack.NewTextBlockObject(slack.PlainTextType, "Approve", false, false)).WithStyle(slack.StylePrimary),
+			slack.NewButtonBlockElement(slackRejectActionID, approvalID,
+				slack.NewTextBlockObject(slack.PlainTextType, "Reject", false, false)).WithStyle(slack.StyleDanger),
+		),
+	}
+	opts := []slack.MsgOption{slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...)}
+	if threadTS != "" {
+		opts = append(opts, slack.MsgOptionTS(threadTS))
+	}
+	if _, _, err := c.api.PostMessageContext(ctx, channelID, opts...); err != nil {
+		return fmt.Errorf("failed to send slack approval prompt: %w", err)
+	}
+	return nil
+}
+
+// handleInteractive turns a pressed approval button into an "/approve <id>"
+// or "/reject <id>" message and replaces the buttons with the answer.
+func (c *SlackChannel) handleInteractive(event socketmode.Event) {
+	if event.Request != nil {
+		c.socketClient.Ack(*event.Request)
+	}
+
+	callback, ok := event.Data.(slack.InteractionCallback)
+	if !ok || callback.Type != slack.InteractionTypeBlockActions {
+		return
+	}
+	if !c.IsAllowed(callback.User.ID) {
+		logger.DebugCF("slack", "Approval rejected by allowlist", map[string]any{
+			"user_id": callback.User.ID,
+		})
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		var verb, outcome string
+		switch action.ActionID {
+		case slackApproveActionID:
+			verb, outcome = "approve", "Approved"
+		case slackRejectActionID:
+			verb, outcome = "reject", "Rejected"
+		default:
+			continue
+		}
+		chatID := action.BlockID
+		channelID, _ := parseSlackChatID(chatID)
+
+		text := fmt.Sprintf("%s\n%s by <@%s>", callback.Message.Text, outcome, callback.User.ID)
+		if _, _, _, err := c.api.UpdateMessageContext(c.ctx, channelID, callback.Container.MessageTs,
+			slack.MsgOptionText(text, false),
+			slack.MsgOptionBlocks(slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil)),
+		); err != nil {
+			logger.DebugCF("slack", "Failed to update approval prompt", map[string]any{"error": err.Error()})
+		}
+
+		peerKind := "channel"
+		peerID := channelID
+		if strings.HasPrefix(channelID, "D") {
+			peerKind = "direct"
+			peerID = callback.User.ID
+		}
+		metadata := map[string]string{
+			"channel_id": channelID,
+			"platform":   "slack",
+			"peer_kind":  peerKind,
+			"peer_id":    peerID,
+			"team_id":    c.teamID,
+		}
+		c.HandleMessage(callback.User.ID, chatID, fmt.Sprintf("/%s %s", verb, action.Value), nil, metadata)
+	}
+}
+
 func (c *SlackChannel) handleEventsAPI(event socketmode.Event) {
 	if event.Request != nil {
 		c.socketClient.Ack(*event.Request)
@@ -252,7 +391,10 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 			if localPath == "" {
 				continue
 			}
-			localFiles = append(localFiles, localPath)
+			// Images are read by the agent after this handler returns; it removes them when done.
+			if !utils.IsImageFile(file.Name, file.Mimetype) {
+				localFiles = append(localFiles, localPath)
+			}
 			mediaPaths = append(mediaPaths, localPath)
 
 			if utils.IsAudioFile(file.Name, file.Mimetype) && c.transcriber != nil && c.transcriber.IsAvailable() {
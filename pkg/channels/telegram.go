@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +24,9 @@ import (
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
+// telegramMaxMessageLength is Telegram's limit on the text of a single message.
+const telegramMaxMessageLength = 4096
+
 type TelegramChannel struct {
 	*BaseChannel
 	bot          *telego.Bot
@@ -121,6 +125,10 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return c.commands.List(ctx, message)
 	}, th.CommandEqual("list"))
 
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleApprovalCallback(ctx, query)
+	}, th.CallbackDataPrefix(approvalCallbackPrefix))
+
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
@@ -166,6 +174,18 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 
 	htmlContent := markdownToTelegramHTML(msg.Content)
 
+	// Replace the message the reply was streamed into
+	if msg.ReplaceMessageID != "" {
+		if mID, err := strconv.Atoi(msg.ReplaceMessageID); err == nil {
+			editMsg := tu.EditMessageText(tu.ID(chatID), mID, htmlContent)
+			editMsg.ParseMode = telego.ModeHTML
+			if _, err = c.bot.EditMessageText(ctx, editMsg); err == nil {
+				return nil
+			}
+		}
+		// Fallback to new message if edit fails
+	}
+
 	// Try to edit placeholder
 	if pID, ok := c.placeholders.Load(msg.ChatID); ok {
 		c.placeholders.Delete(msg.ChatID)
@@ -193,6 +213,109 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	return nil
 }
 
+// StartMessage reuses the "Thinking..." placeholder as the in-flight message
+// when there is one. The placeholder then belongs to the streaming turn, so
+// other messages sent to the chat no longer replace it.
+func (c *TelegramChannel) StartMessage(ctx context.Context, chatID, content string) (string, error) {
+	if pID, ok := c.placeholders.LoadAndDelete(chatID); ok {
+		messageID := strconv.Itoa(pID.(int))
+		return messageID, c.EditMessage(ctx, chatID, messageID, content)
+	}
+
+	id, err := parseChatID(chatID)
+	if err != nil {
+		return "", fmt.Errorf("invalid chat ID: %w", err)
+	}
+	pMsg, err := c.bot.SendMessage(ctx, tu.Message(tu.ID(id), utils.Truncate(content, telegramMaxMessageLength)))
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(pMsg.MessageID), nil
+}
+
+// EditMessage updates an in-flight message with plain text; partial markdown
+// is only converted to HTML once the final reply arrives through Send.
+func (c *TelegramChannel) EditMessage(ctx context.Context, chatID, messageID, content string) error {
+	id, err := parseChatID(chatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID: %w", err)
+	}
+	mID, err := strconv.Atoi(messageID)
+	if err != nil {
+		return fmt.Errorf("invalid message ID: %w", err)
+	}
+	_, err = c.bot.EditMessageText(ctx, tu.EditMessageText(tu.ID(id), mID, utils.Truncate(content, telegramMaxMessageLength)))
+	return err
+}
+
+// EditInterval keeps edits under Telegram's per-chat rate limit.
+func (c *TelegramChannel) EditInterval() time.Duration {
+	return time.Second
+}
+
+// approvalCallbackPrefix marks the callback data of approval buttons, which
+// is followed by "approve:<id>" or "reject:<id>".
+const approvalCallbackPrefix = "approval:"
+
+// SendApprovalPrompt posts text with Approve and Reject buttons.
+func (c *TelegramChannel) SendApprovalPrompt(ctx context.Context, chatID, approvalID, text string) error {
+	id, err := parseChatID(chatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID: %w", err)
+	}
+	keyboard := tu.InlineKeyboard(tu.InlineKeyboardRow(
+		tu.InlineKeyboardButton("✅ Approve").WithCallbackData(approvalCallbackPrefix+"approve:"+approvalID),
+		tu.InlineKeyboardButton("❌ Reject").WithCallbackData(approvalCallbackPrefix+"reject:"+approvalID),
+	))
+	msg := tu.Message(tu.ID(id), utils.Truncate(text, telegramMaxMessageLength)).WithReplyMarkup(keyboard)
+	_, err = c.bot.SendMessage(ctx, msg)
+	return err
+}
+
+// handleApprovalCallback turns a pressed approval button into an
+// "/approve <id>" or "/reject <id>" message and removes the buttons.
+func (c *TelegramChannel) handleApprovalCallback(ctx context.Context, query telego.CallbackQuery) error {
+	senderID := fmt.Sprintf("%d", query.From.ID)
+	if query.From.Username != "" {
+		senderID = fmt.Sprintf("%d|%s", query.From.ID, query.From.Username)
+	}
+	answer := tu.CallbackQuery(query.ID)
+	if !c.IsAllowed(senderID) || query.Message == nil {
+		c.bot.AnswerCallbackQuery(ctx, answer.WithText("You are not allowed to answer this request."))
+		return nil
+	}
+
+	c.bot.AnswerCallbackQuery(ctx, answer)
+
+	verb, approvalID, ok := strings.Cut(strings.TrimPrefix(query.Data, approvalCallbackPrefix), ":")
+	if !ok || (verb != "approve" && verb != "reject") {
+		return nil
+	}
+
+	chat := query.Message.GetChat()
+	if _, err := c.bot.EditMessageReplyMarkup(ctx,
+		tu.EditMessageReplyMarkup(tu.ID(chat.ID), query.Message.GetMessageID(), nil)); err != nil {
+		logger.DebugCF("telegram", "Failed to remove approval buttons", map[string]any{"error": err.Error()})
+	}
+
+	peerKind := "direct"
+	peerID := fmt.Sprintf("%d", query.From.ID)
+	if chat.Type != "private" {
+		peerKind = "group"
+		peerID = fmt.Sprintf("%d", chat.ID)
+	}
+	metadata := map[string]string{
+		"user_id":   fmt.Sprintf("%d", query.From.ID),
+		"username":  query.From.Username,
+		"is_group":  fmt.Sprintf("%t", chat.Type != "private"),
+		"peer_kind": peerKind,
+		"peer_id":   peerID,
+	}
+	c.HandleMessage(fmt.Sprintf("%d", query.From.ID), fmt.Sprintf("%d", chat.ID),
+		fmt.Sprintf("/%s %s", verb, approvalID), nil, metadata)
+	return nil
+}
+
 func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Message) error {
 	if message == nil {
 		return fmt.Errorf("message is nil")
@@ -250,7 +373,7 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		photo := message.Photo[len(message.Photo)-1]
 		photoPath := c.downloadPhoto(ctx, photo.FileID)
 		if photoPath != "" {
-			localFiles = append(localFiles, photoPath)
+			// The agent reads the photo after this handler returns and removes it when done.
 			mediaPaths = append(mediaPaths, photoPath)
 			if content != "" {
 				content += "\n"
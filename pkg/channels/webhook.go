@@ -0,0 +1,348 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	webhookMaxBodyBytes = 1 << 20
+	webhookSendTimeout  = 15 * time.Second
+)
+
+// WebhookChannel is a configurable channel for systems that can send and
+// receive JSON over HTTP, such as Home Assistant, n8n, Gitea or CI jobs.
+// Inbound payloads are mapped to messages through configured field paths;
+// replies are POSTed as {"chat_id": ..., "content": ...} to the reply URL.
+type WebhookChannel struct {
+	*BaseChannel
+	config     config.WebhookConfig
+	httpServer *http.Server
+	client     *http.Client
+}
+
+// NewWebhookChannel creates a new generic webhook channel instance.
+func NewWebhookChannel(cfg config.WebhookConfig, messageBus *bus.MessageBus) (*WebhookChannel, error) {
+	if cfg.ContentField == "" {
+		return nil, fmt.Errorf("webhook content_field is required")
+	}
+	// Without a secret anyone who can reach the listener can post messages,
+	// naming any sender they like.
+	if cfg.Secret == "" && !isLoopbackHost(cfg.WebhookHost) {
+		return nil, fmt.Errorf("webhook secret is required unless webhook_host is a loopback address")
+	}
+	if cfg.Secret == "" && len(cfg.AllowFrom) > 0 {
+		return nil, fmt.Errorf("webhook allow_from requires a secret: senders of unsigned payloads cannot be verified")
+	}
+
+	base := NewBaseChannel("webhook", cfg, messageBus, cfg.AllowFrom)
+
+	return &WebhookChannel{
+		BaseChannel: base,
+		config:      cfg,
+		client:      &http.Client{Timeout: webhookSendTimeout},
+	}, nil
+}
+
+// Start launches the HTTP server receiving webhook payloads.
+func (c *WebhookChannel) Start(ctx context.Context) error {
+	logger.InfoC("webhook", "Starting webhook channel")
+
+	mux := http.NewServeMux()
+	path := c.config.WebhookPath
+	if path == "" {
+		path = "/webhook/generic"
+	}
+	mux.HandleFunc(path, c.webhookHandler)
+
+	addr := fmt.Sprintf("%s:%d", c.config.WebhookHost, c.config.WebhookPort)
+	c.httpServer = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		logger.InfoCF("webhook", "Webhook server listening", map[string]any{
+			"addr": addr,
+			"path": path,
+		})
+		if err := c.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.ErrorCF("webhook", "Webhook server error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}()
+
+	c.setRunning(true)
+	logger.InfoC("webhook", "Webhook channel started")
+	return nil
+}
+
+// Stop gracefully shuts down the HTTP server.
+func (c *WebhookChannel) Stop(ctx context.Context) error {
+	logger.InfoC("webhook", "Stopping webhook channel")
+
+	if c.httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := c.httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.ErrorCF("webhook", "Webhook server shutdown error", map[string]any{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	c.setRunning(false)
+	logger.InfoC("webhook", "Webhook channel stopped")
+	return nil
+}
+
+// webhookHandler maps an inbound JSON payload to a message.
+func (c *WebhookChannel) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBodyBytes))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if c.config.Secret != "" && !c.verifySignature(body, r.Header.Get(c.signatureHeader())) {
+		logger.WarnC("webhook", "Invalid webhook signature")
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var payload any
+	if err := decoder.Decode(&payload); err != nil {
+		logger.ErrorCF("webhook", "Failed to parse webhook payload", map[string]any{
+			"error": err.Error(),
+		})
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	content := fieldString(payload, c.config.ContentField)
+	media := remoteURLs(fieldStrings(payload, c.config.MediaField))
+	if strings.TrimSpace(content) == "" && len(media) == 0 {
+		http.Error(w, fmt.Sprintf("No content at %q", c.config.ContentField), http.StatusBadRequest)
+		return
+	}
+
+	senderID := fieldString(payload, c.config.SenderField)
+	chatID := fieldString(payload, c.config.ChatField)
+	if senderID == "" {
+		senderID = "webhook"
+	}
+	if chatID == "" {
+		chatID = senderID
+	}
+
+	// The sender is only known to be genuine when the payload is signed,
+	// which NewWebhookChannel requires whenever allow_from is set.
+	if !c.IsAllowed(senderID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	logger.DebugCF("webhook", "Received webhook message", map[string]any{
+		"sender_id": senderID,
+		"chat_id":   chatID,
+		"media":     len(media),
+	})
+
+	c.HandleMessage(senderID, chatID, content, media, map[string]string{
+		"peer_kind": "direct",
+		"peer_id":   senderID,
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (c *WebhookChannel) signatureHeader() string {
+	if c.config.SignatureHeader != "" {
+		return c.config.SignatureHeader
+	}
+	return "X-Hub-Signature-256"
+}
+
+// verifySignature checks a hex HMAC-SHA256 of the body, with or without a
+// "sha256=" prefix (GitHub and Gitea use one each).
+func (c *WebhookChannel) verifySignature(body []byte, signature string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	if signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(c.sign(body)), []byte(strings.ToLower(signature)))
+}
+
+func (c *WebhookChannel) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(c.config.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send POSTs the reply to the configured reply URL. Without one the channel
+// is inbound only and replies are dropped.
+func (c *WebhookChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("webhook channel not running")
+	}
+	if c.config.ReplyURL == "" {
+		logger.DebugCF("webhook", "No reply_url configured, dropping reply", map[string]any{
+			"chat_id": msg.ChatID,
+		})
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"chat_id": msg.ChatID,
+		"content": msg.Content,
+	})
+	if err != nil {
+		return err
+	}
+
+	replyURL := strings.ReplaceAll(c.config.ReplyURL, "{chat_id}", url.QueryEscape(msg.ChatID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, replyURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook reply: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.config.ReplyHeaders {
+		req.Header.Set(k, v)
+	}
+	if c.config.Secret != "" {
+		req.Header.Set(c.signatureHeader(), "sha256="+c.sign(body))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook reply failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook reply returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// isLoopbackHost reports whether host only accepts local connections.
+// An empty host listens on every interface.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// remoteURLs keeps the http(s) URLs of media, which the model's provider
+// fetches itself. Anything else could name a file on this host.
+func remoteURLs(media []string) []string {
+	var urls []string
+	for _, item := range media {
+		if u, err := url.Parse(item); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			urls = append(urls, item)
+			continue
+		}
+		logger.WarnCF("webhook", "Ignoring media that is not an http(s) URL", map[string]any{
+			"media": item,
+		})
+	}
+	return urls
+}
+
+// fieldValue follows a dot-separated path such as "message.from.id" into a
+// decoded JSON value. Numeric segments index into arrays.
+func fieldValue(v any, path string) (any, bool) {
+	if path == "" {
+		return nil, false
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// fieldString returns the value at path as text. Objects and arrays are
+// returned as compact JSON.
+func fieldString(payload any, path string) string {
+	v, ok := fieldValue(payload, path)
+	if !ok {
+		return ""
+	}
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+// fieldStrings returns the value at path as a list, accepting a single
+// string or an array of strings.
+func fieldStrings(payload any, path string) []string {
+	v, ok := fieldValue(payload, path)
+	if !ok {
+		return nil
+	}
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	case []any:
+		var out []string
+		for _, item := range val {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package channels
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestWebhookChannel(t *testing.T, cfg config.WebhookConfig) (*WebhookChannel, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewWebhookChannel(cfg, msgBus)
+	if err != nil {
+		t.Fatalf("NewWebhookChannel() error: %v", err)
+	}
+	return ch, msgBus
+}
+
+func postWebhook(ch *WebhookChannel, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook/generic", strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	ch.webhookHandler(rec, req)
+	return rec
+}
+
+func TestWebhookChannel_MapsPayloadFields(t *testing.T) {
+	ch, msgBus := newTestWebhookChannel(t, config.WebhookConfig{
+		WebhookHost:  "127.0.0.1",
+		SenderField:  "event.user.id",
+		ChatField:    "event.room",
+		ContentField: "event.text",
+		MediaField:   "event.attachments",
+	})
+
+	rec := postWebhook(ch, `{"event":{"user":{"id":42},"room":"kitchen","text":"lights off",
+		"attachments":["https://example.com/a.jpg","/home/user/.ssh/id_rsa.png","file:///etc/passwd"]}}`, nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected an inbound message")
+	}
+	if msg.Channel != "webhook" || msg.SenderID != "42" || msg.ChatID != "kitchen" || msg.Content != "lights off" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if len(msg.Media) != 1 || msg.Media[0] != "https://example.com/a.jpg" {
+		t.Errorf("media = %v", msg.Media)
+	}
+
+	if rec := postWebhook(ch, `{"event":{"user":{"id":42}}}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("payload without content: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestWebhookChannel_VerifiesSignature(t *testing.T) {
+	ch, _ := newTestWebhookChannel(t, config.WebhookConfig{
+		Secret:          "s3cret",
+		SignatureHeader: "X-Gitea-Signature",
+		ContentField:    "content",
+	})
+
+	body := `{"content":"build failed"}`
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+	sig := hex.EncodeToString(mac.Sum(nil))
+
+	if rec := postWebhook(ch, body, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("unsigned: status = %d, want 403", rec.Code)
+	}
+	if rec := postWebhook(ch, body, map[string]string{"X-Gitea-Signature": "deadbeef"}); rec.Code != http.StatusForbidden {
+		t.Errorf("bad signature: status = %d, want 403", rec.Code)
+	}
+	for _, s := range []string{sig, "sha256=" + sig} {
+		if rec := postWebhook(ch, body, map[string]string{"X-Gitea-Signature": s}); rec.Code != http.StatusAccepted {
+			t.Errorf("signature %q: status = %d, want 202", s, rec.Code)
+		}
+	}
+}
+
+func TestNewWebhookChannel_RequiresSecretOffLoopback(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.WebhookConfig
+		wantErr bool
+	}{
+		{"all interfaces", config.WebhookConfig{WebhookHost: "0.0.0.0"}, true},
+		{"empty host", config.WebhookConfig{}, true},
+		{"public address", config.WebhookConfig{WebhookHost: "192.168.1.5"}, true},
+		{"loopback", config.WebhookConfig{WebhookHost: "127.0.0.1"}, false},
+		{"ipv6 loopback", config.WebhookConfig{WebhookHost: "::1"}, false},
+		{"secret", config.WebhookConfig{WebhookHost: "0.0.0.0", Secret: "s3cret"}, false},
+		{"allow_from without secret", config.WebhookConfig{WebhookHost: "127.0.0.1", AllowFrom: []string{"42"}}, true},
+		{"allow_from with secret", config.WebhookConfig{Secret: "s3cret", AllowFrom: []string{"42"}}, false},
+	}
+	for _, tt := range tests {
+		tt.cfg.ContentField = "content"
+		_, err := NewWebhookChannel(tt.cfg, bus.NewMessageBus())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWebhookChannel_SendPostsToReplyURL(t *testing.T) {
+	var gotPath, gotQuery, gotAuth string
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery, gotAuth = r.URL.Path, r.URL.Query().Get("chat"), r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	ch, _ := newTestWebhookChannel(t, config.WebhookConfig{
+		WebhookHost:  "localhost",
+		ContentField: "content",
+		ReplyURL:     server.URL + "/reply?chat={chat_id}",
+		ReplyHeaders: map[string]string{"Authorization": "Bearer ha-token"},
+	})
+	ch.setRunning(true)
+
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "living room", Content: "done"}); err != nil {
+		t.Fatalf("Send() error: %v", err)
+	}
+	if gotPath != "/reply" || gotQuery != "living room" || gotAuth != "Bearer ha-token" {
+		t.Errorf("path=%q chat=%q auth=%q", gotPath, gotQuery, gotAuth)
+	}
+	if got["chat_id"] != "living room" || got["content"] != "done" {
+		t.Errorf("body = %v", got)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"sync/atomic"
 
 	"github.com/caarlos0/env/v11"
@@ -47,17 +49,18 @@ func (f *FlexibleStringSlice) UnmarshalJSON(data []byte) error {
 }
 
 type Config struct {
-	Agents    AgentsConfig    `json:"agents"`
-	Bindings  []AgentBinding  `json:"bindings,omitempty"`
-	Session   SessionConfig   `json:"session,omitempty"`
-	Channels  ChannelsConfig  `json:"channels"`
-	Providers ProvidersConfig `json:"providers,omitempty"`
-	ModelList []ModelConfig   `json:"model_list"` // New model-centric provider configuration
-	Gateway   GatewayConfig   `json:"gateway"`
-	Tools     ToolsConfig     `json:"tools"`
-	Compression CompressionConfig `json:"compression,omitempty"`
-	Heartbeat HeartbeatConfig `json:"heartbeat"`
-	Devices   DevicesConfig   `json:"devices"`
+	Agents      AgentsConfig        `json:"agents"`
+	Bindings    []AgentBinding      `json:"bindings,omitempty"`
+	Roles       map[string][]string `json:"roles,omitempty"` // role -> sender IDs ("id" or "channel:id"), for sender_roles bindings
+	Session     SessionConfig       `json:"session,omitempty"`
+	Channels    ChannelsConfig      `json:"channels"`
+	Providers   ProvidersConfig     `json:"providers,omitempty"`
+	ModelList   []ModelConfig       `json:"model_list"` // New model-centric provider configuration
+	Gateway     GatewayConfig       `json:"gateway"`
+	Tools       ToolsConfig         `json:"tools"`
+	Compression CompressionConfig   `json:"compression,omitempty"`
+	Heartbeat   HeartbeatConfig     `json:"heartbeat"`
+	Devices     DevicesConfig       `json:"devices"`
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -130,18 +133,20 @@ func (m AgentModelConfig) MarshalJSON() ([]byte, error) {
 }
 
 type AgentConfig struct {
-	ID        string            `json:"id"`
-	Default   bool              `json:"default,omitempty"`
-	Name      string            `json:"name,omitempty"`
-	Workspace string            `json:"workspace,omitempty"`
-	Model     *AgentModelConfig `json:"model,omitempty"`
-	Skills    []string          `json:"skills,omitempty"`
-	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
+	ID         string            `json:"id"`
+	Default    bool              `json:"default,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Workspace  string            `json:"workspace,omitempty"`
+	Model      *AgentModelConfig `json:"model,omitempty"`
+	Skills     []string          `json:"skills,omitempty"`
+	MCPServers []string          `json:"mcp_servers,omitempty"` // MCP servers this agent may use; all when unset
+	Subagents  *SubagentsConfig  `json:"subagents,omitempty"`
 }
 
 type SubagentsConfig struct {
 	AllowAgents []string          `json:"allow_agents,omitempty"`
 	Model       *AgentModelConfig `json:"model,omitempty"`
+	Tools       []string          `json:"tools,omitempty"` // Tools subagents of this agent may use; all of the target's when unset
 }
 
 type PeerMatch struct {
@@ -149,12 +154,38 @@ type PeerMatch struct {
 	ID   string `json:"id"`
 }
 
+// BindingMatch selects the messages a binding routes. Channel is required
+// ("*" for any channel); the remaining fields narrow the match. Content,
+// SenderRoles, Schedule and HasMedia are conditions: a binding with them only
+// applies to messages meeting all of them.
 type BindingMatch struct {
-	Channel   string     `json:"channel"`
-	AccountID string     `json:"account_id,omitempty"`
-	Peer      *PeerMatch `json:"peer,omitempty"`
-	GuildID   string     `json:"guild_id,omitempty"`
-	TeamID    string     `json:"team_id,omitempty"`
+	Channel     string         `json:"channel"`
+	AccountID   string         `json:"account_id,omitempty"`
+	Peer        *PeerMatch     `json:"peer,omitempty"`
+	GuildID     string         `json:"guild_id,omitempty"`
+	TeamID      string         `json:"team_id,omitempty"`
+	Content     *ContentMatch  `json:"content,omitempty"`
+	SenderRoles []string       `json:"sender_roles,omitempty"` // any one of them
+	Schedule    *ScheduleMatch `json:"schedule,omitempty"`
+	HasMedia    *bool          `json:"has_media,omitempty"`
+}
+
+// ContentMatch matches the text of a message. Prefix matches a leading word
+// such as "@coder" or "/code", Keywords match words anywhere and Regex
+// matches anywhere; any one of them matching is enough. Matching ignores case.
+type ContentMatch struct {
+	Prefix   string   `json:"prefix,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Regex    string   `json:"regex,omitempty"`
+}
+
+// ScheduleMatch limits a binding to a window of the day on some days of the
+// week. To may be earlier than From for a window spanning midnight.
+type ScheduleMatch struct {
+	Days     []string `json:"days,omitempty"`     // "mon" to "sun"; every day when empty
+	From     string   `json:"from,omitempty"`     // "15:04", inclusive; midnight when empty
+	To       string   `json:"to,omitempty"`       // "15:04", exclusive; midnight when empty
+	Timezone string   `json:"timezone,omitempty"` // IANA name; local time when empty
 }
 
 type AgentBinding struct {
@@ -168,16 +199,37 @@ type SessionConfig struct {
 }
 
 type AgentDefaults struct {
-	Workspace           string   `json:"workspace"                       env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
-	RestrictToWorkspace bool     `json:"restrict_to_workspace"           env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
-	Provider            string   `json:"provider"                        env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
-	Model               string   `json:"model"                           env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
-	ModelFallbacks      []string `json:"model_fallbacks,omitempty"`
-	ImageModel          string   `json:"image_model,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
-	ImageModelFallbacks []string `json:"image_model_fallbacks,omitempty"`
-	MaxTokens           int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
-	Temperature         *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
-	MaxToolIterations   int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	Workspace             string   `json:"workspace"                         env:"PICOCLAW_AGENTS_DEFAULTS_WORKSPACE"`
+	RestrictToWorkspace   bool     `json:"restrict_to_workspace"             env:"PICOCLAW_AGENTS_DEFAULTS_RESTRICT_TO_WORKSPACE"`
+	Provider              string   `json:"provider"                          env:"PICOCLAW_AGENTS_DEFAULTS_PROVIDER"`
+	Model                 string   `json:"model"                             env:"PICOCLAW_AGENTS_DEFAULTS_MODEL"`
+	ModelFallbacks        []string `json:"model_fallbacks,omitempty"`
+	ImageModel            string   `json:"image_model,omitempty"             env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
+	ImageModelFallbacks   []string `json:"image_model_fallbacks,omitempty"`
+	MaxTokens             int      `json:"max_tokens"                        env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
+	Temperature           *float64 `json:"temperature,omitempty"             env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
+	MaxToolIterations     int      `json:"max_tool_iterations"               env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	MaxConcurrentSessions int      `json:"max_concurrent_sessions,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_CONCURRENT_SESSIONS"`
+	MaxParallelToolCalls  int      `json:"max_parallel_tool_calls,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_MAX_PARALLEL_TOOL_CALLS"`
+	MaxSubagentDepth      int      `json:"max_subagent_depth,omitempty"      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_SUBAGENT_DEPTH"`
+	Streaming             bool     `json:"streaming"                         env:"PICOCLAW_AGENTS_DEFAULTS_STREAMING"`
+
+	ModelRouting ModelRoutingConfig `json:"model_routing,omitempty"`
+}
+
+// ModelRoutingConfig sends turns that look simple to a small, cheap model and
+// the rest to the agent's own model. A turn on the small model moves to the
+// agent's model when the small one fails, loops or says it is out of its depth.
+type ModelRoutingConfig struct {
+	Enabled             bool     `json:"enabled"                        env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_ROUTING_ENABLED"`
+	SmallModel          string   `json:"small_model"                    env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_ROUTING_SMALL_MODEL"`
+	SmallModelFallbacks []string `json:"small_model_fallbacks,omitempty"`
+	// ClassifierModel, when set, is asked about turns the rules can't decide;
+	// otherwise those go to the small model.
+	ClassifierModel    string   `json:"classifier_model,omitempty"     env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_ROUTING_CLASSIFIER_MODEL"`
+	MaxSimpleChars     int      `json:"max_simple_chars,omitempty"     env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_ROUTING_MAX_SIMPLE_CHARS"`
+	MaxSmallIterations int      `json:"max_small_iterations,omitempty" env:"PICOCLAW_AGENTS_DEFAULTS_MODEL_ROUTING_MAX_SMALL_ITERATIONS"`
+	ComplexKeywords    []string `json:"complex_keywords,omitempty"` // added to the built-in words that mark a turn complex
 }
 
 type ChannelsConfig struct {
@@ -193,6 +245,7 @@ type ChannelsConfig struct {
 	OneBot   OneBotConfig   `json:"onebot"`
 	WeCom    WeComConfig    `json:"wecom"`
 	WeComApp WeComAppConfig `json:"wecom_app"`
+	Webhook  WebhookConfig  `json:"webhook"`
 }
 
 type WhatsAppConfig struct {
@@ -297,6 +350,25 @@ type WeComAppConfig struct {
 	ReplyTimeout   int                 `json:"reply_timeout"    env:"PICOCLAW_CHANNELS_WECOM_APP_REPLY_TIMEOUT"`
 }
 
+// WebhookConfig configures the generic webhook channel. Inbound JSON payloads
+// are mapped to messages with dot-separated field paths (e.g. "message.text");
+// replies are POSTed to ReplyURL, in which {chat_id} is substituted.
+type WebhookConfig struct {
+	Enabled         bool                `json:"enabled"          env:"PICOCLAW_CHANNELS_WEBHOOK_ENABLED"`
+	WebhookHost     string              `json:"webhook_host"     env:"PICOCLAW_CHANNELS_WEBHOOK_WEBHOOK_HOST"`
+	WebhookPort     int                 `json:"webhook_port"     env:"PICOCLAW_CHANNELS_WEBHOOK_WEBHOOK_PORT"`
+	WebhookPath     string              `json:"webhook_path"     env:"PICOCLAW_CHANNELS_WEBHOOK_WEBHOOK_PATH"`
+	Secret          string              `json:"secret"           env:"PICOCLAW_CHANNELS_WEBHOOK_SECRET"`           // HMAC-SHA256 key; may only be empty on a loopback host
+	SignatureHeader string              `json:"signature_header" env:"PICOCLAW_CHANNELS_WEBHOOK_SIGNATURE_HEADER"` // Hex digest, optionally prefixed with "sha256="
+	SenderField     string              `json:"sender_field"     env:"PICOCLAW_CHANNELS_WEBHOOK_SENDER_FIELD"`
+	ChatField       string              `json:"chat_field"       env:"PICOCLAW_CHANNELS_WEBHOOK_CHAT_FIELD"`
+	ContentField    string              `json:"content_field"    env:"PICOCLAW_CHANNELS_WEBHOOK_CONTENT_FIELD"`
+	MediaField      string              `json:"media_field"      env:"PICOCLAW_CHANNELS_WEBHOOK_MEDIA_FIELD"`
+	ReplyURL        string              `json:"reply_url"        env:"PICOCLAW_CHANNELS_WEBHOOK_REPLY_URL"`
+	ReplyHeaders    map[string]string   `json:"reply_headers,omitempty"`
+	AllowFrom       FlexibleStringSlice `json:"allow_from"       env:"PICOCLAW_CHANNELS_WEBHOOK_ALLOW_FROM"`
+}
+
 type HeartbeatConfig struct {
 	Enabled  bool `json:"enabled"  env:"PICOCLAW_HEARTBEAT_ENABLED"`
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
@@ -395,6 +467,7 @@ type ModelConfig struct {
 	// Optional optimizations
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
+	LoadBalance    string `json:"load_balance,omitempty"`     // Selection among entries sharing model_name: round_robin (default), least_loaded
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -409,8 +482,16 @@ func (c *ModelConfig) Validate() error {
 }
 
 type GatewayConfig struct {
-	Host string `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
-	Port int    `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	Host string           `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
+	Port int              `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	API  GatewayAPIConfig `json:"api"`
+}
+
+// GatewayAPIConfig configures the OpenAI-compatible HTTP API served on the
+// gateway port. Requests must carry one of Tokens as a bearer token.
+type GatewayAPIConfig struct {
+	Enabled bool                `json:"enabled" env:"PICOCLAW_GATEWAY_API_ENABLED"`
+	Tokens  FlexibleStringSlice `json:"tokens"  env:"PICOCLAW_GATEWAY_API_TOKENS"`
 }
 
 type BraveConfig struct {
@@ -449,24 +530,125 @@ type CronToolsConfig struct {
 }
 
 type ExecConfig struct {
-	EnableDenyPatterns bool     `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
-	CustomDenyPatterns []string `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	EnableDenyPatterns bool              `json:"enable_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"`
+	CustomDenyPatterns []string          `json:"custom_deny_patterns" env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"`
+	Sandbox            ExecSandboxConfig `json:"sandbox"`
+}
+
+// ExecSandboxConfig runs exec commands in Linux namespaces where only the
+// workspace is writable and only ReadOnlyPaths of the rest of the host are
+// visible. When enabled but unavailable, commands are refused.
+type ExecSandboxConfig struct {
+	Enabled       bool     `json:"enabled"         env:"PICOCLAW_TOOLS_EXEC_SANDBOX_ENABLED"`
+	AllowNetwork  bool     `json:"allow_network"   env:"PICOCLAW_TOOLS_EXEC_SANDBOX_ALLOW_NETWORK"`
+	ReadOnlyPaths []string `json:"read_only_paths" env:"PICOCLAW_TOOLS_EXEC_SANDBOX_READ_ONLY_PATHS"` // Empty for /usr, /bin, /lib, /etc, ...
+	MaxCPUSeconds int      `json:"max_cpu_seconds" env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MAX_CPU_SECONDS"`
+	MaxMemoryMB   int      `json:"max_memory_mb"   env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MAX_MEMORY_MB"`
+	MaxProcesses  int      `json:"max_processes"   env:"PICOCLAW_TOOLS_EXEC_SANDBOX_MAX_PROCESSES"`
 }
 
 // CompressionConfig controls the compress-and-archive memory system.
 type CompressionConfig struct {
-        ChunkSizeTokens  int    `json:"chunk_size_tokens"  env:"PICOCLAW_COMPRESSION_CHUNK_SIZE_TOKENS"`
-        ContinuityBuffer int    `json:"continuity_buffer"  env:"PICOCLAW_COMPRESSION_CONTINUITY_BUFFER"`
-        MinChunkMessages int    `json:"min_chunk_messages" env:"PICOCLAW_COMPRESSION_MIN_CHUNK_MESSAGES"`
-        ColdStorageDir   string `json:"cold_storage_dir"   env:"PICOCLAW_COMPRESSION_COLD_STORAGE_DIR"`
-        SummaryMaxTokens int    `json:"summary_max_tokens" env:"PICOCLAW_COMPRESSION_SUMMARY_MAX_TOKENS"`
+	ChunkSizeTokens  int    `json:"chunk_size_tokens"  env:"PICOCLAW_COMPRESSION_CHUNK_SIZE_TOKENS"`
+	ContinuityBuffer int    `json:"continuity_buffer"  env:"PICOCLAW_COMPRESSION_CONTINUITY_BUFFER"`
+	MinChunkMessages int    `json:"min_chunk_messages" env:"PICOCLAW_COMPRESSION_MIN_CHUNK_MESSAGES"`
+	ColdStorageDir   string `json:"cold_storage_dir"   env:"PICOCLAW_COMPRESSION_COLD_STORAGE_DIR"`
+	SummaryMaxTokens int    `json:"summary_max_tokens" env:"PICOCLAW_COMPRESSION_SUMMARY_MAX_TOKENS"`
 }
 
 type ToolsConfig struct {
-	Web    WebToolsConfig    `json:"web"`
-	Cron   CronToolsConfig   `json:"cron"`
-	Exec   ExecConfig        `json:"exec"`
-	Skills SkillsToolsConfig `json:"skills"`
+	Web      WebToolsConfig    `json:"web"`
+	Cron     CronToolsConfig   `json:"cron"`
+	Exec     ExecConfig        `json:"exec"`
+	Skills   SkillsToolsConfig `json:"skills"`
+	Memory   MemoryToolsConfig `json:"memory"`
+	MCP      MCPConfig         `json:"mcp"`
+	Approval ApprovalConfig    `json:"approval"`
+	Egress   EgressConfig      `json:"egress"`
+	Output   ToolOutputConfig  `json:"output"`
+}
+
+// ToolOutputConfig bounds how much of a single tool result is sent to the
+// model. Longer results are cut down and saved in full under the agent's
+// workspace (tool-output/) so the model can page through them.
+type ToolOutputConfig struct {
+	MaxChars int `json:"max_chars" env:"PICOCLAW_TOOLS_OUTPUT_MAX_CHARS"`
+}
+
+// EgressConfig limits where web_fetch and skill downloads may connect.
+// Hosts resolving to loopback, private, link-local (including cloud metadata)
+// and other non-public addresses are refused unless listed in AllowHosts.
+// Host entries are hostnames, "*.example.com" wildcards, IPs or CIDRs.
+type EgressConfig struct {
+	AllowPrivateNetworks bool     `json:"allow_private_networks" env:"PICOCLAW_TOOLS_EGRESS_ALLOW_PRIVATE_NETWORKS"`
+	AllowHosts           []string `json:"allow_hosts"            env:"PICOCLAW_TOOLS_EGRESS_ALLOW_HOSTS"`
+	DenyHosts            []string `json:"deny_hosts"             env:"PICOCLAW_TOOLS_EGRESS_DENY_HOSTS"`
+	AllowedSchemes       []string `json:"allowed_schemes"        env:"PICOCLAW_TOOLS_EGRESS_ALLOWED_SCHEMES"` // Empty for http and https
+	MaxResponseBytes     int64    `json:"max_response_bytes"     env:"PICOCLAW_TOOLS_EGRESS_MAX_RESPONSE_BYTES"`
+}
+
+// ApprovalConfig makes selected tool calls wait for a human to approve them
+// in the chat the request came from. Rules are checked in order and the
+// first match decides; calls matching no rule run without asking.
+type ApprovalConfig struct {
+	Enabled        bool           `json:"enabled"         env:"PICOCLAW_TOOLS_APPROVAL_ENABLED"`
+	TimeoutSeconds int            `json:"timeout_seconds" env:"PICOCLAW_TOOLS_APPROVAL_TIMEOUT_SECONDS"`
+	Rules          []ApprovalRule `json:"rules"`
+}
+
+// ApprovalRule matches tool calls by tool name ("*" for any tool) and,
+// optionally, a regular expression tested against the JSON-encoded
+// arguments. Action is "ask", "allow" or "deny".
+type ApprovalRule struct {
+	Tool    string `json:"tool"`
+	Pattern string `json:"pattern,omitempty"`
+	Action  string `json:"action"`
+}
+
+// Validate checks that every rule names a tool, a known action and a
+// pattern that compiles.
+func (c *ApprovalConfig) Validate() error {
+	for i, rule := range c.Rules {
+		if rule.Tool == "" {
+			return fmt.Errorf("tools.approval.rules[%d]: tool is required", i)
+		}
+		switch strings.ToLower(rule.Action) {
+		case "ask", "allow", "deny":
+		default:
+			return fmt.Errorf("tools.approval.rules[%d]: invalid action %q", i, rule.Action)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("tools.approval.rules[%d]: invalid pattern: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// MCPConfig declares Model Context Protocol servers, keyed by name, whose
+// tools are offered to agents as mcp_<server>_<tool>.
+type MCPConfig struct {
+	Servers map[string]MCPServerConfig `json:"servers,omitempty"`
+}
+
+// MCPServerConfig describes one MCP server: either a stdio server launched
+// from Command, or a streamable-HTTP server at URL.
+type MCPServerConfig struct {
+	Disabled       bool              `json:"disabled,omitempty"`
+	Command        string            `json:"command,omitempty"`
+	Args           []string          `json:"args,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	URL            string            `json:"url,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"` // Per tool call; default 60
+}
+
+// MemoryToolsConfig controls the memory_search and memory_save tools. When
+// enabled, memory files are no longer inlined into the system prompt; the
+// agent retrieves relevant snippets on demand instead.
+type MemoryToolsConfig struct {
+	Enabled        bool   `json:"enabled"         env:"PICOCLAW_TOOLS_MEMORY_ENABLED"`
+	MaxResults     int    `json:"max_results"     env:"PICOCLAW_TOOLS_MEMORY_MAX_RESULTS"`
+	EmbeddingModel string `json:"embedding_model" env:"PICOCLAW_TOOLS_MEMORY_EMBEDDING_MODEL"` // model_list entry; empty for keyword search only
 }
 
 type SkillsToolsConfig struct {
@@ -525,6 +707,10 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.Tools.Approval.Validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
@@ -10,13 +10,22 @@ func DefaultConfig() *Config {
 	return &Config{
 		Agents: AgentsConfig{
 			Defaults: AgentDefaults{
-				Workspace:           "~/.picoclaw/workspace",
-				RestrictToWorkspace: true,
-				Provider:            "",
-				Model:               "glm-4.7",
-				MaxTokens:           8192,
-				Temperature:         nil, // nil means use provider default
-				MaxToolIterations:   20,
+				Workspace:             "~/.picoclaw/workspace",
+				RestrictToWorkspace:   true,
+				Provider:              "",
+				Model:                 "glm-4.7",
+				MaxTokens:             8192,
+				Temperature:           nil, // nil means use provider default
+				MaxToolIterations:     20,
+				MaxConcurrentSessions: 4,
+				MaxParallelToolCalls:  4,
+				MaxSubagentDepth:      1,
+				Streaming:             true,
+				ModelRouting: ModelRoutingConfig{
+					Enabled:            false,
+					MaxSimpleChars:     200,
+					MaxSmallIterations: 4,
+				},
 			},
 		},
 		Bindings: []AgentBinding{},
@@ -113,6 +122,18 @@ func DefaultConfig() *Config {
 				AllowFrom:      FlexibleStringSlice{},
 				ReplyTimeout:   5,
 			},
+			Webhook: WebhookConfig{
+				Enabled:         false,
+				WebhookHost:     "127.0.0.1",
+				WebhookPort:     18794,
+				WebhookPath:     "/webhook/generic",
+				SignatureHeader: "X-Hub-Signature-256",
+				SenderField:     "sender",
+				ChatField:       "chat_id",
+				ContentField:    "content",
+				MediaField:      "media",
+				AllowFrom:       FlexibleStringSlice{},
+			},
 		},
 		Providers: ProvidersConfig{
 			OpenAI: OpenAIProviderConfig{WebSearch: true},
@@ -289,6 +310,21 @@ func DefaultConfig() *Config {
 			},
 			Exec: ExecConfig{
 				EnableDenyPatterns: true,
+				Sandbox: ExecSandboxConfig{
+					MaxCPUSeconds: 300,
+					MaxMemoryMB:   2048,
+					MaxProcesses:  512,
+				},
+			},
+			Approval: ApprovalConfig{
+				TimeoutSeconds: 300,
+			},
+			Egress: EgressConfig{
+				AllowedSchemes:   []string{"http", "https"},
+				MaxResponseBytes: 10 << 20,
+			},
+			Output: ToolOutputConfig{
+				MaxChars: 30000,
 			},
 			Skills: SkillsToolsConfig{
 				Registries: SkillsRegistriesConfig{
@@ -303,17 +339,21 @@ func DefaultConfig() *Config {
 					TTLSeconds: 300,
 				},
 			},
+			Memory: MemoryToolsConfig{
+				Enabled:    true,
+				MaxResults: 5,
+			},
 		},
 		Heartbeat: HeartbeatConfig{
 			Enabled:  true,
 			Interval: 30,
 		},
 		Compression: CompressionConfig{
-				ChunkSizeTokens:  1200,
-				ContinuityBuffer: 4,
-				MinChunkMessages: 4,
-				ColdStorageDir:   "memory/chunks",
-				SummaryMaxTokens: 4096,
+			ChunkSizeTokens:  1200,
+			ContinuityBuffer: 4,
+			MinChunkMessages: 4,
+			ColdStorageDir:   "memory/chunks",
+			SummaryMaxTokens: 4096,
 		},
 		Devices: DevicesConfig{
 			Enabled:    false,
@@ -7,6 +7,7 @@ var internalChannels = map[string]struct{}{
 	"cli":      {},
 	"system":   {},
 	"subagent": {},
+	"http":     {}, // HTTP API; replies are returned in the response
 }
 
 // IsInternalChannel returns true if the channel is an internal channel.
@@ -0,0 +1,291 @@
+// Package egress decides which hosts tools may reach over HTTP. It keeps
+// model-chosen URLs away from loopback services, the local network and cloud
+// metadata endpoints by checking the address every connection actually dials,
+// so redirects and DNS rebinding cannot get around it.
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	defaultMaxResponseBytes = 10 << 20
+	maxRedirects            = 5
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the policy's
+// size limit.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// blockedPrefixes are the non-public ranges that net/netip has no predicate for.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved and broadcast
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+}
+
+// IPv6 ranges that carry an IPv4 address, see embeddedIPv4.
+var (
+	nat64Prefix     = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+)
+
+// Policy checks URLs and dialed addresses against the egress configuration.
+// The zero value is not usable; use NewPolicy or DefaultPolicy.
+type Policy struct {
+	allowPrivate bool
+	allowHosts   hostList
+	denyHosts    hostList
+	schemes      map[string]bool
+	maxBodyBytes int64
+	resolver     *net.Resolver
+	dialer       *net.Dialer
+}
+
+// NewPolicy builds a policy from cfg, filling in defaults for empty fields.
+func NewPolicy(cfg config.EgressConfig) *Policy {
+	p := &Policy{
+		allowPrivate: cfg.AllowPrivateNetworks,
+		allowHosts:   parseHostList(cfg.AllowHosts),
+		denyHosts:    parseHostList(cfg.DenyHosts),
+		schemes:      make(map[string]bool),
+		maxBodyBytes: cfg.MaxResponseBytes,
+		resolver:     net.DefaultResolver,
+		dialer:       &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	for _, scheme := range cfg.AllowedSchemes {
+		p.schemes[strings.ToLower(strings.TrimSpace(scheme))] = true
+	}
+	if len(p.schemes) == 0 {
+		p.schemes["http"] = true
+		p.schemes["https"] = true
+	}
+	if p.maxBodyBytes <= 0 {
+		p.maxBodyBytes = defaultMaxResponseBytes
+	}
+	return p
+}
+
+// DefaultPolicy returns a policy that only allows http(s) to public addresses.
+func DefaultPolicy() *Policy {
+	return NewPolicy(config.EgressConfig{})
+}
+
+// MaxResponseBytes is the largest response body ReadBody accepts.
+func (p *Policy) MaxResponseBytes() int64 {
+	return p.maxBodyBytes
+}
+
+// CheckURL reports whether u may be requested. Hostnames are only checked
+// against the host lists here; their addresses are checked when dialing.
+func (p *Policy) CheckURL(u *url.URL) error {
+	if !p.schemes[strings.ToLower(u.Scheme)] {
+		return fmt.Errorf("scheme %q is not allowed", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("missing host in URL")
+	}
+	if p.denyHosts.matchHost(host) {
+		return fmt.Errorf("host %s is denied by the egress policy", host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.checkAddr(host, addr)
+	}
+	return nil
+}
+
+// checkAddr reports whether addr, which host resolved to, may be dialed.
+func (p *Policy) checkAddr(host string, addr netip.Addr) error {
+	addr = addr.Unmap()
+	if p.denyHosts.matchAddr(addr) {
+		return fmt.Errorf("host %s (%s) is denied by the egress policy", host, addr)
+	}
+	if p.allowPrivate || p.allowHosts.matchHost(host) || p.allowHosts.matchAddr(addr) {
+		return nil
+	}
+	if !IsPublic(addr) {
+		return fmt.Errorf("host %s resolves to non-public address %s", host, addr)
+	}
+	return nil
+}
+
+// IsPublic reports whether addr is a globally routable unicast address, as
+// opposed to loopback, private, link-local, multicast or reserved ones.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if v4, ok := embeddedIPv4(addr); ok {
+		return IsPublic(v4)
+	}
+	return true
+}
+
+// embeddedIPv4 extracts the IPv4 address carried by NAT64 and 6to4 addresses,
+// which would otherwise be a way to reach private IPv4 hosts over IPv6.
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	b := addr.As16()
+	switch {
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+	case sixToFourPrefix.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), true
+	}
+	return netip.Addr{}, false
+}
+
+// DialContext resolves addr, checks every address it resolves to and then
+// dials the checked addresses directly, so a second lookup cannot swap in a
+// different answer.
+func (p *Policy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if p.denyHosts.matchHost(host) {
+		return nil, fmt.Errorf("host %s is denied by the egress policy", host)
+	}
+
+	var addrs []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{ip}
+	} else {
+		addrs, err = p.resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	for _, ip := range addrs {
+		if err := p.checkAddr(host, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for _, ip := range addrs {
+		conn, err := p.dialer.DialContext(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Client returns an HTTP client that enforces the policy on every request,
+// redirect and connection. Environment proxies are ignored because the proxy,
+// not the client, would then choose the address.
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         p.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     30 * time.Second,
+			TLSHandshakeTimeout: 15 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return p.CheckURL(req.URL)
+		},
+	}
+}
+
+// ReadBody reads r up to the policy's response size limit and fails with
+// ErrResponseTooLarge beyond it.
+func (p *Policy) ReadBody(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, p.maxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > p.maxBodyBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, p.maxBodyBytes)
+	}
+	return body, nil
+}
+
+// hostList matches hostnames, "*.domain" wildcards, IPs and CIDRs.
+type hostList struct {
+	names    map[string]bool
+	suffixes []string
+	prefixes []netip.Prefix
+}
+
+func parseHostList(entries []string) hostList {
+	l := hostList{names: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, "*."):
+			l.suffixes = append(l.suffixes, entry[1:])
+		default:
+			if prefix, err := netip.ParsePrefix(entry); err == nil {
+				l.prefixes = append(l.prefixes, prefix.Masked())
+			} else if addr, err := netip.ParseAddr(entry); err == nil {
+				l.prefixes = append(l.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			} else {
+				l.names[strings.TrimSuffix(entry, ".")] = true
+			}
+		}
+	}
+	return l
+}
+
+func (l hostList) matchHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if l.names[host] {
+		return true
+	}
+	for _, suffix := range l.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return l.matchAddr(addr)
+	}
+	return false
+}
+
+func (l hostList) matchAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
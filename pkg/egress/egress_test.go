@@ -0,0 +1,108 @@
+package egress
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":            true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"100.100.100.200":    false,
+		"0.0.0.0":            false,
+		"255.255.255.255":    false,
+		"224.0.0.1":          false,
+		"::1":                false,
+		"fe80::1":            false,
+		"fd00:ec2::254":      false,
+		"::ffff:127.0.0.1":   false,
+		"64:ff9b::a9fe:a9fe": false,
+		"2002:c0a8:0101::1":  false,
+		"64:ff9b::808:808":   true,
+	}
+	for addr, want := range tests {
+		if got := IsPublic(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestPolicy_CheckURL(t *testing.T) {
+	p := NewPolicy(config.EgressConfig{
+		AllowHosts: []string{"nas.lan", "10.0.0.0/24"},
+		DenyHosts:  []string{"*.blocked.example", "evil.example"},
+	})
+	tests := []struct {
+		url     string
+		wantErr string
+	}{
+		{"https://example.com/", ""},
+		{"http://nas.lan:8080/", ""},
+		{"http://10.0.0.7/", ""},
+		{"http://10.0.1.7/", "non-public"},
+		{"http://[::ffff:169.254.169.254]/", "non-public"},
+		{"https://api.blocked.example/", "denied"},
+		{"https://EVIL.example./", "denied"},
+		{"ftp://example.com/", "scheme"},
+		{"file:///etc/passwd", "scheme"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		err := p.CheckURL(u)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("CheckURL(%s) = %v, want nil", tt.url, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("CheckURL(%s) = %v, want error containing %q", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPolicy_ClientChecksResolvedAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	// A hostname passes CheckURL, so only the dial-time check can stop it.
+	resp, err := DefaultPolicy().Client(5 * time.Second).Get("http://localhost:" + port)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected localhost to be blocked when dialing")
+	}
+	if !strings.Contains(err.Error(), "non-public") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	allowed := NewPolicy(config.EgressConfig{AllowHosts: []string{"localhost"}})
+	resp, err = allowed.Client(5 * time.Second).Get("http://localhost:" + port)
+	if err != nil {
+		t.Fatalf("expected allowlisted host to be reachable: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestPolicy_ReadBody(t *testing.T) {
+	p := NewPolicy(config.EgressConfig{MaxResponseBytes: 4})
+	if body, err := p.ReadBody(strings.NewReader("abcd")); err != nil || string(body) != "abcd" {
+		t.Errorf("ReadBody at the limit = %q, %v", body, err)
+	}
+	if _, err := p.ReadBody(strings.NewReader("abcde")); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("ReadBody over the limit = %v, want ErrResponseTooLarge", err)
+	}
+}
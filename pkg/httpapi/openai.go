@@ -0,0 +1,321 @@
+// Package httpapi serves an OpenAI-compatible chat completions API backed
+// by the agent loop, so existing OpenAI clients can talk to PicoClaw agents.
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// SessionHeader names the conversation a request belongs to. Without it the
+// request's "user" field is used, and failing that a shared default session.
+const SessionHeader = "X-Session-Id"
+
+const (
+	defaultSession = "default"
+	maxBodyBytes   = 4 << 20
+
+	// keepaliveInterval is how often a streaming response gets an SSE comment
+	// while the agent works, so idle timeouts in proxies and clients do not
+	// cut long turns.
+	keepaliveInterval = 15 * time.Second
+)
+
+// Backend runs agent turns. It is implemented by *agent.AgentLoop.
+type Backend interface {
+	ListAgentIDs() []string
+	ProcessHTTP(ctx context.Context, agentID, sessionID, content string, onDelta func(text string)) (string, error)
+}
+
+// Handler serves /v1/chat/completions and /v1/models. Each request carries
+// only the newest user message to the agent: history lives in the agent's
+// session, and tools run on the server.
+type Handler struct {
+	backend   Backend
+	tokens    []string
+	mux       *http.ServeMux
+	keepalive time.Duration
+}
+
+// NewHandler returns a handler that accepts requests bearing one of tokens.
+// With no tokens every request is rejected.
+func NewHandler(backend Backend, tokens []string) *Handler {
+	h := &Handler{
+		backend:   backend,
+		tokens:    tokens,
+		mux:       http.NewServeMux(),
+		keepalive: keepaliveInterval,
+	}
+	h.mux.HandleFunc("/v1/chat/completions", h.chatCompletions)
+	h.mux.HandleFunc("/v1/models", h.models)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "authentication_error", "invalid or missing bearer token")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	for _, t := range h.tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	User     string        `json:"user"`
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text returns the message content, which is either a string or a list of
+// content parts of which only the text parts are kept.
+func (m chatMessage) text() string {
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func (h *Handler) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+
+	var req chatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	var content string
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			content = strings.TrimSpace(req.Messages[i].text())
+			break
+		}
+	}
+	if content == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "messages must include a non-empty user message")
+		return
+	}
+
+	sessionID := strings.TrimSpace(r.Header.Get(SessionHeader))
+	if sessionID == "" {
+		sessionID = strings.TrimSpace(req.User)
+	}
+	if sessionID == "" {
+		sessionID = defaultSession
+	}
+
+	agentID := ""
+	for _, id := range h.backend.ListAgentIDs() {
+		if strings.EqualFold(id, req.Model) {
+			agentID = id
+			break
+		}
+	}
+	model := req.Model
+	if model == "" {
+		model = agentID
+	}
+
+	// Agent turns can run far longer than the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	logger.InfoCF("httpapi", "Chat completion request",
+		map[string]any{
+			"agent_id": agentID,
+			"session":  sessionID,
+			"stream":   req.Stream,
+		})
+
+	c := completion{id: newCompletionID(), created: time.Now().Unix(), model: model}
+	if req.Stream {
+		h.stream(w, r, c, agentID, sessionID, content)
+		return
+	}
+
+	reply, err := h.backend.ProcessHTTP(r.Context(), agentID, sessionID, content, nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":      c.id,
+		"object":  "chat.completion",
+		"created": c.created,
+		"model":   c.model,
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       map[string]any{"role": "assistant", "content": reply},
+			"finish_reason": "stop",
+		}},
+	})
+}
+
+// stream answers with server-sent events in the chat.completion.chunk format.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, c completion, agentID, sessionID, content string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	var mu sync.Mutex
+	var streamed strings.Builder
+	send := func(data any) {
+		mu.Lock()
+		defer mu.Unlock()
+		b, _ := json.Marshal(data)
+		fmt.Fprintf(w, "data: %s\n\n", b)
+		rc.Flush()
+	}
+
+	send(c.chunk(map[string]any{"role": "assistant"}, nil))
+
+	// The answer only arrives once the agent is done with its tools.
+	stopPings := make(chan struct{})
+	var pings sync.WaitGroup
+	pings.Add(1)
+	go func() {
+		defer pings.Done()
+		ticker := time.NewTicker(h.keepalive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopPings:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				fmt.Fprint(w, ": ping\n\n")
+				rc.Flush()
+				mu.Unlock()
+			}
+		}
+	}()
+	reply, err := h.backend.ProcessHTTP(r.Context(), agentID, sessionID, content, func(text string) {
+		streamed.WriteString(text)
+		send(c.chunk(map[string]any{"content": text}, nil))
+	})
+	close(stopPings)
+	pings.Wait()
+	if err != nil {
+		send(errorBody("server_error", err.Error()))
+	} else {
+		// Send whatever part of the reply the stream lacked: all of it when
+		// the provider cannot stream or the turn ended with a default reply.
+		if rest := strings.TrimPrefix(reply, streamed.String()); rest != "" {
+			send(c.chunk(map[string]any{"content": rest}, nil))
+		}
+		stop := "stop"
+		send(c.chunk(map[string]any{}, &stop))
+	}
+
+	mu.Lock()
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	rc.Flush()
+	mu.Unlock()
+}
+
+func (h *Handler) models(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	ids := h.backend.ListAgentIDs()
+	sort.Strings(ids)
+	data := []map[string]any{}
+	for _, id := range ids {
+		data = append(data, map[string]any{
+			"id":       id,
+			"object":   "model",
+			"created":  0,
+			"owned_by": "picoclaw",
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+// completion holds the fields shared by every chunk of one response.
+type completion struct {
+	id      string
+	created int64
+	model   string
+}
+
+func (c completion) chunk(delta map[string]any, finishReason *string) map[string]any {
+	return map[string]any{
+		"id":      c.id,
+		"object":  "chat.completion.chunk",
+		"created": c.created,
+		"model":   c.model,
+		"choices": []map[string]any{{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	}
+}
+
+func newCompletionID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+func errorBody(errType, message string) map[string]any {
+	return map[string]any{"error": map[string]any{"message": message, "type": errType}}
+}
+
+func writeError(w http.ResponseWriter, status int, errType, message string) {
+	writeJSON(w, status, errorBody(errType, message))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
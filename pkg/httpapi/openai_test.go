@@ -0,0 +1,231 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeBackend struct {
+	agentID   string
+	sessionID string
+	content   string
+	deltas    []string
+	reply     string
+	delay     time.Duration
+}
+
+func (b *fakeBackend) ListAgentIDs() []string { return []string{"main", "coder"} }
+
+func (b *fakeBackend) ProcessHTTP(
+	ctx context.Context,
+	agentID, sessionID, content string,
+	onDelta func(text string),
+) (string, error) {
+	b.agentID, b.sessionID, b.content = agentID, sessionID, content
+	time.Sleep(b.delay)
+	if onDelta != nil {
+		for _, d := range b.deltas {
+			onDelta(d)
+		}
+	}
+	return b.reply, nil
+}
+
+func doRequest(h http.Handler, method, path, token, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_RequiresBearerToken(t *testing.T) {
+	h := NewHandler(&fakeBackend{}, []string{"secret"})
+
+	for _, token := range []string{"", "wrong"} {
+		if rec := doRequest(h, http.MethodGet, "/v1/models", token, "", nil); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, rec.Code)
+		}
+	}
+	if rec := doRequest(h, http.MethodGet, "/v1/models", "secret", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("valid token: status = %d, want 200", rec.Code)
+	}
+
+	noTokens := NewHandler(&fakeBackend{}, nil)
+	if rec := doRequest(noTokens, http.MethodGet, "/v1/models", "anything", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("handler without tokens should reject requests, got %d", rec.Code)
+	}
+}
+
+func TestHandler_ListsAgentsAsModels(t *testing.T) {
+	h := NewHandler(&fakeBackend{}, []string{"secret"})
+	rec := doRequest(h, http.MethodGet, "/v1/models", "secret", "", nil)
+
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(resp.Data) != 2 || resp.Data[0].ID != "coder" || resp.Data[1].ID != "main" {
+		t.Errorf("unexpected models: %s", rec.Body.String())
+	}
+}
+
+func TestHandler_ChatCompletion(t *testing.T) {
+	backend := &fakeBackend{reply: "hi there"}
+	h := NewHandler(backend, []string{"secret"})
+
+	body := `{"model":"coder","user":"alice","messages":[
+		{"role":"system","content":"be brief"},
+		{"role":"user","content":"first"},
+		{"role":"assistant","content":"ok"},
+		{"role":"user","content":[{"type":"text","text":"second"}]}
+	]}`
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "secret", body, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Object  string `json:"object"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Object != "chat.completion" || resp.Model != "coder" || len(resp.Choices) != 1 ||
+		resp.Choices[0].Message.Content != "hi there" {
+		t.Errorf("unexpected response: %s", rec.Body.String())
+	}
+	if backend.agentID != "coder" || backend.sessionID != "alice" || backend.content != "second" {
+		t.Errorf("backend got agent=%q session=%q content=%q", backend.agentID, backend.sessionID, backend.content)
+	}
+
+	// The session header wins over the user field; unknown models fall back to routing.
+	doRequest(h, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"model":"gpt-4o","user":"alice","messages":[{"role":"user","content":"hey"}]}`,
+		map[string]string{SessionHeader: "thread-7"})
+	if backend.agentID != "" || backend.sessionID != "thread-7" {
+		t.Errorf("backend got agent=%q session=%q", backend.agentID, backend.sessionID)
+	}
+
+	rec = doRequest(h, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"messages":[{"role":"system","content":"x"}]}`, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("request without a user message: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandler_StreamsServerSentEvents(t *testing.T) {
+	backend := &fakeBackend{deltas: []string{"Hel", "lo"}, reply: "Hello"}
+	h := NewHandler(backend, []string{"secret"})
+
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"model":"main","stream":true,"messages":[{"role":"user","content":"hi"}]}`, nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var text strings.Builder
+	var events []string
+	finished := false
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		events = append(events, data)
+		if data == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Object  string `json:"object"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("invalid chunk %q: %v", data, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("object = %q", chunk.Object)
+		}
+		text.WriteString(chunk.Choices[0].Delta.Content)
+		if fr := chunk.Choices[0].FinishReason; fr != nil && *fr == "stop" {
+			finished = true
+		}
+	}
+
+	if text.String() != "Hello" {
+		t.Errorf("streamed text = %q, want Hello", text.String())
+	}
+	if !finished {
+		t.Error("expected a chunk with finish_reason stop")
+	}
+	if len(events) == 0 || events[len(events)-1] != "[DONE]" {
+		t.Errorf("expected the stream to end with [DONE], got %v", events)
+	}
+}
+
+func TestHandler_StreamWithoutDeltasSendsWholeReply(t *testing.T) {
+	backend := &fakeBackend{reply: "all at once"}
+	h := NewHandler(backend, []string{"secret"})
+
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`, nil)
+	if !strings.Contains(rec.Body.String(), `"content":"all at once"`) {
+		t.Errorf("expected the full reply in the stream, got %s", rec.Body.String())
+	}
+}
+
+func TestHandler_StreamDoesNotRepeatStreamedReply(t *testing.T) {
+	backend := &fakeBackend{deltas: []string{"Hello"}, reply: "Hello"}
+	h := NewHandler(backend, []string{"secret"})
+
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`, nil)
+	if n := strings.Count(rec.Body.String(), `"content":"Hello"`); n != 1 {
+		t.Errorf("expected the reply once, got it %d times: %s", n, rec.Body.String())
+	}
+}
+
+func TestHandler_StreamSendsKeepalivesWhileTheAgentWorks(t *testing.T) {
+	backend := &fakeBackend{reply: "done", delay: 100 * time.Millisecond}
+	h := NewHandler(backend, []string{"secret"})
+	h.keepalive = 10 * time.Millisecond
+
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`, nil)
+	body := rec.Body.String()
+	ping := strings.Index(body, ": ping\n\n")
+	if ping < 0 || ping > strings.Index(body, `"content":"done"`) {
+		t.Errorf("expected keepalive comments before the reply, got %s", body)
+	}
+	if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("expected the stream to end with [DONE], got %s", body)
+	}
+}
@@ -0,0 +1,294 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultCallTimeout    = 60 * time.Second
+	defaultConnectTimeout = 30 * time.Second
+	defaultRetryDelay     = 5 * time.Second
+	maxRetryDelay         = 5 * time.Minute
+)
+
+// Client is a connection to one MCP server. A lost connection (the stdio
+// process exited, or the HTTP session expired) is re-established on the
+// next call.
+type Client struct {
+	name string
+	cfg  config.MCPServerConfig
+
+	mu    sync.Mutex
+	conn  transport
+	tools []Tool
+}
+
+// NewClient returns an unconnected client for the named server.
+func NewClient(name string, cfg config.MCPServerConfig) *Client {
+	return &Client{name: name, cfg: cfg}
+}
+
+// Name returns the server's name from config.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Connect starts the server or opens the session, performs the initialize
+// handshake, and lists the server's tools.
+func (c *Client) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.connectLocked(ctx)
+	return err
+}
+
+func (c *Client) connectLocked(ctx context.Context) (transport, error) {
+	if c.conn != nil {
+		c.conn.close()
+		c.conn = nil
+	}
+
+	var conn transport
+	switch {
+	case c.cfg.Command != "":
+		t, err := startStdio(c.name, c.cfg)
+		if err != nil {
+			return nil, err
+		}
+		conn = t
+	case c.cfg.URL != "":
+		conn = newHTTPTransport(c.cfg)
+	default:
+		return nil, fmt.Errorf("mcp server %s: command or url is required", c.name)
+	}
+
+	if _, err := conn.call(ctx, "initialize", map[string]any{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "picoclaw", "version": "1.0"},
+	}); err != nil {
+		conn.close()
+		return nil, fmt.Errorf("mcp server %s: initialize: %w", c.name, err)
+	}
+	if err := conn.notify(ctx, "notifications/initialized", nil); err != nil {
+		conn.close()
+		return nil, fmt.Errorf("mcp server %s: %w", c.name, err)
+	}
+
+	tools, err := listTools(ctx, conn)
+	if err != nil {
+		conn.close()
+		return nil, fmt.Errorf("mcp server %s: tools/list: %w", c.name, err)
+	}
+
+	c.conn = conn
+	c.tools = tools
+	logger.InfoCF("mcp", "Connected to MCP server", map[string]any{
+		"server": c.name,
+		"tools":  len(tools),
+	})
+	return conn, nil
+}
+
+func listTools(ctx context.Context, conn transport) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var params any
+		if cursor != "" {
+			params = map[string]any{"cursor": cursor}
+		}
+		raw, err := conn.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// Tools returns the tools listed on the last successful connect.
+func (c *Client) Tools() []Tool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Tool(nil), c.tools...)
+}
+
+// CallTool invokes a tool on the server, reconnecting first if the
+// connection was lost. A call that fails in flight is not retried, since
+// the tool may already have had side effects.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallResult, error) {
+	timeout := defaultCallTimeout
+	if c.cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(c.cfg.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c.mu.Lock()
+	conn := c.conn
+	if conn == nil || !conn.alive() {
+		logger.InfoCF("mcp", "Reconnecting to MCP server", map[string]any{"server": c.name})
+		var err error
+		if conn, err = c.connectLocked(ctx); err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+	}
+	c.mu.Unlock()
+
+	if args == nil {
+		args = map[string]any{}
+	}
+	raw, err := conn.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args})
+	if err != nil {
+		return nil, err
+	}
+	var result CallResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("invalid tools/call result: %w", err)
+	}
+	return &result, nil
+}
+
+// Close disconnects from the server, stopping it if it is a child process.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.close()
+	c.conn = nil
+	return err
+}
+
+// Manager owns the clients of all configured servers.
+type Manager struct {
+	clients    []*Client
+	retryDelay time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager returns a manager for the enabled servers in servers.
+func NewManager(servers map[string]config.MCPServerConfig) *Manager {
+	names := make([]string, 0, len(servers))
+	for name, cfg := range servers {
+		if !cfg.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	m := &Manager{retryDelay: defaultRetryDelay}
+	for _, name := range names {
+		m.clients = append(m.clients, NewClient(name, servers[name]))
+	}
+	return m
+}
+
+// Start connects to all servers in parallel and calls onConnect for each
+// one that succeeds. Servers that fail to connect are retried in the
+// background, with growing delays, until they connect (onConnect is called
+// then) or the manager is closed. onConnect may run concurrently.
+func (m *Manager) Start(ctx context.Context, onConnect func(*Client)) {
+	retryCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	m.cancel = cancel
+
+	connectCtx, cancelConnect := context.WithTimeout(ctx, defaultConnectTimeout)
+	defer cancelConnect()
+
+	connected := make([]bool, len(m.clients))
+	var wg sync.WaitGroup
+	for i, c := range m.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Connect(connectCtx); err != nil {
+				logger.ErrorCF("mcp", "Failed to connect to MCP server, will retry", map[string]any{
+					"server": c.name,
+					"error":  err.Error(),
+				})
+				return
+			}
+			connected[i] = true
+		}()
+	}
+	wg.Wait()
+
+	for i, c := range m.clients {
+		if connected[i] {
+			onConnect(c)
+			continue
+		}
+		m.wg.Add(1)
+		go m.retry(retryCtx, c, onConnect)
+	}
+}
+
+// retry reconnects c until it succeeds or ctx is cancelled.
+func (m *Manager) retry(ctx context.Context, c *Client, onConnect func(*Client)) {
+	defer m.wg.Done()
+	delay := m.retryDelay
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		connectCtx, cancel := context.WithTimeout(ctx, defaultConnectTimeout)
+		err := c.Connect(connectCtx)
+		cancel()
+		if err == nil {
+			onConnect(c)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("mcp", "MCP server still unreachable", map[string]any{
+			"server": c.name,
+			"error":  err.Error(),
+			"retry":  delay.String(),
+		})
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// Clients returns the clients of all enabled servers, ordered by server
+// name, whether or not they are connected yet.
+func (m *Manager) Clients() []*Client {
+	return m.clients
+}
+
+// Close stops retrying and disconnects from all servers.
+func (m *Manager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+	for _, c := range m.clients {
+		c.Close()
+	}
+}
@@ -0,0 +1,215 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// The test binary doubles as a stdio MCP server when MCP_TEST_SERVER is set.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_TEST_SERVER") == "1" {
+		runTestServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testServerResult answers one request of the fake server.
+func testServerResult(method string, params map[string]any) any {
+	switch method {
+	case "initialize":
+		return map[string]any{"protocolVersion": protocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}}
+	case "tools/list":
+		if params["cursor"] == nil {
+			return map[string]any{
+				"tools":      []any{map[string]any{"name": "echo", "description": "Echo text", "inputSchema": map[string]any{"type": "object"}}},
+				"nextCursor": "page2",
+			}
+		}
+		return map[string]any{"tools": []any{map[string]any{"name": "crash"}}}
+	case "tools/call":
+		args, _ := params["arguments"].(map[string]any)
+		return map[string]any{"content": []any{map[string]any{"type": "text", "text": fmt.Sprint(args["text"])}}}
+	}
+	return nil
+}
+
+func runTestServer() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params map[string]any  `json:"params"`
+		}
+		json.Unmarshal(scanner.Bytes(), &req)
+		if len(req.ID) == 0 {
+			continue
+		}
+		if req.Method == "tools/call" && req.Params["name"] == "crash" {
+			os.Exit(1)
+		}
+		data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": testServerResult(req.Method, req.Params)})
+		fmt.Println(string(data))
+	}
+}
+
+func TestClient_StdioListsAndCallsTools(t *testing.T) {
+	t.Setenv("MCP_TEST_SERVER", "1")
+	client := NewClient("test", config.MCPServerConfig{Command: os.Args[0]})
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer client.Close()
+
+	tools := client.Tools()
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[1].Name != "crash" {
+		t.Fatalf("expected both pages of tools, got %+v", tools)
+	}
+
+	result, err := client.CallTool(context.Background(), "echo", map[string]any{"text": "hello"})
+	if err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	if result.Text() != "hello" {
+		t.Errorf("result = %q, want hello", result.Text())
+	}
+
+	// The server dies mid-call; the next call starts a new one.
+	if _, err := client.CallTool(context.Background(), "crash", nil); err == nil {
+		t.Fatal("expected an error when the server exits")
+	}
+	result, err = client.CallTool(context.Background(), "echo", map[string]any{"text": "again"})
+	if err != nil {
+		t.Fatalf("CallTool() after crash error: %v", err)
+	}
+	if result.Text() != "again" {
+		t.Errorf("result = %q, want again", result.Text())
+	}
+}
+
+func TestClient_StreamableHTTP(t *testing.T) {
+	var sessions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params map[string]any  `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		sessions = append(sessions, r.Header.Get(sessionIDHeader))
+
+		if len(req.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		resp, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": testServerResult(req.Method, req.Params)})
+		if req.Method == "initialize" {
+			w.Header().Set(sessionIDHeader, "sess-1")
+		}
+		if req.Method == "tools/call" {
+			// Answer over SSE, after an unrelated notification.
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", resp)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient("remote", config.MCPServerConfig{URL: server.URL})
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error: %v", err)
+	}
+	defer client.Close()
+
+	result, err := client.CallTool(context.Background(), "echo", map[string]any{"text": "over http"})
+	if err != nil {
+		t.Fatalf("CallTool() error: %v", err)
+	}
+	if result.Text() != "over http" {
+		t.Errorf("result = %q", result.Text())
+	}
+	if sessions[0] != "" || sessions[len(sessions)-1] != "sess-1" {
+		t.Errorf("expected the session ID on requests after initialize, got %v", sessions)
+	}
+}
+
+func TestManager_SkipsDisabledAndKeepsUnreachableServers(t *testing.T) {
+	m := NewManager(map[string]config.MCPServerConfig{
+		"off":     {Disabled: true, Command: "true"},
+		"missing": {Command: "/nonexistent/mcp-server"},
+	})
+	if len(m.Clients()) != 1 {
+		t.Fatalf("expected only the enabled server, got %d", len(m.Clients()))
+	}
+	var connected []string
+	m.Start(context.Background(), func(c *Client) { connected = append(connected, c.Name()) })
+	defer m.Close()
+	if len(connected) != 0 {
+		t.Errorf("expected no server to connect, got %v", connected)
+	}
+	if len(m.Clients()) != 1 {
+		t.Errorf("expected the unreachable server to be kept for retries, got %d", len(m.Clients()))
+	}
+}
+
+func TestManager_RetriesServersThatFailAtStartup(t *testing.T) {
+	var up atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params map[string]any  `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": testServerResult(req.Method, req.Params)})
+	}))
+	defer server.Close()
+
+	m := NewManager(map[string]config.MCPServerConfig{"late": {URL: server.URL}})
+	m.retryDelay = 10 * time.Millisecond
+	connected := make(chan *Client, 1)
+	m.Start(context.Background(), func(c *Client) { connected <- c })
+	defer m.Close()
+
+	select {
+	case c := <-connected:
+		t.Fatalf("%s connected while the server was down", c.Name())
+	default:
+	}
+
+	up.Store(true)
+	select {
+	case c := <-connected:
+		if len(c.Tools()) != 2 {
+			t.Errorf("expected the tools to be listed on reconnect, got %+v", c.Tools())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server was not retried after it came up")
+	}
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters (standard Okapi values).
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 is an in-memory Okapi BM25 index over a fixed set of documents.
+type bm25 struct {
+	termFreqs []map[string]int
+	docLens   []int
+	avgLen    float64
+	docFreq   map[string]int
+}
+
+func newBM25(texts []string) *bm25 {
+	idx := &bm25{
+		termFreqs: make([]map[string]int, len(texts)),
+		docLens:   make([]int, len(texts)),
+		docFreq:   make(map[string]int),
+	}
+
+	total := 0
+	for i, text := range texts {
+		tf := make(map[string]int)
+		terms := tokenize(text)
+		for _, term := range terms {
+			tf[term]++
+		}
+		for term := range tf {
+			idx.docFreq[term]++
+		}
+		idx.termFreqs[i] = tf
+		idx.docLens[i] = len(terms)
+		total += len(terms)
+	}
+	if len(texts) > 0 {
+		idx.avgLen = float64(total) / float64(len(texts))
+	}
+	return idx
+}
+
+// score returns the BM25 score of every document for query.
+func (idx *bm25) score(query string) []float64 {
+	scores := make([]float64, len(idx.termFreqs))
+	if idx.avgLen == 0 {
+		return scores
+	}
+
+	n := float64(len(idx.termFreqs))
+	seen := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		df := float64(idx.docFreq[term])
+		if df == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for i, tf := range idx.termFreqs {
+			f := float64(tf[term])
+			if f == 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(idx.docLens[i])/idx.avgLen
+			scores[i] += idf * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+	}
+	return scores
+}
+
+// tokenize lowercases text and splits it into terms. Letters and digits form
+// words; Han, Hiragana, Katakana and Hangul characters are indexed one rune
+// per term since those scripts are not space-separated.
+func tokenize(text string) []string {
+	var terms []string
+	var word strings.Builder
+
+	flush := func() {
+		if word.Len() > 1 {
+			terms = append(terms, word.String())
+		}
+		word.Reset()
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
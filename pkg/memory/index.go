@@ -0,0 +1,383 @@
+// Package memory provides retrieval over the agent's long-term memory files:
+// MEMORY.md, daily notes and archived conversation chunks.
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Snippet sizing. Files are split at Markdown headings and, once a snippet
+// grows past maxSnippetChars, at the next blank line.
+const (
+	maxSnippetChars  = 800
+	hardSnippetChars = 2000
+)
+
+// embedBatchSize is the number of snippets sent per embedding request.
+const embedBatchSize = 32
+
+// Result is one snippet matching a search.
+type Result struct {
+	Source  string  // Path relative to the workspace
+	Line    int     // 1-based line the snippet starts at
+	Snippet string  // Snippet text
+	Score   float64 // Relevance; only comparable within one search
+}
+
+// Embedder turns texts into vectors for semantic search.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+type document struct {
+	source string
+	line   int
+	text   string
+	hash   string
+}
+
+// Index searches the Markdown files under a set of directories. It is
+// rebuilt lazily whenever a file is added, removed or modified, so callers
+// never need to notify it of writes.
+type Index struct {
+	workspace string
+	roots     []string
+
+	mu        sync.Mutex
+	signature string
+	docs      []document
+	lexical   *bm25
+
+	embedder  Embedder
+	cacheFile string
+	vectors   map[string][]float32
+}
+
+// NewIndex creates an index over the *.md files under dirs. Relative
+// directories are resolved against workspace, which is also the base for
+// Result.Source.
+func NewIndex(workspace string, dirs ...string) *Index {
+	var roots []string
+	for _, dir := range dirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workspace, dir)
+		}
+		roots = append(roots, filepath.Clean(dir))
+	}
+	return &Index{workspace: workspace, roots: roots}
+}
+
+// SetEmbedder enables hybrid search: results are ranked by a blend of BM25
+// and embedding similarity. Snippet vectors are cached in cacheFile (if set)
+// so only new or changed snippets are embedded.
+func (ix *Index) SetEmbedder(e Embedder, cacheFile string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.embedder = e
+	ix.cacheFile = cacheFile
+	ix.vectors = nil
+}
+
+// Search returns up to limit snippets relevant to query, best first.
+func (ix *Index) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if err := ix.refresh(); err != nil {
+		return nil, err
+	}
+	if len(ix.docs) == 0 {
+		return nil, nil
+	}
+
+	scores := ix.lexical.score(query)
+	if ix.embedder != nil {
+		semantic, err := ix.semanticScores(ctx, query)
+		if err != nil {
+			logger.WarnCF("memory", "Embedding search failed, using keyword search only",
+				map[string]any{"error": err.Error()})
+		} else {
+			scores = blendScores(scores, semantic)
+		}
+	}
+
+	order := make([]int, 0, len(scores))
+	for i, score := range scores {
+		if score > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	if len(order) > limit {
+		order = order[:limit]
+	}
+
+	results := make([]Result, 0, len(order))
+	for _, i := range order {
+		doc := ix.docs[i]
+		results = append(results, Result{
+			Source:  doc.source,
+			Line:    doc.line,
+			Snippet: doc.text,
+			Score:   scores[i],
+		})
+	}
+	return results, nil
+}
+
+// refresh rebuilds the index when the set of files or their mtimes changed.
+func (ix *Index) refresh() error {
+	files, signature, err := ix.scan()
+	if err != nil {
+		return err
+	}
+	if signature == ix.signature && ix.lexical != nil {
+		return nil
+	}
+
+	var docs []document
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		source, err := filepath.Rel(ix.workspace, path)
+		if err != nil {
+			source = path
+		}
+		docs = append(docs, splitDocument(filepath.ToSlash(source), string(data))...)
+	}
+
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.text
+	}
+
+	ix.docs = docs
+	ix.lexical = newBM25(texts)
+	ix.signature = signature
+	return nil
+}
+
+// scan lists the Markdown files under the index roots, skipping hidden
+// entries, along with a signature that changes whenever any of them does.
+func (ix *Index) scan() ([]string, string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	var sig strings.Builder
+
+	for _, root := range ix.roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") || seen[path] {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			seen[path] = true
+			files = append(files, path)
+			fmt.Fprintf(&sig, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("scanning %s: %w", root, err)
+		}
+	}
+	return files, sig.String(), nil
+}
+
+// splitDocument cuts a Markdown file into snippets.
+func splitDocument(source, content string) []document {
+	var docs []document
+	var current []string
+	start := 1
+
+	emit := func() {
+		text := strings.TrimSpace(strings.Join(current, "\n"))
+		if text != "" {
+			sum := sha256.Sum256([]byte(text))
+			docs = append(docs, document{
+				source: source,
+				line:   start,
+				text:   text,
+				hash:   hex.EncodeToString(sum[:]),
+			})
+		}
+		current = current[:0]
+	}
+
+	size := 0
+	for i, line := range strings.Split(content, "\n") {
+		isHeading := strings.HasPrefix(line, "#")
+		isBreak := strings.TrimSpace(line) == "" && size >= maxSnippetChars
+		if isHeading || isBreak || size+len(line) > hardSnippetChars {
+			emit()
+			size = 0
+		}
+		if len(current) == 0 {
+			start = i + 1
+		}
+		current = append(current, line)
+		size += len(line) + 1
+	}
+	emit()
+	return docs
+}
+
+// semanticScores returns the cosine similarity between query and every
+// document, embedding any documents not yet in the vector cache.
+func (ix *Index) semanticScores(ctx context.Context, query string) ([]float64, error) {
+	if ix.vectors == nil {
+		ix.vectors = ix.loadVectors()
+	}
+
+	var missing []document
+	for _, doc := range ix.docs {
+		if _, ok := ix.vectors[doc.hash]; !ok {
+			missing = append(missing, doc)
+		}
+	}
+	for start := 0; start < len(missing); start += embedBatchSize {
+		batch := missing[start:min(start+embedBatchSize, len(missing))]
+		texts := make([]string, len(batch))
+		for i, doc := range batch {
+			texts[i] = doc.text
+		}
+		vectors, err := ix.embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) != len(batch) {
+			return nil, fmt.Errorf("embedder returned %d vectors for %d inputs", len(vectors), len(batch))
+		}
+		for i, doc := range batch {
+			ix.vectors[doc.hash] = vectors[i]
+		}
+	}
+	if len(missing) > 0 {
+		ix.saveVectors()
+	}
+
+	queryVectors, err := ix.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(queryVectors) != 1 {
+		return nil, fmt.Errorf("embedder returned %d vectors for the query", len(queryVectors))
+	}
+
+	scores := make([]float64, len(ix.docs))
+	for i, doc := range ix.docs {
+		scores[i] = cosine(queryVectors[0], ix.vectors[doc.hash])
+	}
+	return scores, nil
+}
+
+func (ix *Index) loadVectors() map[string][]float32 {
+	vectors := make(map[string][]float32)
+	if ix.cacheFile == "" {
+		return vectors
+	}
+	data, err := os.ReadFile(ix.cacheFile)
+	if err != nil {
+		return vectors
+	}
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		logger.WarnCF("memory", "Ignoring unreadable embedding cache",
+			map[string]any{"path": ix.cacheFile, "error": err.Error()})
+		return make(map[string][]float32)
+	}
+	return vectors
+}
+
+// saveVectors writes the vectors of the current documents to the cache,
+// dropping those of snippets that no longer exist.
+func (ix *Index) saveVectors() {
+	if ix.cacheFile == "" {
+		return
+	}
+	live := make(map[string][]float32, len(ix.docs))
+	for _, doc := range ix.docs {
+		if v, ok := ix.vectors[doc.hash]; ok {
+			live[doc.hash] = v
+		}
+	}
+	ix.vectors = live
+
+	data, err := json.Marshal(live)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(ix.cacheFile), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(ix.cacheFile, data, 0o644)
+	}
+	if err != nil {
+		logger.WarnCF("memory", "Failed to write embedding cache",
+			map[string]any{"path": ix.cacheFile, "error": err.Error()})
+	}
+}
+
+// blendScores combines max-normalized BM25 scores with cosine similarities.
+// Keyword matches keep results grounded; similarity finds paraphrases.
+func blendScores(lexical, semantic []float64) []float64 {
+	maxLexical := 0.0
+	for _, s := range lexical {
+		maxLexical = math.Max(maxLexical, s)
+	}
+
+	blended := make([]float64, len(lexical))
+	for i := range lexical {
+		l := 0.0
+		if maxLexical > 0 {
+			l = lexical[i] / maxLexical
+		}
+		blended[i] = 0.5*l + 0.5*math.Max(semantic[i], 0)
+	}
+	return blended
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
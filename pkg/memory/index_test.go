@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndexSearch_RanksRelevantSnippetFirst(t *testing.T) {
+	workspace := t.TempDir()
+	writeFile(t, filepath.Join(workspace, "memory", "MEMORY.md"),
+		"# Preferences\n\nUser likes oat milk in their coffee.\n\n# Devices\n\nThe router is a Netgear in the hallway.\n")
+	writeFile(t, filepath.Join(workspace, "memory", "202601", "20260105.md"),
+		"# 2026-01-05\n\nFixed the garden irrigation timer.\n")
+	writeFile(t, filepath.Join(workspace, "memory", ".embeddings", "x.md"), "coffee coffee coffee")
+
+	ix := NewIndex(workspace, "memory")
+	results, err := ix.Search(context.Background(), "coffee milk", 5)
+	if err != nil {
+		t.Fatalf("Search() error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d: %+v", len(results), results)
+	}
+	if results[0].Source != "memory/MEMORY.md" || results[0].Line != 1 {
+		t.Errorf("unexpected result location %s:%d", results[0].Source, results[0].Line)
+	}
+	if !strings.Contains(results[0].Snippet, "oat milk") {
+		t.Errorf("unexpected snippet %q", results[0].Snippet)
+	}
+}
+
+func TestIndexSearch_PicksUpFileChanges(t *testing.T) {
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "memory", "MEMORY.md")
+	writeFile(t, path, "Nothing here yet.\n")
+
+	ix := NewIndex(workspace, "memory")
+	if results, _ := ix.Search(context.Background(), "birthday", 5); len(results) != 0 {
+		t.Fatalf("expected no results, got %+v", results)
+	}
+
+	writeFile(t, path, "Nothing here yet.\n\n# Family\n\nSister's birthday is March 3.\n")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+
+	results, err := ix.Search(context.Background(), "birthday", 5)
+	if err != nil {
+		t.Fatalf("Search() error: %v", err)
+	}
+	if len(results) != 1 || results[0].Line != 3 {
+		t.Fatalf("expected the new section to be found, got %+v", results)
+	}
+}
+
+func TestTokenize_SplitsCJKPerRune(t *testing.T) {
+	got := tokenize("Hello, 世界! v2 a")
+	want := []string{"hello", "世", "界", "v2"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("tokenize() = %v, want %v", got, want)
+	}
+}
+
+// keywordEmbedder maps texts to vectors by a fixed vocabulary, so that
+// "car" and "automobile" land on the same axis.
+type keywordEmbedder struct {
+	calls int
+}
+
+func (e *keywordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		text = strings.ToLower(text)
+		v := []float32{0, 0}
+		if strings.Contains(text, "car") || strings.Contains(text, "automobile") {
+			v[0] = 1
+		}
+		if strings.Contains(text, "cat") {
+			v[1] = 1
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+func TestIndexSearch_EmbeddingsFindParaphrases(t *testing.T) {
+	workspace := t.TempDir()
+	writeFile(t, filepath.Join(workspace, "memory", "MEMORY.md"),
+		"# Vehicles\n\nThe car is parked in garage two.\n\n# Pets\n\nThe cat is called Miso.\n")
+
+	cacheFile := filepath.Join(workspace, "memory", ".embeddings", "test.json")
+	embedder := &keywordEmbedder{}
+	ix := NewIndex(workspace, "memory")
+	ix.SetEmbedder(embedder, cacheFile)
+
+	results, err := ix.Search(context.Background(), "automobile", 1)
+	if err != nil {
+		t.Fatalf("Search() error: %v", err)
+	}
+	if len(results) != 1 || !strings.Contains(results[0].Snippet, "garage") {
+		t.Fatalf("expected the car snippet, got %+v", results)
+	}
+	if _, err := os.Stat(cacheFile); err != nil {
+		t.Errorf("expected embedding cache to be written: %v", err)
+	}
+
+	// A fresh index reuses cached snippet vectors and only embeds the query.
+	embedder.calls = 0
+	ix = NewIndex(workspace, "memory")
+	ix.SetEmbedder(embedder, cacheFile)
+	if _, err := ix.Search(context.Background(), "automobile", 1); err != nil {
+		t.Fatalf("Search() error: %v", err)
+	}
+	if embedder.calls != 1 {
+		t.Errorf("expected only the query to be embedded, got %d calls", embedder.calls)
+	}
+}
@@ -71,13 +71,9 @@ func (p *Provider) Chat(
 	model string,
 	options map[string]any,
 ) (*LLMResponse, error) {
-	var opts []option.RequestOption
-	if p.tokenSource != nil {
-		tok, err := p.tokenSource()
-		if err != nil {
-			return nil, fmt.Errorf("refreshing token: %w", err)
-		}
-		opts = append(opts, option.WithAuthToken(tok))
+	opts, err := p.requestOptions()
+	if err != nil {
+		return nil, err
 	}
 
 	params, err := buildParams(messages, tools, model, options)
@@ -93,6 +89,86 @@ func (p *Provider) Chat(
 	return parseResponse(resp), nil
 }
 
+// ChatStream streams the response through the Messages streaming API,
+// reporting text and tool input fragments through onDelta.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(protocoltypes.StreamDelta),
+) (*LLMResponse, error) {
+	opts, err := p.requestOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := buildParams(messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := p.client.Messages.NewStreaming(ctx, params, opts...)
+	defer stream.Close()
+
+	message := anthropic.Message{}
+	for stream.Next() {
+		event := stream.Current()
+		if err := message.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("claude API stream: %w", err)
+		}
+		if onDelta != nil {
+			if delta, ok := streamDelta(event); ok {
+				onDelta(delta)
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("claude API call: %w", err)
+	}
+
+	return parseResponse(&message), nil
+}
+
+// streamDelta maps a stream event to a text or tool-call fragment.
+func streamDelta(event anthropic.MessageStreamEventUnion) (protocoltypes.StreamDelta, bool) {
+	switch ev := event.AsAny().(type) {
+	case anthropic.ContentBlockStartEvent:
+		if tu, ok := ev.ContentBlock.AsAny().(anthropic.ToolUseBlock); ok {
+			return protocoltypes.StreamDelta{ToolCall: &protocoltypes.ToolCallDelta{
+				Index: int(ev.Index),
+				ID:    tu.ID,
+				Name:  tu.Name,
+			}}, true
+		}
+	case anthropic.ContentBlockDeltaEvent:
+		switch d := ev.Delta.AsAny().(type) {
+		case anthropic.TextDelta:
+			return protocoltypes.StreamDelta{Content: d.Text}, true
+		case anthropic.InputJSONDelta:
+			return protocoltypes.StreamDelta{ToolCall: &protocoltypes.ToolCallDelta{
+				Index:     int(ev.Index),
+				Arguments: d.PartialJSON,
+			}}, true
+		}
+	}
+	return protocoltypes.StreamDelta{}, false
+}
+
+// requestOptions refreshes the auth token when a token source is configured.
+func (p *Provider) requestOptions() ([]option.RequestOption, error) {
+	var opts []option.RequestOption
+	if p.tokenSource != nil {
+		tok, err := p.tokenSource()
+		if err != nil {
+			return nil, fmt.Errorf("refreshing token: %w", err)
+		}
+		opts = append(opts, option.WithAuthToken(tok))
+	}
+	return opts, nil
+}
+
 func (p *Provider) GetDefaultModel() string {
 	return "claude-sonnet-4.6"
 }
@@ -119,6 +195,8 @@ func buildParams(
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 				)
+			} else if len(msg.Parts) > 0 {
+				anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(translateParts(msg.Parts)...))
 			} else {
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)),
@@ -172,6 +250,36 @@ func buildParams(
 	return params, nil
 }
 
+// translateParts maps content parts to Anthropic blocks. Local and inline
+// images are sent as base64, remote images by URL.
+func translateParts(parts []protocoltypes.ContentPart) []anthropic.ContentBlockParamUnion {
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case protocoltypes.ContentPartText:
+			if part.Text != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(part.Text))
+			}
+		case protocoltypes.ContentPartImage:
+			if part.Image == nil {
+				continue
+			}
+			if part.Image.URL != "" && part.Image.Path == "" && part.Image.Data == "" {
+				blocks = append(blocks, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: part.Image.URL}))
+				continue
+			}
+			mediaType, data, err := part.Image.Inline()
+			if err != nil {
+				log.Printf("anthropic: skipping image: %v", err)
+				blocks = append(blocks, anthropic.NewTextBlock("[image unavailable]"))
+				continue
+			}
+			blocks = append(blocks, anthropic.NewImageBlockBase64(mediaType, data))
+		}
+	}
+	return blocks
+}
+
 func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 	result := make([]anthropic.ToolUnionParam, 0, len(tools))
 	for _, t := range tools {
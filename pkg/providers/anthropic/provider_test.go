@@ -2,13 +2,18 @@ package anthropicprovider
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 func TestBuildParams_BasicMessage(t *testing.T) {
@@ -77,6 +82,42 @@ func TestBuildParams_ToolCallMessage(t *testing.T) {
 	}
 }
 
+func TestBuildParams_ImageParts(t *testing.T) {
+	imgPath := filepath.Join(t.TempDir(), "shot.png")
+	if err := os.WriteFile(imgPath, []byte("\x89PNG\r\n\x1a\n0000"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []Message{
+		{Role: "user", Content: "Describe", Parts: []protocoltypes.ContentPart{
+			{Type: protocoltypes.ContentPartText, Text: "Describe"},
+			{Type: protocoltypes.ContentPartImage, Image: &protocoltypes.ImageSource{Path: imgPath}},
+			{Type: protocoltypes.ContentPartImage, Image: &protocoltypes.ImageSource{URL: "https://example.com/a.jpg"}},
+		}},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	blocks := params.Messages[0].Content
+	if len(blocks) != 3 {
+		t.Fatalf("len(Content) = %d, want 3", len(blocks))
+	}
+	if blocks[0].OfText == nil || blocks[0].OfText.Text != "Describe" {
+		t.Errorf("Content[0] should be the text block")
+	}
+	if blocks[1].OfImage == nil || blocks[1].OfImage.Source.OfBase64 == nil {
+		t.Fatalf("Content[1] should be a base64 image block")
+	}
+	if got := blocks[1].OfImage.Source.OfBase64.MediaType; got != "image/png" {
+		t.Errorf("MediaType = %q, want image/png", got)
+	}
+	if blocks[2].OfImage == nil || blocks[2].OfImage.Source.OfURL == nil ||
+		blocks[2].OfImage.Source.OfURL.URL != "https://example.com/a.jpg" {
+		t.Errorf("Content[2] should be a URL image block")
+	}
+}
+
 func TestBuildParams_WithTools(t *testing.T) {
 	tools := []ToolDefinition{
 		{
@@ -193,6 +234,67 @@ func TestProvider_ChatRoundTrip(t *testing.T) {
 	}
 }
 
+func TestProvider_ChatStream(t *testing.T) {
+	events := []string{
+		`event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4.6","content":[],"stop_reason":null,"usage":{"input_tokens":12,"output_tokens":0}}}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking "}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"weather"}}`,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":0}`,
+		`event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"SF\"}"}}`,
+		`event: content_block_stop
+data: {"type":"content_block_stop","index":1}`,
+		`event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+		`event: message_stop
+data: {"type":"message_stop"}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			fmt.Fprintf(w, "%s\n\n", ev)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewProviderWithClient(createAnthropicTestClient(server.URL, "test-token"))
+	var text string
+	var toolDeltas []protocoltypes.ToolCallDelta
+	resp, err := provider.ChatStream(t.Context(), []Message{{Role: "user", Content: "Weather?"}}, nil,
+		"claude-sonnet-4.6", map[string]any{}, func(d protocoltypes.StreamDelta) {
+			text += d.Content
+			if d.ToolCall != nil {
+				toolDeltas = append(toolDeltas, *d.ToolCall)
+			}
+		})
+	if err != nil {
+		t.Fatalf("ChatStream() error: %v", err)
+	}
+	if text != "Checking weather" || resp.Content != "Checking weather" {
+		t.Errorf("streamed %q, final %q, want %q", text, resp.Content, "Checking weather")
+	}
+	if len(toolDeltas) != 3 || toolDeltas[0].Name != "get_weather" || toolDeltas[0].ID != "toolu_1" {
+		t.Errorf("tool deltas = %+v", toolDeltas)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Arguments["city"] != "SF" {
+		t.Fatalf("ToolCalls = %+v", resp.ToolCalls)
+	}
+	if resp.FinishReason != "tool_calls" {
+		t.Errorf("FinishReason = %q, want tool_calls", resp.FinishReason)
+	}
+}
+
 func TestProvider_GetDefaultModel(t *testing.T) {
 	p := NewProvider("test-token")
 	if got := p.GetDefaultModel(); got != "claude-sonnet-4.6" {
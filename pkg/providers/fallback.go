@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 // FallbackChain orchestrates model fallback across multiple candidates.
@@ -14,8 +16,9 @@ type FallbackChain struct {
 
 // FallbackCandidate represents one model/provider to try.
 type FallbackCandidate struct {
-	Provider string
-	Model    string
+	Provider  string
+	Model     string
+	ModelName string // model_list entry serving this candidate; empty if not from model_list
 }
 
 // FallbackResult contains the successful response and metadata about all attempts.
@@ -36,6 +39,16 @@ type FallbackAttempt struct {
 	Skipped  bool // true if skipped due to cooldown
 }
 
+// cooldownKey identifies the candidate in the cooldown tracker. model_list
+// candidates cool down on their own, since entries sharing a protocol can be
+// backed by unrelated accounts or endpoints.
+func (c FallbackCandidate) cooldownKey() string {
+	if c.ModelName != "" {
+		return c.ModelName
+	}
+	return c.Provider
+}
+
 // NewFallbackChain creates a new fallback chain with the given cooldown tracker.
 func NewFallbackChain(cooldown *CooldownTracker) *FallbackChain {
 	return &FallbackChain{cooldown: cooldown}
@@ -43,23 +56,41 @@ func NewFallbackChain(cooldown *CooldownTracker) *FallbackChain {
 
 // ResolveCandidates parses model config into a deduplicated candidate list.
 func ResolveCandidates(cfg ModelConfig, defaultProvider string) []FallbackCandidate {
+	return ResolveModelListCandidates(cfg, defaultProvider, nil)
+}
+
+// ResolveModelListCandidates is like ResolveCandidates, but first looks each
+// reference up in modelList, by model_name or by its protocol/model string.
+// Candidates found there carry the entry's ModelName so each attempt can be
+// dispatched to that entry's own provider.
+func ResolveModelListCandidates(
+	cfg ModelConfig,
+	defaultProvider string,
+	modelList []config.ModelConfig,
+) []FallbackCandidate {
 	seen := make(map[string]bool)
 	var candidates []FallbackCandidate
 
 	addCandidate := func(raw string) {
-		ref := ParseModelRef(raw, defaultProvider)
-		if ref == nil {
-			return
+		candidate, ok := lookupModelList(raw, modelList)
+		if !ok {
+			ref := ParseModelRef(raw, defaultProvider)
+			if ref == nil {
+				return
+			}
+			candidate = FallbackCandidate{Provider: ref.Provider, Model: ref.Model}
+		}
+		// model_list entries are told apart by name: two of them may serve
+		// the same model through different endpoints or keys.
+		key := candidate.ModelName
+		if key == "" {
+			key = ModelKey(candidate.Provider, candidate.Model)
 		}
-		key := ModelKey(ref.Provider, ref.Model)
 		if seen[key] {
 			return
 		}
 		seen[key] = true
-		candidates = append(candidates, FallbackCandidate{
-			Provider: ref.Provider,
-			Model:    ref.Model,
-		})
+		candidates = append(candidates, candidate)
 	}
 
 	// Primary first.
@@ -73,6 +104,41 @@ func ResolveCandidates(cfg ModelConfig, defaultProvider string) []FallbackCandid
 	return candidates
 }
 
+// lookupModelList finds the model_list entry a reference points at.
+func lookupModelList(raw string, modelList []config.ModelConfig) (FallbackCandidate, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return FallbackCandidate{}, false
+	}
+
+	match := -1
+	for i := range modelList {
+		if modelList[i].ModelName == raw {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		for i := range modelList {
+			if modelList[i].Model == raw {
+				match = i
+				break
+			}
+		}
+	}
+	if match < 0 {
+		return FallbackCandidate{}, false
+	}
+
+	entry := modelList[match]
+	protocol, modelID := ExtractProtocol(entry.Model)
+	return FallbackCandidate{
+		Provider:  NormalizeProvider(protocol),
+		Model:     modelID,
+		ModelName: entry.ModelName,
+	}, true
+}
+
 // Execute runs the fallback chain for text/chat requests.
 // It tries each candidate in order, respecting cooldowns and error classification.
 //
@@ -86,7 +152,7 @@ func ResolveCandidates(cfg ModelConfig, defaultProvider string) []FallbackCandid
 func (fc *FallbackChain) Execute(
 	ctx context.Context,
 	candidates []FallbackCandidate,
-	run func(ctx context.Context, provider, model string) (*LLMResponse, error),
+	run func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error),
 ) (*FallbackResult, error) {
 	if len(candidates) == 0 {
 		return nil, fmt.Errorf("fallback: no candidates configured")
@@ -103,8 +169,8 @@ func (fc *FallbackChain) Execute(
 		}
 
 		// Check cooldown.
-		if !fc.cooldown.IsAvailable(candidate.Provider) {
-			remaining := fc.cooldown.CooldownRemaining(candidate.Provider)
+		if !fc.cooldown.IsAvailable(candidate.cooldownKey()) {
+			remaining := fc.cooldown.CooldownRemaining(candidate.cooldownKey())
 			result.Attempts = append(result.Attempts, FallbackAttempt{
 				Provider: candidate.Provider,
 				Model:    candidate.Model,
@@ -112,7 +178,7 @@ func (fc *FallbackChain) Execute(
 				Reason:   FailoverRateLimit,
 				Error: fmt.Errorf(
 					"provider %s in cooldown (%s remaining)",
-					candidate.Provider,
+					candidate.cooldownKey(),
 					remaining.Round(time.Second),
 				),
 			})
@@ -121,12 +187,12 @@ func (fc *FallbackChain) Execute(
 
 		// Execute the run function.
 		start := time.Now()
-		resp, err := run(ctx, candidate.Provider, candidate.Model)
+		resp, err := run(ctx, candidate)
 		elapsed := time.Since(start)
 
 		if err == nil {
 			// Success.
-			fc.cooldown.MarkSuccess(candidate.Provider)
+			fc.cooldown.MarkSuccess(candidate.cooldownKey())
 			result.Response = resp
 			result.Provider = candidate.Provider
 			result.Model = candidate.Model
@@ -172,7 +238,7 @@ func (fc *FallbackChain) Execute(
 		}
 
 		// Retriable error: mark failure and continue to next candidate.
-		fc.cooldown.MarkFailure(candidate.Provider, failErr.Reason)
+		fc.cooldown.MarkFailure(candidate.cooldownKey(), failErr.Reason)
 		result.Attempts = append(result.Attempts, FallbackAttempt{
 			Provider: candidate.Provider,
 			Model:    candidate.Model,
@@ -197,7 +263,7 @@ func (fc *FallbackChain) Execute(
 func (fc *FallbackChain) ExecuteImage(
 	ctx context.Context,
 	candidates []FallbackCandidate,
-	run func(ctx context.Context, provider, model string) (*LLMResponse, error),
+	run func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error),
 ) (*FallbackResult, error) {
 	if len(candidates) == 0 {
 		return nil, fmt.Errorf("image fallback: no candidates configured")
@@ -213,7 +279,7 @@ func (fc *FallbackChain) ExecuteImage(
 		}
 
 		start := time.Now()
-		resp, err := run(ctx, candidate.Provider, candidate.Model)
+		resp, err := run(ctx, candidate)
 		elapsed := time.Since(start)
 
 		if err == nil {
@@ -5,20 +5,22 @@ import (
 	"errors"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 func makeCandidate(provider, model string) FallbackCandidate {
 	return FallbackCandidate{Provider: provider, Model: model}
 }
 
-func successRun(content string) func(ctx context.Context, provider, model string) (*LLMResponse, error) {
-	return func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+func successRun(content string) func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
+	return func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		return &LLMResponse{Content: content, FinishReason: "stop"}, nil
 	}
 }
 
-func failRun(err error) func(ctx context.Context, provider, model string) (*LLMResponse, error) {
-	return func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+func failRun(err error) func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
+	return func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		return nil, err
 	}
 }
@@ -50,7 +52,7 @@ func TestFallback_SecondCandidateSuccess(t *testing.T) {
 	}
 
 	attempt := 0
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		attempt++
 		if attempt == 1 {
 			return nil, errors.New("rate limit exceeded")
@@ -83,7 +85,7 @@ func TestFallback_AllFail(t *testing.T) {
 		makeCandidate("groq", "llama"),
 	}
 
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		return nil, errors.New("rate limit exceeded")
 	}
 
@@ -111,7 +113,7 @@ func TestFallback_ContextCanceled(t *testing.T) {
 	}
 
 	attempt := 0
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		attempt++
 		if attempt == 1 {
 			cancel() // cancel context
@@ -137,7 +139,7 @@ func TestFallback_NonRetriableError(t *testing.T) {
 	}
 
 	attempt := 0
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		attempt++
 		return nil, errors.New("string should match pattern")
 	}
@@ -171,8 +173,8 @@ func TestFallback_CooldownSkip(t *testing.T) {
 		makeCandidate("anthropic", "claude"),
 	}
 
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
-		if provider == "openai" {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
+		if candidate.Provider == "openai" {
 			t.Error("should not call openai (in cooldown)")
 		}
 		return &LLMResponse{Content: "claude response", FinishReason: "stop"}, nil
@@ -211,7 +213,7 @@ func TestFallback_AllInCooldown(t *testing.T) {
 	}
 
 	_, err := fc.Execute(context.Background(), candidates,
-		func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+		func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 			t.Error("should not call any provider (all in cooldown)")
 			return nil, nil
 		})
@@ -260,7 +262,7 @@ func TestFallback_UnclassifiedError(t *testing.T) {
 	}
 
 	attempt := 0
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		attempt++
 		return nil, errors.New("completely unknown internal error")
 	}
@@ -281,7 +283,7 @@ func TestFallback_SuccessResetsCooldown(t *testing.T) {
 	candidates := []FallbackCandidate{makeCandidate("openai", "gpt-4")}
 
 	attempt := 0
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		attempt++
 		if attempt == 1 {
 			ct.MarkFailure("openai", FailoverRateLimit) // simulate failure tracked elsewhere
@@ -324,7 +326,7 @@ func TestImageFallback_DimensionError(t *testing.T) {
 	}
 
 	attempt := 0
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		attempt++
 		return nil, errors.New("image dimensions exceed max 4096x4096")
 	}
@@ -348,7 +350,7 @@ func TestImageFallback_SizeError(t *testing.T) {
 	}
 
 	attempt := 0
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		attempt++
 		return nil, errors.New("image exceeds 20 mb")
 	}
@@ -372,7 +374,7 @@ func TestImageFallback_RetryOnOtherErrors(t *testing.T) {
 	}
 
 	attempt := 0
-	run := func(ctx context.Context, provider, model string) (*LLMResponse, error) {
+	run := func(ctx context.Context, candidate FallbackCandidate) (*LLMResponse, error) {
 		attempt++
 		if attempt == 1 {
 			return nil, errors.New("rate limit exceeded")
@@ -477,3 +479,55 @@ func TestFallbackExhaustedError_Message(t *testing.T) {
 		t.Error("expected non-empty error message")
 	}
 }
+
+func TestResolveModelListCandidates_BindsModelListEntries(t *testing.T) {
+	modelList := []config.ModelConfig{
+		{ModelName: "claude", Model: "anthropic/claude-sonnet-4.6", APIKey: "a"},
+		{ModelName: "gpt4", Model: "openai/gpt-4o", APIKey: "b"},
+	}
+	cfg := ModelConfig{
+		Primary:   "claude",
+		Fallbacks: []string{"openai/gpt-4o", "groq/llama-3"},
+	}
+
+	candidates := ResolveModelListCandidates(cfg, "openai", modelList)
+	if len(candidates) != 3 {
+		t.Fatalf("candidates = %d, want 3", len(candidates))
+	}
+
+	want := []FallbackCandidate{
+		{Provider: "anthropic", Model: "claude-sonnet-4.6", ModelName: "claude"},
+		{Provider: "openai", Model: "gpt-4o", ModelName: "gpt4"},
+		{Provider: "groq", Model: "llama-3"},
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("candidate[%d] = %+v, want %+v", i, candidates[i], want[i])
+		}
+	}
+}
+
+func TestResolveModelListCandidates_KeepsAliasesOfOneModel(t *testing.T) {
+	modelList := []config.ModelConfig{
+		{ModelName: "gpt4o-direct", Model: "openai/gpt-4o", APIKey: "a"},
+		{ModelName: "gpt4o-proxy", Model: "openai/gpt-4o", APIBase: "https://proxy.example.com/v1"},
+	}
+	cfg := ModelConfig{
+		Primary:   "gpt4o-direct",
+		Fallbacks: []string{"gpt4o-proxy", "openai/gpt-4o", "gpt4o-direct"},
+	}
+
+	candidates := ResolveModelListCandidates(cfg, "openai", modelList)
+	want := []FallbackCandidate{
+		{Provider: "openai", Model: "gpt-4o", ModelName: "gpt4o-direct"},
+		{Provider: "openai", Model: "gpt-4o", ModelName: "gpt4o-proxy"},
+	}
+	if len(candidates) != len(want) {
+		t.Fatalf("candidates = %+v, want %+v", candidates, want)
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("candidate[%d] = %+v, want %+v", i, candidates[i], want[i])
+		}
+	}
+}
@@ -69,15 +69,89 @@ func (p *Provider) Chat(
 	model string,
 	options map[string]any,
 ) (*LLMResponse, error) {
-	if p.apiBase == "" {
-		return nil, fmt.Errorf("API base not configured")
+	resp, err := p.post(ctx, "/chat/completions", p.buildRequestBody(messages, tools, model, options))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return parseResponse(body)
+}
+
+// ChatStream sends the request with stream=true and reads the server-sent
+// events, reporting text and tool-call fragments through onDelta.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(protocoltypes.StreamDelta),
+) (*LLMResponse, error) {
+	requestBody := p.buildRequestBody(messages, tools, model, options)
+	requestBody["stream"] = true
+
+	resp, err := p.post(ctx, "/chat/completions", requestBody)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
+	return parseStream(resp.Body, onDelta)
+}
+
+// Embed returns an embedding vector for each text using the /embeddings endpoint.
+func (p *Provider) Embed(ctx context.Context, texts []string, model string) ([][]float32, error) {
+	resp, err := p.post(ctx, "/embeddings", map[string]any{
+		"model": normalizeModel(model, p.apiBase),
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var apiResponse struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embeddings: %w", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range apiResponse.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+func (p *Provider) buildRequestBody(
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) map[string]any {
 	model = normalizeModel(model, p.apiBase)
 
 	requestBody := map[string]any{
 		"model":    model,
-		"messages": messages,
+		"messages": serializeMessages(messages),
 	}
 
 	if len(tools) > 0 {
@@ -111,12 +185,22 @@ func (p *Provider) Chat(
 		}
 	}
 
+	return requestBody
+}
+
+// post sends a request to the API endpoint at path and returns the response
+// once it has a 200 status. The caller must close the body.
+func (p *Provider) post(ctx context.Context, path string, requestBody map[string]any) (*http.Response, error) {
+	if p.apiBase == "" {
+		return nil, fmt.Errorf("API base not configured")
+	}
+
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+"/chat/completions", bytes.NewReader(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+path, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -130,18 +214,17 @@ func (p *Provider) Chat(
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
 		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
 	}
 
-	return parseResponse(body)
+	return resp, nil
 }
 
 func parseResponse(body []byte) (*LLMResponse, error) {
@@ -182,8 +265,11 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 	choice := apiResponse.Choices[0]
 	toolCalls := make([]ToolCall, 0, len(choice.Message.ToolCalls))
 	for _, tc := range choice.Message.ToolCalls {
-		arguments := make(map[string]any)
-		name := ""
+		name, arguments := "", ""
+		if tc.Function != nil {
+			name = tc.Function.Name
+			arguments = tc.Function.Arguments
+		}
 
 		// Extract thought_signature from Gemini/Google-specific extra content
 		thoughtSignature := ""
@@ -191,33 +277,7 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 			thoughtSignature = tc.ExtraContent.Google.ThoughtSignature
 		}
 
-		if tc.Function != nil {
-			name = tc.Function.Name
-			if tc.Function.Arguments != "" {
-				if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
-					log.Printf("openai_compat: failed to decode tool call arguments for %q: %v", name, err)
-					arguments["raw"] = tc.Function.Arguments
-				}
-			}
-		}
-
-		// Build ToolCall with ExtraContent for Gemini 3 thought_signature persistence
-		toolCall := ToolCall{
-			ID:               tc.ID,
-			Name:             name,
-			Arguments:        arguments,
-			ThoughtSignature: thoughtSignature,
-		}
-
-		if thoughtSignature != "" {
-			toolCall.ExtraContent = &ExtraContent{
-				Google: &GoogleExtra{
-					ThoughtSignature: thoughtSignature,
-				},
-			}
-		}
-
-		toolCalls = append(toolCalls, toolCall)
+		toolCalls = append(toolCalls, buildToolCall(tc.ID, name, arguments, thoughtSignature))
 	}
 
 	return &LLMResponse{
@@ -228,6 +288,83 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 	}, nil
 }
 
+// buildToolCall decodes the JSON arguments of a tool call and keeps Gemini's
+// thought_signature in ExtraContent so it survives the round trip.
+func buildToolCall(id, name, rawArguments, thoughtSignature string) ToolCall {
+	arguments := make(map[string]any)
+	if rawArguments != "" {
+		if err := json.Unmarshal([]byte(rawArguments), &arguments); err != nil {
+			log.Printf("openai_compat: failed to decode tool call arguments for %q: %v", name, err)
+			arguments["raw"] = rawArguments
+		}
+	}
+
+	toolCall := ToolCall{
+		ID:               id,
+		Name:             name,
+		Arguments:        arguments,
+		ThoughtSignature: thoughtSignature,
+	}
+
+	if thoughtSignature != "" {
+		toolCall.ExtraContent = &ExtraContent{
+			Google: &GoogleExtra{
+				ThoughtSignature: thoughtSignature,
+			},
+		}
+	}
+
+	return toolCall
+}
+
+// serializeMessages converts messages into the wire format. Messages with
+// content parts are sent as an array of text and image_url items; images that
+// cannot be loaded are replaced with a short text note so the turn still goes through.
+func serializeMessages(messages []Message) []any {
+	out := make([]any, 0, len(messages))
+	for _, msg := range messages {
+		if len(msg.Parts) == 0 {
+			out = append(out, msg)
+			continue
+		}
+
+		content := make([]map[string]any, 0, len(msg.Parts))
+		for _, part := range msg.Parts {
+			switch part.Type {
+			case protocoltypes.ContentPartText:
+				content = append(content, map[string]any{"type": "text", "text": part.Text})
+			case protocoltypes.ContentPartImage:
+				if part.Image == nil {
+					continue
+				}
+				url, err := part.Image.DataURL()
+				if err != nil {
+					log.Printf("openai_compat: skipping image: %v", err)
+					content = append(content, map[string]any{"type": "text", "text": "[image unavailable]"})
+					continue
+				}
+				content = append(content, map[string]any{
+					"type":      "image_url",
+					"image_url": map[string]any{"url": url},
+				})
+			}
+		}
+
+		wire := map[string]any{
+			"role":    msg.Role,
+			"content": content,
+		}
+		if len(msg.ToolCalls) > 0 {
+			wire["tool_calls"] = msg.ToolCalls
+		}
+		if msg.ToolCallID != "" {
+			wire["tool_call_id"] = msg.ToolCallID
+		}
+		out = append(out, wire)
+	}
+	return out
+}
+
 func normalizeModel(model, apiBase string) string {
 	idx := strings.Index(model, "/")
 	if idx == -1 {
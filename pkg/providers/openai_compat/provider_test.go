@@ -1,11 +1,17 @@
 package openai_compat
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 func TestProviderChat_UsesMaxCompletionTokensForGLM(t *testing.T) {
@@ -101,6 +107,165 @@ func TestProviderChat_ParsesToolCalls(t *testing.T) {
 	}
 }
 
+func TestProviderChat_SerializesImageParts(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{
+			"choices": []map[string]any{
+				{
+					"message":       map[string]any{"content": "a cat"},
+					"finish_reason": "stop",
+				},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	imgPath := filepath.Join(t.TempDir(), "shot.png")
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	if err := os.WriteFile(imgPath, png, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.Chat(t.Context(), []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "what is this?", Parts: []protocoltypes.ContentPart{
+			{Type: protocoltypes.ContentPartText, Text: "what is this?"},
+			{Type: protocoltypes.ContentPartImage, Image: &protocoltypes.ImageSource{Path: imgPath}},
+			{Type: protocoltypes.ContentPartImage, Image: &protocoltypes.ImageSource{URL: "https://example.com/a.jpg"}},
+		}},
+	}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	messages := requestBody["messages"].([]any)
+	if content, _ := messages[0].(map[string]any)["content"].(string); content != "sys" {
+		t.Errorf("system content = %v, want plain string", messages[0])
+	}
+	user := messages[1].(map[string]any)
+	if _, ok := user["parts"]; ok {
+		t.Error("parts should not be sent on the wire")
+	}
+	content, ok := user["content"].([]any)
+	if !ok || len(content) != 3 {
+		t.Fatalf("user content = %#v, want 3 items", user["content"])
+	}
+	if text := content[0].(map[string]any); text["type"] != "text" || text["text"] != "what is this?" {
+		t.Errorf("text part = %v", text)
+	}
+	local := content[1].(map[string]any)["image_url"].(map[string]any)["url"].(string)
+	want := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	if local != want {
+		t.Errorf("local image url = %q, want %q", local, want)
+	}
+	remote := content[2].(map[string]any)["image_url"].(map[string]any)["url"].(string)
+	if remote != "https://example.com/a.jpg" {
+		t.Errorf("remote image url = %q", remote)
+	}
+}
+
+func TestProviderChatStream_AssemblesDeltas(t *testing.T) {
+	var requestBody map[string]any
+	chunks := []string{
+		`{"choices":[{"delta":{"role":"assistant","content":"Let me "}}]}`,
+		`{"choices":[{"delta":{"content":"check."}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"SF\"}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	var text string
+	var toolDeltas int
+	resp, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "weather?"}}, nil, "gpt-4o", nil,
+		func(d protocoltypes.StreamDelta) {
+			text += d.Content
+			if d.ToolCall != nil {
+				toolDeltas++
+			}
+		})
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	if requestBody["stream"] != true {
+		t.Errorf("stream = %v, want true", requestBody["stream"])
+	}
+	if text != "Let me check." || resp.Content != "Let me check." {
+		t.Errorf("streamed %q, final %q", text, resp.Content)
+	}
+	if toolDeltas != 3 {
+		t.Errorf("tool deltas = %d, want 3", toolDeltas)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" || resp.ToolCalls[0].Arguments["city"] != "SF" {
+		t.Fatalf("ToolCalls = %+v", resp.ToolCalls)
+	}
+	if resp.FinishReason != "tool_calls" {
+		t.Errorf("FinishReason = %q, want tool_calls", resp.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 15 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestProviderEmbed_OrdersVectorsByIndex(t *testing.T) {
+	var path string
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{
+			"data": []map[string]any{
+				{"index": 1, "embedding": []float32{0, 1}},
+				{"index": 0, "embedding": []float32{1, 0}},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	vectors, err := p.Embed(t.Context(), []string{"first", "second"}, "text-embedding-3-small")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if path != "/embeddings" {
+		t.Errorf("path = %q, want /embeddings", path)
+	}
+	if requestBody["model"] != "text-embedding-3-small" {
+		t.Errorf("model = %v", requestBody["model"])
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+		t.Errorf("vectors = %v, want ordered by index", vectors)
+	}
+}
+
 func TestProviderChat_HTTPError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -0,0 +1,70 @@
+package protocoltypes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxInlineImageBytes caps how much of a local image is inlined into a request.
+const maxInlineImageBytes = 20 << 20
+
+// Inline returns the image as a media type and base64 payload, reading it from
+// disk when the source is a local path. Sources that only have a URL return an
+// error; callers should pass those through as URLs instead.
+func (s ImageSource) Inline() (mediaType, data string, err error) {
+	if s.Data != "" {
+		mediaType = s.MediaType
+		if mediaType == "" {
+			mediaType = "image/jpeg"
+		}
+		return mediaType, s.Data, nil
+	}
+	if s.Path == "" {
+		return "", "", fmt.Errorf("image has no inline data or local path")
+	}
+
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading image: %w", err)
+	}
+	if info.Size() > maxInlineImageBytes {
+		return "", "", fmt.Errorf("image %s is too large (%d bytes)", filepath.Base(s.Path), info.Size())
+	}
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading image: %w", err)
+	}
+
+	mediaType = s.MediaType
+	if mediaType == "" {
+		mediaType = detectImageType(s.Path, raw)
+	}
+	return mediaType, base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// DataURL returns a data: URL for inline or local images, or the remote URL as-is.
+func (s ImageSource) DataURL() (string, error) {
+	if s.URL != "" && s.Data == "" && s.Path == "" {
+		return s.URL, nil
+	}
+	mediaType, data, err := s.Inline()
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mediaType + ";base64," + data, nil
+}
+
+func detectImageType(path string, raw []byte) string {
+	if detected := http.DetectContentType(raw); strings.HasPrefix(detected, "image/") {
+		return detected
+	}
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); strings.HasPrefix(byExt, "image/") {
+		return byExt
+	}
+	return "image/jpeg"
+}
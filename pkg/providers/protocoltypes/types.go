@@ -31,6 +31,22 @@ type LLMResponse struct {
 	Usage        *UsageInfo `json:"usage,omitempty"`
 }
 
+// StreamDelta is an incremental piece of a streamed response. Exactly one of
+// Content or ToolCall is set.
+type StreamDelta struct {
+	Content  string
+	ToolCall *ToolCallDelta
+}
+
+// ToolCallDelta is a fragment of a tool call. ID and Name arrive with the first
+// fragment of a call; Arguments carries the next chunk of its JSON arguments.
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
 type UsageInfo struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
@@ -38,10 +54,45 @@ type UsageInfo struct {
 }
 
 type Message struct {
-	Role       string     `json:"role"`
-	Content    string     `json:"content"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Parts carries multimodal content. When set, providers serialize Parts
+	// instead of Content.
+	Parts      []ContentPart `json:"parts,omitempty"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// Content part types.
+const (
+	ContentPartText  = "text"
+	ContentPartImage = "image"
+)
+
+// ContentPart is one piece of a multimodal message.
+type ContentPart struct {
+	Type  string       `json:"type"`
+	Text  string       `json:"text,omitempty"`
+	Image *ImageSource `json:"image,omitempty"`
+}
+
+// ImageSource locates an image by local path, remote URL or inline base64 data.
+// Exactly one of Path, URL or Data is expected to be set.
+type ImageSource struct {
+	Path      string `json:"path,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Data      string `json:"data,omitempty"` // base64 without the data: prefix
+	MediaType string `json:"media_type,omitempty"`
+}
+
+// HasImages reports whether the message carries at least one image part.
+func (m Message) HasImages() bool {
+	for _, part := range m.Parts {
+		if part.Type == ContentPartImage && part.Image != nil {
+			return true
+		}
+	}
+	return false
 }
 
 type ToolDefinition struct {
@@ -0,0 +1,266 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Load-balancing strategies for model_list entries sharing a model_name.
+// Round-robin is used when none is configured.
+const (
+	LoadBalanceRoundRobin  = "round_robin"
+	LoadBalanceLeastLoaded = "least_loaded"
+)
+
+// ModelRegistry groups model_list entries by model_name and spreads requests
+// across them. Each entry gets its own provider instance, an optional RPM
+// token bucket, and a cooldown slot in the shared CooldownTracker, so a
+// rate-limited API key is skipped while its siblings keep serving.
+type ModelRegistry struct {
+	groups      map[string]*modelGroup
+	cooldown    *CooldownTracker
+	workspace   string
+	newProvider func(cfg *config.ModelConfig) (LLMProvider, string, error)
+	nowFunc     func() time.Time
+}
+
+type modelGroup struct {
+	name     string
+	strategy string
+	entries  []*modelEntry
+	next     atomic.Uint64
+}
+
+type modelEntry struct {
+	key      string // cooldown key, unique per entry
+	protocol string
+	cfg      config.ModelConfig
+	bucket   *tokenBucket // nil when RPM is unlimited
+	inFlight atomic.Int64
+
+	mu       sync.Mutex
+	provider LLMProvider
+	modelID  string
+}
+
+// NewModelRegistry builds a registry over cfg.ModelList. Entry cooldowns are
+// recorded in cooldown under "<model_name>#<n>" keys.
+func NewModelRegistry(cfg *config.Config, cooldown *CooldownTracker) *ModelRegistry {
+	r := &ModelRegistry{
+		groups:      make(map[string]*modelGroup),
+		cooldown:    cooldown,
+		workspace:   cfg.WorkspacePath(),
+		newProvider: CreateProviderFromConfig,
+		nowFunc:     time.Now,
+	}
+
+	for _, mc := range cfg.ModelList {
+		group := r.groups[mc.ModelName]
+		if group == nil {
+			group = &modelGroup{name: mc.ModelName}
+			r.groups[mc.ModelName] = group
+		}
+		// The first entry naming a strategy sets it for the whole group.
+		if group.strategy == "" {
+			group.strategy = mc.LoadBalance
+		}
+
+		protocol, _ := ExtractProtocol(mc.Model)
+		entry := &modelEntry{
+			key:      fmt.Sprintf("%s#%d", mc.ModelName, len(group.entries)),
+			protocol: protocol,
+			cfg:      mc,
+		}
+		if mc.RPM > 0 {
+			entry.bucket = newTokenBucket(mc.RPM, r.nowFunc())
+		}
+		group.entries = append(group.entries, entry)
+	}
+	return r
+}
+
+// Has reports whether modelName is a model_list entry.
+func (r *ModelRegistry) Has(modelName string) bool {
+	_, ok := r.groups[modelName]
+	return ok
+}
+
+// Do runs fn with the provider and model ID of one of modelName's entries.
+// When fn fails with a retriable error the entry is put in cooldown and the
+// next available entry is tried. It returns a rate-limit FailoverError when
+// every entry is cooling down, and waits (bounded by ctx) while all
+// available entries have used up their RPM budget.
+func (r *ModelRegistry) Do(
+	ctx context.Context,
+	modelName string,
+	fn func(ctx context.Context, provider LLMProvider, modelID string) (*LLMResponse, error),
+) (*LLMResponse, error) {
+	group := r.groups[modelName]
+	if group == nil {
+		return nil, fmt.Errorf("model %q not found in model_list", modelName)
+	}
+
+	tried := make(map[*modelEntry]bool)
+	var lastErr error
+	for {
+		entry, err := r.acquire(ctx, group, tried)
+		if err != nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, err
+		}
+		tried[entry] = true
+
+		provider, modelID, err := r.entryProvider(entry)
+		if err != nil {
+			entry.inFlight.Add(-1)
+			lastErr = err
+			continue
+		}
+
+		resp, err := fn(ctx, provider, modelID)
+		entry.inFlight.Add(-1)
+		if err == nil {
+			r.cooldown.MarkSuccess(entry.key)
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		failErr := ClassifyError(err, entry.protocol, modelID)
+		if failErr == nil || !failErr.IsRetriable() {
+			return nil, err
+		}
+		r.cooldown.MarkFailure(entry.key, failErr.Reason)
+		lastErr = err
+	}
+}
+
+// acquire picks an untried entry that is out of cooldown and has RPM budget,
+// waiting for budget if necessary. The returned entry's in-flight count has
+// been incremented.
+func (r *ModelRegistry) acquire(ctx context.Context, group *modelGroup, tried map[*modelEntry]bool) (*modelEntry, error) {
+	for {
+		candidates := r.orderedCandidates(group, tried)
+		if len(candidates) == 0 {
+			return nil, &FailoverError{
+				Reason:   FailoverRateLimit,
+				Provider: group.name,
+				Model:    group.name,
+				Wrapped:  fmt.Errorf("all %d entries of model %q are rate limited or failing", len(group.entries), group.name),
+			}
+		}
+
+		now := r.nowFunc()
+		wait := time.Duration(-1)
+		for _, entry := range candidates {
+			if entry.bucket == nil {
+				entry.inFlight.Add(1)
+				return entry, nil
+			}
+			d := entry.bucket.take(now)
+			if d == 0 {
+				entry.inFlight.Add(1)
+				return entry, nil
+			}
+			if wait < 0 || d < wait {
+				wait = d
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// orderedCandidates returns the group's usable entries in preference order.
+func (r *ModelRegistry) orderedCandidates(group *modelGroup, tried map[*modelEntry]bool) []*modelEntry {
+	n := len(group.entries)
+	start := int(group.next.Add(1)-1) % n
+
+	candidates := make([]*modelEntry, 0, n)
+	for i := 0; i < n; i++ {
+		entry := group.entries[(start+i)%n]
+		if tried[entry] || !r.cooldown.IsAvailable(entry.key) {
+			continue
+		}
+		candidates = append(candidates, entry)
+	}
+
+	if group.strategy == LoadBalanceLeastLoaded {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].inFlight.Load() < candidates[j].inFlight.Load()
+		})
+	}
+	return candidates
+}
+
+// entryProvider returns the entry's provider, creating it on first use.
+func (r *ModelRegistry) entryProvider(entry *modelEntry) (LLMProvider, string, error) {
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.provider != nil {
+		return entry.provider, entry.modelID, nil
+	}
+
+	mc := entry.cfg
+	if mc.Workspace == "" {
+		mc.Workspace = r.workspace
+	}
+	provider, modelID, err := r.newProvider(&mc)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create provider for %s: %w", entry.key, err)
+	}
+	entry.provider = provider
+	entry.modelID = modelID
+	return provider, modelID, nil
+}
+
+// tokenBucket allows rpm requests per minute with bursts of up to rpm.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	perSec   float64
+	last     time.Time
+}
+
+func newTokenBucket(rpm int, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(rpm),
+		tokens:   float64(rpm),
+		perSec:   float64(rpm) / 60,
+		last:     now,
+	}
+}
+
+// take consumes a token and returns 0, or returns how long until one is
+// available without consuming anything.
+func (b *tokenBucket) take(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.perSec)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.perSec * float64(time.Second))
+}
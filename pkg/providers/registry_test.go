@@ -0,0 +1,187 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// keyedProvider identifies which model_list entry served a request.
+type keyedProvider struct {
+	apiKey string
+}
+
+func (p *keyedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	return &LLMResponse{Content: p.apiKey}, nil
+}
+
+func (p *keyedProvider) GetDefaultModel() string { return "" }
+
+func newTestRegistry(entries ...config.ModelConfig) *ModelRegistry {
+	r := NewModelRegistry(&config.Config{ModelList: entries}, NewCooldownTracker())
+	r.newProvider = func(mc *config.ModelConfig) (LLMProvider, string, error) {
+		_, modelID := ExtractProtocol(mc.Model)
+		return &keyedProvider{apiKey: mc.APIKey}, modelID, nil
+	}
+	return r
+}
+
+// served runs one request through the registry and returns the API key used.
+func served(t *testing.T, r *ModelRegistry, modelName string) string {
+	t.Helper()
+	resp, err := r.Do(context.Background(), modelName,
+		func(ctx context.Context, p LLMProvider, modelID string) (*LLMResponse, error) {
+			return p.Chat(ctx, nil, nil, modelID, nil)
+		})
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	return resp.Content
+}
+
+func TestModelRegistry_RoundRobinSelection(t *testing.T) {
+	r := newTestRegistry(
+		config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "k1"},
+		config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "k2"},
+		config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "k3"},
+	)
+
+	counts := map[string]int{}
+	for i := 0; i < 9; i++ {
+		counts[served(t, r, "gpt")]++
+	}
+	for _, key := range []string{"k1", "k2", "k3"} {
+		if counts[key] != 3 {
+			t.Errorf("key %s served %d requests, want 3 (%v)", key, counts[key], counts)
+		}
+	}
+}
+
+func TestModelRegistry_LeastLoadedSelection(t *testing.T) {
+	r := newTestRegistry(
+		config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "busy", LoadBalance: LoadBalanceLeastLoaded},
+		config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "idle"},
+	)
+
+	// Hold a request open on "busy" so every other request goes to "idle".
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.Do(context.Background(), "gpt", func(ctx context.Context, p LLMProvider, modelID string) (*LLMResponse, error) {
+			if p.(*keyedProvider).apiKey != "busy" {
+				t.Errorf("first request should go to busy")
+			}
+			close(started)
+			<-release
+			return &LLMResponse{}, nil
+		})
+	}()
+	<-started
+
+	for i := 0; i < 3; i++ {
+		if got := served(t, r, "gpt"); got != "idle" {
+			t.Errorf("request %d served by %s, want idle", i, got)
+		}
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestModelRegistry_SkipsRateLimitedKey(t *testing.T) {
+	r := newTestRegistry(
+		config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "limited"},
+		config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "ok"},
+	)
+
+	var tried []string
+	resp, err := r.Do(context.Background(), "gpt", func(ctx context.Context, p LLMProvider, modelID string) (*LLMResponse, error) {
+		key := p.(*keyedProvider).apiKey
+		tried = append(tried, key)
+		if key == "limited" {
+			return nil, errors.New("status: 429 too many requests")
+		}
+		return &LLMResponse{Content: key}, nil
+	})
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	if resp.Content != "ok" || len(tried) != 2 {
+		t.Fatalf("expected retry on the second key, tried %v", tried)
+	}
+
+	for i := 0; i < 4; i++ {
+		if got := served(t, r, "gpt"); got != "ok" {
+			t.Errorf("rate-limited key should be in cooldown, request %d served by %s", i, got)
+		}
+	}
+}
+
+func TestModelRegistry_AllKeysCoolingDown(t *testing.T) {
+	r := newTestRegistry(config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "k1"})
+	r.cooldown.MarkFailure("gpt#0", FailoverRateLimit)
+
+	_, err := r.Do(context.Background(), "gpt", func(ctx context.Context, p LLMProvider, modelID string) (*LLMResponse, error) {
+		t.Fatal("no entry should be called")
+		return nil, nil
+	})
+	var failErr *FailoverError
+	if !errors.As(err, &failErr) || failErr.Reason != FailoverRateLimit {
+		t.Fatalf("expected rate-limit FailoverError, got %v", err)
+	}
+}
+
+func TestModelRegistry_EnforcesRPM(t *testing.T) {
+	r := newTestRegistry(config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o", APIKey: "k1", RPM: 2})
+
+	served(t, r, "gpt")
+	served(t, r, "gpt")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := r.Do(ctx, "gpt", func(ctx context.Context, p LLMProvider, modelID string) (*LLMResponse, error) {
+		t.Fatal("third request within the minute should wait for budget")
+		return nil, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected to wait until the deadline, got %v", err)
+	}
+}
+
+func TestModelRegistry_ModelNotFound(t *testing.T) {
+	r := newTestRegistry()
+	if r.Has("gpt") {
+		t.Error("empty registry should not have gpt")
+	}
+	if _, err := r.Do(context.Background(), "gpt", nil); err == nil {
+		t.Error("expected error for unknown model")
+	}
+}
+
+func TestTokenBucket_Refills(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(60, now)
+	for i := 0; i < 60; i++ {
+		if wait := b.take(now); wait != 0 {
+			t.Fatalf("token %d: unexpected wait %v", i, wait)
+		}
+	}
+	if wait := b.take(now); wait <= 0 || wait > time.Second {
+		t.Fatalf("expected a wait of up to 1s, got %v", wait)
+	}
+	if wait := b.take(now.Add(time.Second)); wait != 0 {
+		t.Fatalf("expected a token after 1s, got wait %v", wait)
+	}
+}
@@ -17,6 +17,15 @@ type (
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
 	ExtraContent           = protocoltypes.ExtraContent
 	GoogleExtra            = protocoltypes.GoogleExtra
+	ContentPart            = protocoltypes.ContentPart
+	ImageSource            = protocoltypes.ImageSource
+	StreamDelta            = protocoltypes.StreamDelta
+	ToolCallDelta          = protocoltypes.ToolCallDelta
+)
+
+const (
+	ContentPartText  = protocoltypes.ContentPartText
+	ContentPartImage = protocoltypes.ContentPartImage
 )
 
 type LLMProvider interface {
@@ -30,6 +39,27 @@ type LLMProvider interface {
 	GetDefaultModel() string
 }
 
+// StreamingProvider is implemented by providers that can stream responses.
+// ChatStream calls onDelta as text and tool-call fragments arrive and returns
+// the assembled response, the same as Chat would have.
+type StreamingProvider interface {
+	LLMProvider
+	ChatStream(
+		ctx context.Context,
+		messages []Message,
+		tools []ToolDefinition,
+		model string,
+		options map[string]any,
+		onDelta func(StreamDelta),
+	) (*LLMResponse, error)
+}
+
+// EmbeddingProvider is implemented by providers that can embed text for
+// semantic search.
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string, model string) ([][]float32, error)
+}
+
 // FailoverReason classifies why an LLM request failed for fallback decisions.
 type FailoverReason string
 
@@ -0,0 +1,250 @@
+package routing
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// routeFacts are the properties of a message that binding conditions test.
+type routeFacts struct {
+	content  string
+	roles    []string
+	hasMedia bool
+	now      time.Time
+}
+
+// factsFor gathers the facts about input, including the roles config.Roles
+// gives its sender.
+func (r *RouteResolver) factsFor(input RouteInput, channel string) routeFacts {
+	facts := routeFacts{
+		content:  strings.TrimSpace(input.Content),
+		hasMedia: input.HasMedia,
+		now:      input.Now,
+	}
+	if facts.now.IsZero() {
+		facts.now = time.Now()
+	}
+	for _, role := range input.SenderRoles {
+		if role = strings.ToLower(strings.TrimSpace(role)); role != "" {
+			facts.roles = append(facts.roles, role)
+		}
+	}
+	if senderID := strings.TrimSpace(input.SenderID); senderID != "" {
+		for role, members := range r.cfg.Roles {
+			for _, member := range members {
+				if memberMatches(strings.TrimSpace(member), channel, senderID) {
+					facts.roles = append(facts.roles, strings.ToLower(role))
+					break
+				}
+			}
+		}
+	}
+	return facts
+}
+
+// memberMatches reports whether a config.Roles member names senderID of
+// channel. Members are sender IDs, optionally prefixed "channel:" to limit
+// them to one channel.
+func memberMatches(member, channel, senderID string) bool {
+	if SenderMatches(senderID, member) {
+		return true
+	}
+	prefix := channel + ":"
+	return len(member) > len(prefix) && strings.EqualFold(member[:len(prefix)], prefix) &&
+		SenderMatches(senderID, member[len(prefix):])
+}
+
+// conditionCount returns how many conditions b has; bindings with more of
+// them are more specific and are tried first within a priority level.
+func conditionCount(b *config.AgentBinding) int {
+	n := 0
+	if b.Match.Content != nil {
+		n++
+	}
+	if len(b.Match.SenderRoles) > 0 {
+		n++
+	}
+	if b.Match.Schedule != nil {
+		n++
+	}
+	if b.Match.HasMedia != nil {
+		n++
+	}
+	return n
+}
+
+// conditionsHold reports whether every condition of b holds for facts, and
+// the MatchedBy suffix naming them, e.g. "+role+schedule".
+func (r *RouteResolver) conditionsHold(b *config.AgentBinding, facts routeFacts) (string, bool) {
+	var suffix string
+	if m := b.Match.Content; m != nil {
+		if !r.contentMatches(m, facts.content) {
+			return "", false
+		}
+	}
+	if len(b.Match.SenderRoles) > 0 {
+		if !slices.ContainsFunc(b.Match.SenderRoles, func(role string) bool {
+			return slices.Contains(facts.roles, strings.ToLower(strings.TrimSpace(role)))
+		}) {
+			return "", false
+		}
+		suffix += "+role"
+	}
+	if s := b.Match.Schedule; s != nil {
+		if !scheduleMatches(s, facts.now) {
+			return "", false
+		}
+		suffix += "+schedule"
+	}
+	if b.Match.HasMedia != nil {
+		if *b.Match.HasMedia != facts.hasMedia {
+			return "", false
+		}
+		suffix += "+media"
+	}
+	return suffix, true
+}
+
+// contentMatches reports whether content starts with m's prefix, contains one
+// of its keywords or matches its regex.
+func (r *RouteResolver) contentMatches(m *config.ContentMatch, content string) bool {
+	lower := strings.ToLower(content)
+	if prefix := strings.ToLower(strings.TrimSpace(m.Prefix)); prefix != "" {
+		if rest, ok := strings.CutPrefix(lower, prefix); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\n' || rest[0] == '\t') {
+			return true
+		}
+	}
+	for _, keyword := range m.Keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && containsWord(lower, keyword) {
+			return true
+		}
+	}
+	if m.Regex != "" {
+		if re := r.compile(m.Regex); re != nil && re.MatchString(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWord reports whether word occurs in s with no letter or digit
+// directly before or after it.
+func containsWord(s, word string) bool {
+	for i := 0; i+len(word) <= len(s); {
+		j := strings.Index(s[i:], word)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(word)
+		if (start == 0 || !isWordByte(s[start-1])) && (end == len(s) || !isWordByte(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+	return false
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_'
+}
+
+// compile returns the case-insensitive regexp for pattern, compiling it once.
+// Invalid patterns are logged and never match.
+func (r *RouteResolver) compile(pattern string) *regexp.Regexp {
+	if cached, ok := r.regexps.Load(pattern); ok {
+		re, _ := cached.(*regexp.Regexp)
+		return re
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		logger.WarnCF("routing", "Invalid content regex in binding",
+			map[string]any{
+				"regex": pattern,
+				"error": err.Error(),
+			})
+		re = nil
+	}
+	r.regexps.Store(pattern, re)
+	return re
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+var timezones sync.Map // name -> *time.Location, or nil if unknown
+
+// scheduleMatches reports whether now falls within s. An unknown timezone or
+// malformed time never matches.
+func scheduleMatches(s *config.ScheduleMatch, now time.Time) bool {
+	if tz := strings.TrimSpace(s.Timezone); tz != "" {
+		loc, ok := timezones.Load(tz)
+		if !ok {
+			l, err := time.LoadLocation(tz)
+			if err != nil {
+				logger.WarnCF("routing", "Unknown timezone in binding schedule",
+					map[string]any{
+						"timezone": tz,
+						"error":    err.Error(),
+					})
+				l = nil
+			}
+			timezones.Store(tz, l)
+			loc = l
+		}
+		l, _ := loc.(*time.Location)
+		if l == nil {
+			return false
+		}
+		now = now.In(l)
+	}
+
+	if len(s.Days) > 0 && !slices.ContainsFunc(s.Days, func(day string) bool {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if len(day) > 3 {
+			day = day[:3] // "monday" -> "mon"
+		}
+		d, ok := weekdays[day]
+		return ok && d == now.Weekday()
+	}) {
+		return false
+	}
+
+	from, ok := minuteOfDay(s.From)
+	if !ok {
+		return false
+	}
+	to, ok := minuteOfDay(s.To)
+	if !ok {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	switch {
+	case from == to:
+		return true
+	case from < to:
+		return minute >= from && minute < to
+	default: // spans midnight
+		return minute >= from || minute < to
+	}
+}
+
+// minuteOfDay parses "15:04" into minutes after midnight; empty is midnight.
+func minuteOfDay(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, true
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
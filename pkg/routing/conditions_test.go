@@ -0,0 +1,237 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func conditionAgents() []config.AgentConfig {
+	return []config.AgentConfig{
+		{ID: "main", Default: true},
+		{ID: "coder"},
+		{ID: "oncall"},
+		{ID: "vision"},
+	}
+}
+
+func TestResolveRoute_ContentBinding(t *testing.T) {
+	bindings := []config.AgentBinding{
+		{AgentID: "coder", Match: config.BindingMatch{Channel: "*", Content: &config.ContentMatch{Prefix: "/code"}}},
+		{AgentID: "oncall", Match: config.BindingMatch{Channel: "telegram", Content: &config.ContentMatch{Keywords: []string{"outage"}}}},
+		{AgentID: "vision", Match: config.BindingMatch{Channel: "telegram", Content: &config.ContentMatch{Regex: `^draw\b`}}},
+	}
+	r := NewRouteResolver(testConfig(conditionAgents(), bindings))
+
+	tests := []struct {
+		channel   string
+		content   string
+		wantAgent string
+		wantBy    string
+	}{
+		{"telegram", "/code fix the build", "coder", "binding.content"},
+		{"discord", "/CODE", "coder", "binding.content"},
+		{"telegram", "/codex please", "main", "default"},
+		{"telegram", "is there an Outage right now?", "oncall", "binding.content"},
+		{"telegram", "outages happen", "main", "default"},
+		{"discord", "outage", "main", "default"},
+		{"telegram", "Draw a cat", "vision", "binding.content"},
+		{"telegram", "withdraw money", "main", "default"},
+	}
+	for _, tt := range tests {
+		route := r.ResolveRoute(RouteInput{Channel: tt.channel, Content: tt.content})
+		if route.AgentID != tt.wantAgent || route.MatchedBy != tt.wantBy {
+			t.Errorf("%s %q: got %s by %s, want %s by %s",
+				tt.channel, tt.content, route.AgentID, route.MatchedBy, tt.wantAgent, tt.wantBy)
+		}
+	}
+}
+
+func TestResolveRoute_ContentBeatsPeer(t *testing.T) {
+	bindings := []config.AgentBinding{
+		{AgentID: "main", Match: config.BindingMatch{
+			Channel: "telegram",
+			Peer:    &config.PeerMatch{Kind: "direct", ID: "user1"},
+		}},
+		{AgentID: "coder", Match: config.BindingMatch{
+			Channel: "telegram",
+			Peer:    &config.PeerMatch{Kind: "group", ID: "devs"},
+			Content: &config.ContentMatch{Prefix: "@coder"},
+		}},
+		{AgentID: "oncall", Match: config.BindingMatch{
+			Channel: "telegram",
+			Content: &config.ContentMatch{Prefix: "@coder"},
+		}},
+	}
+	r := NewRouteResolver(testConfig(conditionAgents(), bindings))
+
+	// The devs group gets the binding scoped to it, everyone else the
+	// unscoped one; both beat the peer binding.
+	route := r.ResolveRoute(RouteInput{
+		Channel: "telegram",
+		Peer:    &RoutePeer{Kind: "group", ID: "devs"},
+		Content: "@coder look at this",
+	})
+	if route.AgentID != "coder" || route.MatchedBy != "binding.content" {
+		t.Errorf("devs: got %s by %s, want coder by binding.content", route.AgentID, route.MatchedBy)
+	}
+	route = r.ResolveRoute(RouteInput{
+		Channel: "telegram",
+		Peer:    &RoutePeer{Kind: "direct", ID: "user1"},
+		Content: "@coder look at this",
+	})
+	if route.AgentID != "oncall" || route.MatchedBy != "binding.content" {
+		t.Errorf("user1: got %s by %s, want oncall by binding.content", route.AgentID, route.MatchedBy)
+	}
+	route = r.ResolveRoute(RouteInput{
+		Channel: "telegram",
+		Peer:    &RoutePeer{Kind: "direct", ID: "user1"},
+		Content: "hello",
+	})
+	if route.AgentID != "main" || route.MatchedBy != "binding.peer" {
+		t.Errorf("plain message: got %s by %s, want main by binding.peer", route.AgentID, route.MatchedBy)
+	}
+}
+
+func TestResolveRoute_RoleBinding(t *testing.T) {
+	bindings := []config.AgentBinding{
+		{AgentID: "main", Match: config.BindingMatch{Channel: "discord"}},
+		{AgentID: "oncall", Match: config.BindingMatch{Channel: "discord", SenderRoles: []string{"Admin"}}},
+	}
+	cfg := testConfig(conditionAgents(), bindings)
+	cfg.Roles = map[string][]string{"admin": {"discord:42", "7"}}
+	r := NewRouteResolver(cfg)
+
+	tests := []struct {
+		name      string
+		input     RouteInput
+		wantAgent string
+		wantBy    string
+	}{
+		{"configured member", RouteInput{Channel: "discord", SenderID: "42"}, "oncall", "binding.account+role"},
+		{"member of any channel", RouteInput{Channel: "discord", SenderID: "7"}, "oncall", "binding.account+role"},
+		{"role from channel", RouteInput{Channel: "discord", SenderID: "9", SenderRoles: []string{"admin"}}, "oncall", "binding.account+role"},
+		{"other sender", RouteInput{Channel: "discord", SenderID: "9"}, "main", "binding.account"},
+	}
+	for _, tt := range tests {
+		route := r.ResolveRoute(tt.input)
+		if route.AgentID != tt.wantAgent || route.MatchedBy != tt.wantBy {
+			t.Errorf("%s: got %s by %s, want %s by %s", tt.name, route.AgentID, route.MatchedBy, tt.wantAgent, tt.wantBy)
+		}
+	}
+}
+
+func TestResolveRoute_RoleBindingTelegramSender(t *testing.T) {
+	bindings := []config.AgentBinding{
+		{AgentID: "oncall", Match: config.BindingMatch{Channel: "telegram", SenderRoles: []string{"admin"}}},
+	}
+	cfg := testConfig(conditionAgents(), bindings)
+	cfg.Roles = map[string][]string{"admin": {"telegram:42", "@bob", "discord:7"}}
+	r := NewRouteResolver(cfg)
+
+	// Telegram sender IDs take the form "id|username".
+	tests := []struct {
+		senderID  string
+		wantAgent string
+	}{
+		{"42|alice", "oncall"},
+		{"42", "oncall"},
+		{"9|bob", "oncall"},
+		{"7|carol", "main"},
+		{"9|alice", "main"},
+	}
+	for _, tt := range tests {
+		route := r.ResolveRoute(RouteInput{Channel: "telegram", SenderID: tt.senderID})
+		if route.AgentID != tt.wantAgent {
+			t.Errorf("%s: AgentID = %s, want %s", tt.senderID, route.AgentID, tt.wantAgent)
+		}
+	}
+}
+
+func TestResolveRoute_ScheduleBinding(t *testing.T) {
+	bindings := []config.AgentBinding{
+		{AgentID: "oncall", Match: config.BindingMatch{
+			Channel: "slack",
+			Schedule: &config.ScheduleMatch{
+				Days:     []string{"Saturday", "sun"},
+				Timezone: "America/New_York",
+			},
+		}},
+		{AgentID: "oncall", Match: config.BindingMatch{
+			Channel:  "slack",
+			Schedule: &config.ScheduleMatch{From: "22:00", To: "06:00", Timezone: "America/New_York"},
+		}},
+	}
+	r := NewRouteResolver(testConfig(conditionAgents(), bindings))
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	tests := []struct {
+		now       time.Time
+		wantAgent string
+	}{
+		{time.Date(2026, 3, 7, 12, 0, 0, 0, newYork), "oncall"}, // Saturday
+		{time.Date(2026, 3, 9, 12, 0, 0, 0, newYork), "main"},   // Monday noon
+		{time.Date(2026, 3, 9, 23, 30, 0, 0, newYork), "oncall"},
+		{time.Date(2026, 3, 10, 5, 59, 0, 0, newYork), "oncall"},
+		{time.Date(2026, 3, 10, 6, 0, 0, 0, newYork), "main"},
+		// 03:00 UTC on Tuesday is still Monday evening in New York.
+		{time.Date(2026, 3, 10, 3, 0, 0, 0, time.UTC), "oncall"},
+	}
+	for _, tt := range tests {
+		route := r.ResolveRoute(RouteInput{Channel: "slack", Now: tt.now})
+		if route.AgentID != tt.wantAgent {
+			t.Errorf("%s: AgentID = %s, want %s", tt.now, route.AgentID, tt.wantAgent)
+		}
+		if tt.wantAgent == "oncall" && route.MatchedBy != "binding.account+schedule" {
+			t.Errorf("%s: MatchedBy = %s, want binding.account+schedule", tt.now, route.MatchedBy)
+		}
+	}
+}
+
+func TestResolveRoute_MediaBindingAndSpecificity(t *testing.T) {
+	hasMedia := true
+	bindings := []config.AgentBinding{
+		{AgentID: "main", Match: config.BindingMatch{Channel: "telegram"}},
+		{AgentID: "vision", Match: config.BindingMatch{Channel: "telegram", HasMedia: &hasMedia}},
+		{AgentID: "coder", Match: config.BindingMatch{
+			Channel:     "telegram",
+			HasMedia:    &hasMedia,
+			SenderRoles: []string{"dev"},
+		}},
+	}
+	r := NewRouteResolver(testConfig(conditionAgents(), bindings))
+
+	tests := []struct {
+		input     RouteInput
+		wantAgent string
+		wantBy    string
+	}{
+		{RouteInput{Channel: "telegram"}, "main", "binding.account"},
+		{RouteInput{Channel: "telegram", HasMedia: true}, "vision", "binding.account+media"},
+		{RouteInput{Channel: "telegram", HasMedia: true, SenderRoles: []string{"dev"}}, "coder", "binding.account+role+media"},
+	}
+	for _, tt := range tests {
+		route := r.ResolveRoute(tt.input)
+		if route.AgentID != tt.wantAgent || route.MatchedBy != tt.wantBy {
+			t.Errorf("%+v: got %s by %s, want %s by %s", tt.input, route.AgentID, route.MatchedBy, tt.wantAgent, tt.wantBy)
+		}
+	}
+}
+
+func TestResolveRoute_InvalidConditionsNeverMatch(t *testing.T) {
+	bindings := []config.AgentBinding{
+		{AgentID: "coder", Match: config.BindingMatch{Channel: "cli", Content: &config.ContentMatch{Regex: "("}}},
+		{AgentID: "oncall", Match: config.BindingMatch{Channel: "cli", Schedule: &config.ScheduleMatch{Timezone: "Nowhere/Land"}}},
+		{AgentID: "vision", Match: config.BindingMatch{Channel: "cli", Schedule: &config.ScheduleMatch{From: "25:00"}}},
+	}
+	r := NewRouteResolver(testConfig(conditionAgents(), bindings))
+
+	route := r.ResolveRoute(RouteInput{Channel: "cli", Content: "("})
+	if route.AgentID != "main" || route.MatchedBy != "default" {
+		t.Errorf("got %s by %s, want main by default", route.AgentID, route.MatchedBy)
+	}
+}
@@ -1,7 +1,10 @@
 package routing
 
 import (
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -14,6 +17,13 @@ type RouteInput struct {
 	ParentPeer *RoutePeer
 	GuildID    string
 	TeamID     string
+
+	// Tested by binding conditions.
+	Content     string
+	SenderID    string
+	SenderRoles []string // roles the channel reports for the sender
+	HasMedia    bool
+	Now         time.Time // zero for the current time
 }
 
 // ResolvedRoute is the result of agent routing.
@@ -23,12 +33,17 @@ type ResolvedRoute struct {
 	AccountID      string
 	SessionKey     string
 	MainSessionKey string
-	MatchedBy      string // "binding.peer", "binding.peer.parent", "binding.guild", "binding.team", "binding.account", "binding.channel", "default"
+	// MatchedBy is "binding.content", "binding.peer", "binding.peer.parent",
+	// "binding.guild", "binding.team", "binding.account", "binding.channel" or
+	// "default", followed by the conditions the binding also required, such as
+	// "binding.channel+role+schedule".
+	MatchedBy string
 }
 
 // RouteResolver determines which agent handles a message based on config bindings.
 type RouteResolver struct {
-	cfg *config.Config
+	cfg     *config.Config
+	regexps sync.Map // content regex -> *regexp.Regexp, or nil if invalid
 }
 
 // NewRouteResolver creates a new route resolver.
@@ -37,8 +52,12 @@ func NewRouteResolver(cfg *config.Config) *RouteResolver {
 }
 
 // ResolveRoute determines which agent handles the message and constructs session keys.
-// Implements the 7-level priority cascade:
-// peer > parent_peer > guild > team > account > channel_wildcard > default
+// Implements the 8-level priority cascade:
+// content > peer > parent_peer > guild > team > account > channel_wildcard > default
+//
+// Bindings whose conditions (content, sender roles, schedule, media) don't
+// hold are ignored. Within a level, bindings with more conditions are tried
+// first, then bindings in config order.
 func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 	channel := strings.ToLower(strings.TrimSpace(input.Channel))
 	accountID := NormalizeAccountID(input.AccountID)
@@ -50,7 +69,7 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 	}
 	identityLinks := r.cfg.Session.IdentityLinks
 
-	bindings := r.filterBindings(channel, accountID)
+	bindings, suffixes := r.activeBindings(r.filterBindings(channel, accountID), r.factsFor(input, channel))
 
 	choose := func(agentID string, matchedBy string) ResolvedRoute {
 		resolvedAgentID := r.pickAgentID(agentID)
@@ -73,48 +92,57 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 		}
 	}
 
-	// Priority 1: Peer binding
+	chooseBinding := func(match *config.AgentBinding, matchedBy string) ResolvedRoute {
+		return choose(match.AgentID, matchedBy+suffixes[match])
+	}
+
+	// Priority 1: Content binding
+	if match := r.findContentMatch(bindings, input); match != nil {
+		return chooseBinding(match, "binding.content")
+	}
+
+	// Priority 2: Peer binding
 	if peer != nil && strings.TrimSpace(peer.ID) != "" {
 		if match := r.findPeerMatch(bindings, peer); match != nil {
-			return choose(match.AgentID, "binding.peer")
+			return chooseBinding(match, "binding.peer")
 		}
 	}
 
-	// Priority 2: Parent peer binding
+	// Priority 3: Parent peer binding
 	parentPeer := input.ParentPeer
 	if parentPeer != nil && strings.TrimSpace(parentPeer.ID) != "" {
 		if match := r.findPeerMatch(bindings, parentPeer); match != nil {
-			return choose(match.AgentID, "binding.peer.parent")
+			return chooseBinding(match, "binding.peer.parent")
 		}
 	}
 
-	// Priority 3: Guild binding
+	// Priority 4: Guild binding
 	guildID := strings.TrimSpace(input.GuildID)
 	if guildID != "" {
 		if match := r.findGuildMatch(bindings, guildID); match != nil {
-			return choose(match.AgentID, "binding.guild")
+			return chooseBinding(match, "binding.guild")
 		}
 	}
 
-	// Priority 4: Team binding
+	// Priority 5: Team binding
 	teamID := strings.TrimSpace(input.TeamID)
 	if teamID != "" {
 		if match := r.findTeamMatch(bindings, teamID); match != nil {
-			return choose(match.AgentID, "binding.team")
+			return chooseBinding(match, "binding.team")
 		}
 	}
 
-	// Priority 5: Account binding
+	// Priority 6: Account binding
 	if match := r.findAccountMatch(bindings); match != nil {
-		return choose(match.AgentID, "binding.account")
+		return chooseBinding(match, "binding.account")
 	}
 
-	// Priority 6: Channel wildcard binding
+	// Priority 7: Channel wildcard binding
 	if match := r.findChannelWildcardMatch(bindings); match != nil {
-		return choose(match.AgentID, "binding.channel")
+		return chooseBinding(match, "binding.channel")
 	}
 
-	// Priority 7: Default agent
+	// Priority 8: Default agent
 	return choose(r.resolveDefaultAgentID(), "default")
 }
 
@@ -122,7 +150,7 @@ func (r *RouteResolver) filterBindings(channel, accountID string) []config.Agent
 	var filtered []config.AgentBinding
 	for _, b := range r.cfg.Bindings {
 		matchChannel := strings.ToLower(strings.TrimSpace(b.Match.Channel))
-		if matchChannel == "" || matchChannel != channel {
+		if matchChannel == "" || matchChannel != channel && matchChannel != "*" {
 			continue
 		}
 		if !matchesAccountID(b.Match.AccountID, accountID) {
@@ -133,6 +161,52 @@ func (r *RouteResolver) filterBindings(channel, accountID string) []config.Agent
 	return filtered
 }
 
+// activeBindings returns the bindings whose conditions hold, the more
+// specific first, with the MatchedBy suffix of each.
+func (r *RouteResolver) activeBindings(
+	bindings []config.AgentBinding,
+	facts routeFacts,
+) ([]config.AgentBinding, map[*config.AgentBinding]string) {
+	var active []config.AgentBinding
+	for i := range bindings {
+		if _, ok := r.conditionsHold(&bindings[i], facts); ok {
+			active = append(active, bindings[i])
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		return conditionCount(&active[i]) > conditionCount(&active[j])
+	})
+
+	suffixes := make(map[*config.AgentBinding]string, len(active))
+	for i := range active {
+		suffixes[&active[i]], _ = r.conditionsHold(&active[i], facts)
+	}
+	return active, suffixes
+}
+
+// findContentMatch returns the first binding with a content condition whose
+// peer, guild and team, where set, also match input. Its content already
+// matched in activeBindings.
+func (r *RouteResolver) findContentMatch(bindings []config.AgentBinding, input RouteInput) *config.AgentBinding {
+	for i := range bindings {
+		b := &bindings[i]
+		if b.Match.Content == nil {
+			continue
+		}
+		if b.Match.Peer != nil && !peerMatches(b.Match.Peer, input.Peer) && !peerMatches(b.Match.Peer, input.ParentPeer) {
+			continue
+		}
+		if guild := strings.TrimSpace(b.Match.GuildID); guild != "" && guild != strings.TrimSpace(input.GuildID) {
+			continue
+		}
+		if team := strings.TrimSpace(b.Match.TeamID); team != "" && team != strings.TrimSpace(input.TeamID) {
+			continue
+		}
+		return b
+	}
+	return nil
+}
+
 func matchesAccountID(matchAccountID, actual string) bool {
 	trimmed := strings.TrimSpace(matchAccountID)
 	if trimmed == "" {
@@ -147,21 +221,25 @@ func matchesAccountID(matchAccountID, actual string) bool {
 func (r *RouteResolver) findPeerMatch(bindings []config.AgentBinding, peer *RoutePeer) *config.AgentBinding {
 	for i := range bindings {
 		b := &bindings[i]
-		if b.Match.Peer == nil {
-			continue
-		}
-		peerKind := strings.ToLower(strings.TrimSpace(b.Match.Peer.Kind))
-		peerID := strings.TrimSpace(b.Match.Peer.ID)
-		if peerKind == "" || peerID == "" {
-			continue
-		}
-		if peerKind == strings.ToLower(peer.Kind) && peerID == peer.ID {
+		if peerMatches(b.Match.Peer, peer) {
 			return b
 		}
 	}
 	return nil
 }
 
+func peerMatches(match *config.PeerMatch, peer *RoutePeer) bool {
+	if match == nil || peer == nil {
+		return false
+	}
+	peerKind := strings.ToLower(strings.TrimSpace(match.Kind))
+	peerID := strings.TrimSpace(match.ID)
+	if peerKind == "" || peerID == "" {
+		return false
+	}
+	return peerKind == strings.ToLower(peer.Kind) && peerID == peer.ID
+}
+
 func (r *RouteResolver) findGuildMatch(bindings []config.AgentBinding, guildID string) *config.AgentBinding {
 	for i := range bindings {
 		b := &bindings[i]
@@ -0,0 +1,31 @@
+package routing
+
+import "strings"
+
+// SenderMatches reports whether senderID is the sender entry names. Either
+// side may use the compound "id|username" form that Telegram sender IDs
+// take, and entry may name a username with a leading "@".
+func SenderMatches(senderID, entry string) bool {
+	idPart := senderID
+	userPart := ""
+	if idx := strings.Index(senderID, "|"); idx > 0 {
+		idPart = senderID[:idx]
+		userPart = senderID[idx+1:]
+	}
+
+	trimmed := strings.TrimPrefix(entry, "@")
+	entryID := trimmed
+	entryUser := ""
+	if idx := strings.Index(trimmed, "|"); idx > 0 {
+		entryID = trimmed[:idx]
+		entryUser = trimmed[idx+1:]
+	}
+
+	return senderID == entry ||
+		idPart == entry ||
+		senderID == trimmed ||
+		idPart == trimmed ||
+		idPart == entryID ||
+		(entryUser != "" && senderID == entryUser) ||
+		(userPart != "" && (userPart == entry || userPart == trimmed || userPart == entryUser))
+}
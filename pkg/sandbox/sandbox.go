@@ -0,0 +1,27 @@
+// Package sandbox runs commands isolated from the host. On Linux the command
+// gets its own user, mount, PID, IPC and (unless allowed) network namespaces,
+// sees only the workspace (read-write) and a few system directories
+// (read-only), is confined by Landlock where the kernel supports it, and runs
+// under resource limits. Other platforms have no sandbox.
+package sandbox
+
+// DefaultReadOnlyPaths are the host paths visible in the sandbox when
+// Options.ReadOnlyPaths is empty: enough to run common tools.
+var DefaultReadOnlyPaths = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc"}
+
+// Options describes the sandbox a command runs in.
+type Options struct {
+	Workspace     string   `json:"workspace"`       // Mounted read-write at the same path
+	ReadOnlyPaths []string `json:"read_only_paths"` // Host paths visible read-only; missing ones are skipped
+	AllowNetwork  bool     `json:"allow_network"`   // Share the host's network instead of having none
+	MaxCPUSeconds int      `json:"max_cpu_seconds"` // RLIMIT_CPU; 0 for no limit
+	MaxMemoryMB   int      `json:"max_memory_mb"`   // RLIMIT_DATA; 0 for no limit
+	MaxProcesses  int      `json:"max_processes"`   // RLIMIT_NPROC; 0 for no limit
+}
+
+func (o Options) readOnlyPaths() []string {
+	if len(o.ReadOnlyPaths) == 0 {
+		return DefaultReadOnlyPaths
+	}
+	return o.ReadOnlyPaths
+}
@@ -0,0 +1,401 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// specEnv carries the spec from Command to the re-executed binary, which
+// sets up the sandbox in init and then executes the command in place.
+const specEnv = "PICOCLAW_SANDBOX_SPEC"
+
+const (
+	capSysAdmin          = 21
+	prSetNoNewPrivs      = 38
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+
+	nobodyID = 65534
+
+	// initFailedStatus is the exit status when the sandbox cannot be set up.
+	initFailedStatus = 126
+
+	// lockedMountFlags are the flags a remount inside a user namespace must
+	// keep on mounts inherited from the host.
+	lockedMountFlags = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+		syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+)
+
+type spec struct {
+	Options
+	Dir  string   `json:"dir"`
+	Args []string `json:"args"`
+}
+
+func init() {
+	if data, ok := os.LookupEnv(specEnv); ok {
+		runInit(data)
+	}
+}
+
+// Available reports why commands cannot be sandboxed on this system, or nil
+// if they can.
+func Available() error {
+	for _, path := range []string{"/proc/sys/kernel/unprivileged_userns_clone", "/proc/sys/user/max_user_namespaces"} {
+		if data, err := os.ReadFile(path); err == nil && strings.TrimSpace(string(data)) == "0" {
+			return fmt.Errorf("user namespaces are disabled (%s is 0)", path)
+		}
+	}
+	if _, err := os.Executable(); err != nil {
+		return fmt.Errorf("cannot locate own executable: %w", err)
+	}
+	return nil
+}
+
+// Command returns a command that runs name with args inside a sandbox
+// described by opts, in dir. dir must be visible in the sandbox, i.e. inside
+// the workspace or a read-only path.
+func Command(ctx context.Context, opts Options, dir, name string, args ...string) (*exec.Cmd, error) {
+	if err := Available(); err != nil {
+		return nil, err
+	}
+	if opts.Workspace == "" {
+		return nil, fmt.Errorf("sandbox workspace is required")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	// Bind mounts follow symlinks, so the sandbox sees resolved paths.
+	if opts.Workspace, err = filepath.EvalSymlinks(opts.Workspace); err != nil {
+		return nil, fmt.Errorf("sandbox workspace: %w", err)
+	}
+	if dir == "" {
+		dir = opts.Workspace
+	} else if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return nil, fmt.Errorf("sandbox working directory: %w", err)
+	}
+
+	data, err := json.Marshal(spec{Options: opts, Dir: dir, Args: append([]string{name}, args...)})
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, self)
+	cmd.Args = []string{"picoclaw-sandbox"}
+	cmd.Env = append(os.Environ(), specEnv+"="+string(data))
+
+	// Running as root inside would give the command back all capabilities
+	// of the namespace on exec, so host root is seen as nobody instead.
+	uid, gid := os.Getuid(), os.Getgid()
+	innerUID, innerGID := uid, gid
+	if uid == 0 {
+		innerUID, innerGID = nobodyID, nobodyID
+	}
+	flags := syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !opts.AllowNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 uintptr(flags),
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: innerUID, HostID: uid, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: innerGID, HostID: gid, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		// Mounting needs CAP_SYS_ADMIN in the new namespace; it is dropped
+		// again before the command runs.
+		AmbientCaps: []uintptr{capSysAdmin},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	return cmd, nil
+}
+
+// runInit sets up the sandbox in the re-executed binary and replaces it with
+// the command. It only returns by exiting.
+func runInit(data string) {
+	// Landlock, no_new_privs and capabilities are per thread; they must be
+	// set on the thread that calls execve.
+	runtime.LockOSThread()
+
+	var s spec
+	err := json.Unmarshal([]byte(data), &s)
+	if err == nil {
+		err = s.enter()
+	}
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(initFailedStatus)
+}
+
+func (s *spec) enter() error {
+	os.Unsetenv(specEnv)
+	if len(s.Args) == 0 {
+		return fmt.Errorf("no command")
+	}
+
+	if err := s.setupRoot(); err != nil {
+		return err
+	}
+	if err := os.Chdir(s.Dir); err != nil {
+		return err
+	}
+	if err := setLimits(s.Options); err != nil {
+		return err
+	}
+	path, err := exec.LookPath(s.Args[0])
+	if err != nil {
+		return err
+	}
+
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("set no_new_privs: %w", errno)
+	}
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("drop capabilities: %w", errno)
+	}
+
+	readOnly := append([]string{"/proc"}, s.readOnlyPaths()...)
+	readWrite := []string{s.Workspace, "/tmp", "/dev"}
+	if err := restrictPaths(readOnly, readWrite); err != nil {
+		return err
+	}
+	return syscall.Exec(path, s.Args, os.Environ())
+}
+
+// setupRoot replaces the root filesystem with a read-only tmpfs holding the
+// read-only paths, the workspace, a private /tmp, a minimal /dev and /proc.
+//
+// The new root is assembled at /newroot inside a scratch tmpfs that first
+// becomes the root, with the host's root at /oldroot, so that host paths
+// under /tmp stay reachable while binding them.
+func (s *spec) setupRoot() error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount scratch tmpfs: %w", err)
+	}
+	for _, dir := range []string{"/tmp/oldroot", "/tmp/newroot"} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	if err := syscall.PivotRoot("/tmp", "/tmp/oldroot"); err != nil {
+		return fmt.Errorf("pivot to scratch root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", "/newroot", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount root tmpfs: %w", err)
+	}
+
+	for _, path := range s.readOnlyPaths() {
+		if err := bindHostPath(path, false, true); err != nil {
+			return err
+		}
+	}
+	if err := mkdirAndMount("/newroot/tmp", "tmpfs", "mode=1777"); err != nil {
+		return err
+	}
+	if err := bindHostPath(s.Workspace, true, false); err != nil {
+		return err
+	}
+	if err := setupDev(); err != nil {
+		return err
+	}
+	// Mounting procfs is refused where the host's /proc is partly masked,
+	// as in most containers; commands then run without /proc.
+	if err := os.MkdirAll("/newroot/proc", 0o755); err != nil {
+		return err
+	}
+	_ = syscall.Mount("proc", "/newroot/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+
+	// Switch to the new root and detach everything of the host.
+	if err := os.Chdir("/newroot"); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot to sandbox root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach host root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	return syscall.Mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, "")
+}
+
+func mkdirAndMount(target, fstype, data string) error {
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	if err := syscall.Mount(fstype, target, fstype, syscall.MS_NOSUID|syscall.MS_NODEV, data); err != nil {
+		return fmt.Errorf("mount %s on %s: %w", fstype, target, err)
+	}
+	return nil
+}
+
+// bindHostPath makes the host's path visible at the same path in the new
+// root. Symlinks are recreated rather than followed, so /bin -> usr/bin
+// stays a link.
+func bindHostPath(path string, writable, optional bool) error {
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("sandbox path %q is not absolute", path)
+	}
+	src, dst := "/oldroot"+path, "/newroot"+path
+
+	info, err := os.Lstat(src)
+	if err != nil {
+		if optional && os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.MkdirAll(dst, 0o755); err != nil {
+			return err
+		}
+	default:
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+
+	if err := syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", path, err)
+	}
+	if writable {
+		return nil
+	}
+	return remountReadOnly(dst)
+}
+
+// remountReadOnly makes target and every mount below it read-only.
+func remountReadOnly(target string) error {
+	points, err := mountPointsUnder(target)
+	if err != nil {
+		return err
+	}
+	for _, point := range points {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(point, &st); err != nil {
+			return fmt.Errorf("statfs %s: %w", point, err)
+		}
+		flags := uintptr(syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY) | uintptr(st.Flags)&lockedMountFlags
+		if err := syscall.Mount("", point, "", flags, ""); err != nil {
+			return fmt.Errorf("remount %s read-only: %w", point, err)
+		}
+	}
+	return nil
+}
+
+// mountPointsUnder lists target and the mount points below it.
+func mountPointsUnder(target string) ([]string, error) {
+	f, err := os.Open("/oldroot/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	points := []string{target}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		point := unescapeMountPath(fields[4])
+		if strings.HasPrefix(point, target+"/") {
+			points = append(points, point)
+		}
+	}
+	return points, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space, ...) used in
+// /proc/self/mountinfo.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// setupDev populates a tmpfs /dev with the host's harmless devices.
+func setupDev() error {
+	if err := mkdirAndMount("/newroot/dev", "tmpfs", "mode=0755"); err != nil {
+		return err
+	}
+	for _, name := range []string{"null", "zero", "full", "random", "urandom", "tty"} {
+		if err := bindHostPath("/dev/"+name, true, true); err != nil {
+			return err
+		}
+	}
+	for name, target := range map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	} {
+		if err := os.Symlink(target, "/newroot/dev/"+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setLimits(o Options) error {
+	limits := []struct {
+		resource int
+		value    int
+		scale    uint64
+	}{
+		{syscall.RLIMIT_CPU, o.MaxCPUSeconds, 1},
+		{syscall.RLIMIT_DATA, o.MaxMemoryMB, 1 << 20},
+		{rlimitNproc, o.MaxProcesses, 1},
+	}
+	for _, l := range limits {
+		if l.value <= 0 {
+			continue
+		}
+		v := uint64(l.value) * l.scale
+		if err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: v, Max: v}); err != nil {
+			return fmt.Errorf("setrlimit %d: %w", l.resource, err)
+		}
+	}
+	return nil
+}
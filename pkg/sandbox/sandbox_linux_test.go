@@ -0,0 +1,74 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runSandboxed runs a shell script in a sandbox over workspace, skipping the
+// test where this system cannot create one.
+func runSandboxed(t *testing.T, opts Options, script string) (string, error) {
+	t.Helper()
+	cmd, err := Command(context.Background(), opts, "", "sh", "-c", script)
+	if err != nil {
+		t.Skipf("sandbox unavailable: %v", err)
+	}
+	out, err := cmd.CombinedOutput()
+	if strings.HasPrefix(string(out), "sandbox: ") {
+		t.Skipf("sandbox cannot be set up here: %s", out)
+	}
+	return string(out), err
+}
+
+func TestCommand_ConfinesFilesystem(t *testing.T) {
+	workspace := t.TempDir()
+	secret := filepath.Join(filepath.Dir(workspace), "secret-"+filepath.Base(workspace))
+	if err := os.WriteFile(secret, []byte("hidden"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(secret)
+
+	out, err := runSandboxed(t, Options{Workspace: workspace}, `
+		echo hello > out.txt && echo wrote
+		cat `+secret+` 2>/dev/null || echo hidden
+		touch /usr/sandbox-probe 2>/dev/null || echo read-only
+		echo scratch > /tmp/x && cat /tmp/x
+		pwd
+	`)
+	if err != nil {
+		t.Fatalf("sandboxed command failed: %v\n%s", err, out)
+	}
+
+	for _, want := range []string{"wrote", "hidden", "read-only", "scratch", workspace} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "out.txt")); string(data) != "hello\n" {
+		t.Errorf("expected the workspace write to reach the host, got %q", data)
+	}
+	if _, err := os.Stat("/tmp/x"); err == nil && !strings.HasPrefix(workspace, "/tmp/x") {
+		t.Error("expected /tmp in the sandbox to be private")
+	}
+}
+
+func TestCommand_NetworkAndLimits(t *testing.T) {
+	out, err := runSandboxed(t, Options{Workspace: t.TempDir(), MaxCPUSeconds: 7}, `
+		ulimit -t
+		cat /proc/net/dev 2>/dev/null | grep -c ':' || true
+	`)
+	if err != nil {
+		t.Fatalf("sandboxed command failed: %v\n%s", err, out)
+	}
+	lines := strings.Fields(out)
+	if len(lines) == 0 || lines[0] != "7" {
+		t.Errorf("expected the CPU limit to apply, got:\n%s", out)
+	}
+	// Without network access only the loopback interface exists.
+	if len(lines) > 1 && lines[1] != "1" {
+		t.Errorf("expected only loopback in the sandbox, got %s interfaces", lines[1])
+	}
+}
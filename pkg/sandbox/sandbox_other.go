@@ -0,0 +1,20 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// Available reports why commands cannot be sandboxed on this system.
+func Available() error {
+	return fmt.Errorf("sandboxing is only supported on Linux, not %s", runtime.GOOS)
+}
+
+// Command is not supported outside Linux.
+func Command(ctx context.Context, opts Options, dir, name string, args ...string) (*exec.Cmd, error) {
+	return nil, Available()
+}
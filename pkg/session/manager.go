@@ -145,6 +145,26 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	session.Updated = time.Now()
 }
 
+// DropOldest removes the n oldest messages of a session, for example after
+// they have been archived. Messages added since the caller read the history
+// are kept.
+func (sm *SessionManager) DropOldest(key string, n int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok || n <= 0 {
+		return
+	}
+
+	if n >= len(session.Messages) {
+		session.Messages = []providers.Message{}
+	} else {
+		session.Messages = append([]providers.Message{}, session.Messages[n:]...)
+	}
+	session.Updated = time.Now()
+}
+
 // sanitizeFilename converts a session key into a cross-platform safe filename.
 // Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
 // volume separator on Windows, so filepath.Base would misinterpret the key.
@@ -72,3 +72,22 @@ func TestSave_RejectsPathTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestDropOldest_KeepsNewerMessages(t *testing.T) {
+	sm := NewSessionManager("")
+	key := "telegram:1"
+	for _, content := range []string{"a", "b", "c", "d"} {
+		sm.AddMessage(key, "user", content)
+	}
+
+	sm.DropOldest(key, 3)
+	history := sm.GetHistory(key)
+	if len(history) != 1 || history[0].Content != "d" {
+		t.Fatalf("expected only %q to remain, got %+v", "d", history)
+	}
+
+	sm.DropOldest(key, 5)
+	if got := len(sm.GetHistory(key)); got != 0 {
+		t.Errorf("expected empty history, got %d messages", got)
+	}
+}
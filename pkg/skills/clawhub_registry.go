@@ -10,6 +10,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/egress"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
@@ -84,6 +85,11 @@ func NewClawHubRegistry(cfg ClawHubConfig) *ClawHubRegistry {
 	}
 }
 
+// SetEgressPolicy makes registry requests, including redirects, follow policy.
+func (c *ClawHubRegistry) SetEgressPolicy(policy *egress.Policy) {
+	c.client = policy.Client(c.client.Timeout)
+}
+
 func (c *ClawHubRegistry) Name() string {
 	return "clawhub"
 }
@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/egress"
 )
 
 const (
@@ -62,6 +64,7 @@ type SkillRegistry interface {
 type RegistryConfig struct {
 	ClawHub               ClawHubConfig
 	MaxConcurrentSearches int
+	Egress                *egress.Policy // nil leaves registry requests unrestricted
 }
 
 // ClawHubConfig configures the ClawHub registry.
@@ -101,7 +104,11 @@ func NewRegistryManagerFromConfig(cfg RegistryConfig) *RegistryManager {
 		rm.maxConcurrent = cfg.MaxConcurrentSearches
 	}
 	if cfg.ClawHub.Enabled {
-		rm.AddRegistry(NewClawHubRegistry(cfg.ClawHub))
+		hub := NewClawHubRegistry(cfg.ClawHub)
+		if cfg.Egress != nil {
+			hub.SetEgressPolicy(cfg.Egress)
+		}
+		rm.AddRegistry(hub)
 	}
 	return rm
 }
@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	ApprovalAsk   = "ask"
+	ApprovalAllow = "allow"
+	ApprovalDeny  = "deny"
+
+	defaultApprovalTimeout = 5 * time.Minute
+)
+
+// ApprovalRequest is a tool call waiting for a human decision.
+type ApprovalRequest struct {
+	ID       string
+	Tool     string
+	Args     map[string]any
+	Channel  string
+	ChatID   string
+	SenderID string
+}
+
+// Prompt returns the text shown to approvers.
+func (r ApprovalRequest) Prompt() string {
+	args, _ := json.Marshal(r.Args)
+	return fmt.Sprintf("Approval needed [%s]: the agent wants to run %s with %s",
+		r.ID, r.Tool, utils.Truncate(string(args), 500))
+}
+
+// ApprovalRequester delivers the prompt of req to the chat it came from.
+type ApprovalRequester func(ctx context.Context, req ApprovalRequest) error
+
+type approvalRule struct {
+	tool    string
+	pattern *regexp.Regexp
+	action  string
+}
+
+type pendingApproval struct {
+	req      ApprovalRequest
+	decision chan bool
+}
+
+// ApprovalPolicy decides which tool calls need a human to say yes, and holds
+// those calls until someone in the originating chat answers or the timeout
+// passes.
+type ApprovalPolicy struct {
+	rules     []approvalRule
+	timeout   time.Duration
+	requester ApprovalRequester
+
+	mu      sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+// NewApprovalPolicy validates and compiles the rules of cfg.
+func NewApprovalPolicy(cfg config.ApprovalConfig) (*ApprovalPolicy, error) {
+	p := &ApprovalPolicy{
+		timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		pending: make(map[string]*pendingApproval),
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultApprovalTimeout
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	for _, rule := range cfg.Rules {
+		r := approvalRule{tool: rule.Tool, action: strings.ToLower(rule.Action)}
+		if rule.Pattern != "" {
+			r.pattern = regexp.MustCompile(rule.Pattern)
+		}
+		p.rules = append(p.rules, r)
+	}
+	return p, nil
+}
+
+// SetRequester sets how approval prompts are delivered. Without one, calls
+// that need approval are rejected.
+func (p *ApprovalPolicy) SetRequester(requester ApprovalRequester) {
+	p.requester = requester
+}
+
+// Action returns what the first matching rule says about a call: ask, allow
+// or deny. Calls matching no rule are allowed.
+func (p *ApprovalPolicy) Action(tool string, args map[string]any) string {
+	var encoded string
+	for _, rule := range p.rules {
+		if rule.tool != "*" && rule.tool != tool {
+			continue
+		}
+		if rule.pattern != nil {
+			if encoded == "" {
+				data, _ := json.Marshal(args)
+				encoded = string(data)
+			}
+			if !rule.pattern.MatchString(encoded) {
+				continue
+			}
+		}
+		return rule.action
+	}
+	return ApprovalAllow
+}
+
+// Check applies the policy to a tool call. It returns nil when the call may
+// run; otherwise the result to hand back to the LLM instead. Calls that need
+// approval block until they are resolved, timed out, or ctx is done.
+func (p *ApprovalPolicy) Check(ctx context.Context, tool string, args map[string]any, execCtx ExecutionContext) *ToolResult {
+	switch p.Action(tool, args) {
+	case ApprovalAllow:
+		return nil
+	case ApprovalDeny:
+		return ErrorResult(fmt.Sprintf("Calling %s with these arguments is not permitted by policy.", tool))
+	}
+
+	if p.requester == nil || execCtx.ChatID == "" || constants.IsInternalChannel(execCtx.Channel) {
+		return ErrorResult(fmt.Sprintf(
+			"Calling %s needs human approval, which cannot be requested from this channel. "+
+				"Tell the user what you wanted to run instead.", tool))
+	}
+
+	pending := &pendingApproval{
+		req: ApprovalRequest{
+			ID:       newApprovalID(),
+			Tool:     tool,
+			Args:     args,
+			Channel:  execCtx.Channel,
+			ChatID:   execCtx.ChatID,
+			SenderID: execCtx.SenderID,
+		},
+		decision: make(chan bool, 1),
+	}
+	p.mu.Lock()
+	p.pending[pending.req.ID] = pending
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.pending, pending.req.ID)
+		p.mu.Unlock()
+	}()
+
+	logger.InfoCF("tool", "Waiting for approval", map[string]any{
+		"tool":    tool,
+		"id":      pending.req.ID,
+		"channel": execCtx.Channel,
+		"chat_id": execCtx.ChatID,
+	})
+	if err := p.requester(ctx, pending.req); err != nil {
+		return ErrorResult(fmt.Sprintf("Could not ask for approval to call %s: %v", tool, err)).WithError(err)
+	}
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	select {
+	case approved := <-pending.decision:
+		if approved {
+			return nil
+		}
+		return ErrorResult(fmt.Sprintf(
+			"The user rejected the %s call. Do not retry it; ask the user how to proceed.", tool))
+	case <-timer.C:
+		return ErrorResult(fmt.Sprintf("The %s call was not approved within %s and was cancelled.", tool, p.timeout))
+	case <-ctx.Done():
+		return ErrorResult(fmt.Sprintf("The %s call was cancelled while waiting for approval.", tool)).WithError(ctx.Err())
+	}
+}
+
+// Resolve answers a pending request of the given chat. An empty id selects
+// the chat's only pending request.
+func (p *ApprovalPolicy) Resolve(channel, chatID, id string, approved bool) (ApprovalRequest, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var match *pendingApproval
+	if id != "" {
+		match = p.pending[id]
+		if match != nil && (match.req.Channel != channel || match.req.ChatID != chatID) {
+			match = nil
+		}
+	} else {
+		var ids []string
+		for _, pa := range p.pending {
+			if pa.req.Channel == channel && pa.req.ChatID == chatID {
+				match = pa
+				ids = append(ids, pa.req.ID)
+			}
+		}
+		if len(ids) > 1 {
+			sort.Strings(ids)
+			return ApprovalRequest{}, fmt.Errorf("several requests are waiting (%s); specify an ID", strings.Join(ids, ", "))
+		}
+	}
+	if match == nil && id == "" {
+		return ApprovalRequest{}, fmt.Errorf("no approval request is pending")
+	}
+	if match == nil {
+		return ApprovalRequest{}, fmt.Errorf("no pending approval request %s", id)
+	}
+
+	delete(p.pending, match.req.ID)
+	match.decision <- approved
+	return match.req, nil
+}
+
+func newApprovalID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b)
+}
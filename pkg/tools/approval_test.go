@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestApprovalPolicy(t *testing.T, timeout int, rules ...config.ApprovalRule) *ApprovalPolicy {
+	t.Helper()
+	policy, err := NewApprovalPolicy(config.ApprovalConfig{TimeoutSeconds: timeout, Rules: rules})
+	if err != nil {
+		t.Fatalf("NewApprovalPolicy() error: %v", err)
+	}
+	return policy
+}
+
+func TestApprovalPolicy_FirstMatchingRuleWins(t *testing.T) {
+	policy := newTestApprovalPolicy(t, 0,
+		config.ApprovalRule{Tool: "exec", Pattern: `"command":"ls\b`, Action: "allow"},
+		config.ApprovalRule{Tool: "exec", Pattern: `rm\s+-rf`, Action: "deny"},
+		config.ApprovalRule{Tool: "*", Action: "ask"},
+	)
+
+	tests := []struct {
+		tool string
+		args map[string]any
+		want string
+	}{
+		{"exec", map[string]any{"command": "ls -la"}, ApprovalAllow},
+		{"exec", map[string]any{"command": "rm -rf /tmp/x"}, ApprovalDeny},
+		{"exec", map[string]any{"command": "make install"}, ApprovalAsk},
+		{"write_file", map[string]any{"path": "a.txt"}, ApprovalAsk},
+	}
+	for _, tt := range tests {
+		if got := policy.Action(tt.tool, tt.args); got != tt.want {
+			t.Errorf("Action(%s, %v) = %s, want %s", tt.tool, tt.args, got, tt.want)
+		}
+	}
+
+	if got := newTestApprovalPolicy(t, 0).Action("exec", nil); got != ApprovalAllow {
+		t.Errorf("expected calls matching no rule to be allowed, got %s", got)
+	}
+}
+
+func TestNewApprovalPolicy_RejectsInvalidRules(t *testing.T) {
+	for _, rule := range []config.ApprovalRule{
+		{Tool: "exec", Action: "maybe"},
+		{Action: "ask"},
+		{Tool: "exec", Pattern: "(", Action: "ask"},
+	} {
+		if _, err := NewApprovalPolicy(config.ApprovalConfig{Rules: []config.ApprovalRule{rule}}); err == nil {
+			t.Errorf("expected an error for %+v", rule)
+		}
+	}
+}
+
+func TestToolRegistry_ApprovalApproveAndReject(t *testing.T) {
+	policy := newTestApprovalPolicy(t, 0, config.ApprovalRule{Tool: "exec", Action: "ask"})
+	prompts := make(chan ApprovalRequest, 1)
+	policy.SetRequester(func(ctx context.Context, req ApprovalRequest) error {
+		prompts <- req
+		return nil
+	})
+
+	r := NewToolRegistry()
+	r.Register(newMockTool("exec", "runs commands"))
+	r.SetApprovalPolicy(policy)
+	execCtx := ExecutionContext{Channel: "telegram", ChatID: "42"}
+
+	for _, approved := range []bool{true, false} {
+		done := make(chan *ToolResult, 1)
+		go func() {
+			done <- r.ExecuteWithContext(context.Background(), "exec", map[string]any{"command": "uptime"}, execCtx, nil)
+		}()
+		req := <-prompts
+		if !strings.Contains(req.Prompt(), "uptime") {
+			t.Errorf("prompt should show the arguments, got %q", req.Prompt())
+		}
+
+		// Another chat cannot answer the request.
+		if _, err := policy.Resolve("telegram", "99", req.ID, true); err == nil {
+			t.Error("expected a request to be answerable only from its own chat")
+		}
+		if _, err := policy.Resolve("telegram", "42", "", approved); err != nil {
+			t.Fatalf("Resolve() error: %v", err)
+		}
+
+		result := <-done
+		if approved && (result.IsError || result.ForLLM != "ok") {
+			t.Errorf("expected the approved call to run, got %+v", result)
+		}
+		if !approved && (!result.IsError || !strings.Contains(result.ForLLM, "rejected")) {
+			t.Errorf("expected the rejected call not to run, got %+v", result)
+		}
+	}
+}
+
+func TestApprovalPolicy_TimeoutAndInternalChannels(t *testing.T) {
+	policy := newTestApprovalPolicy(t, 0, config.ApprovalRule{Tool: "*", Action: "ask"})
+	policy.timeout = 20 * time.Millisecond
+	policy.SetRequester(func(ctx context.Context, req ApprovalRequest) error { return nil })
+
+	result := policy.Check(context.Background(), "exec", nil, ExecutionContext{Channel: "slack", ChatID: "C1"})
+	if result == nil || !strings.Contains(result.ForLLM, "not approved within") {
+		t.Errorf("expected a timeout result, got %+v", result)
+	}
+	if len(policy.pending) != 0 {
+		t.Errorf("expected the timed-out request to be dropped, got %d pending", len(policy.pending))
+	}
+
+	result = policy.Check(context.Background(), "exec", nil, ExecutionContext{Channel: "cli", ChatID: "direct"})
+	if result == nil || !result.IsError {
+		t.Errorf("expected calls from internal channels to be refused, got %+v", result)
+	}
+}
@@ -10,11 +10,42 @@ type Tool interface {
 	Execute(ctx context.Context, args map[string]any) *ToolResult
 }
 
-// ContextualTool is an optional interface that tools can implement
-// to receive the current message context (channel, chatID)
-type ContextualTool interface {
-	Tool
-	SetContext(channel, chatID string)
+// ExecutionContext describes the turn a tool call belongs to.
+// ToolRegistry.ExecuteWithContext attaches it to the context passed to
+// Tool.Execute, so a single tool instance can be shared across agents and
+// concurrent sessions without holding any per-turn state.
+type ExecutionContext struct {
+	Channel    string            // Originating channel (telegram, discord, cli, ...)
+	ChatID     string            // Originating chat ID within the channel
+	SenderID   string            // Sender of the message that started the turn
+	SessionKey string            // Session the turn's history is stored under
+	AgentID    string            // Agent running the turn
+	Metadata   map[string]string // Inbound message metadata (peer, guild, ...)
+	Callback   AsyncCallback     // Where async tools report completion; may be nil
+}
+
+type execCtxKey struct{}
+
+// WithExecutionContext returns a child context carrying ec.
+func WithExecutionContext(ctx context.Context, ec ExecutionContext) context.Context {
+	return context.WithValue(ctx, execCtxKey{}, ec)
+}
+
+// GetExecutionContext returns the ExecutionContext attached to ctx,
+// or the zero value if there is none.
+func GetExecutionContext(ctx context.Context) ExecutionContext {
+	ec, _ := ctx.Value(execCtxKey{}).(ExecutionContext)
+	return ec
+}
+
+// ToolChannel returns the originating channel of the current turn, or "" if unset.
+func ToolChannel(ctx context.Context) string {
+	return GetExecutionContext(ctx).Channel
+}
+
+// ToolChatID returns the originating chat ID of the current turn, or "" if unset.
+func ToolChatID(ctx context.Context) string {
+	return GetExecutionContext(ctx).ChatID
 }
 
 // AsyncCallback is a function type that async tools use to notify completion.
@@ -23,50 +54,29 @@ type ContextualTool interface {
 // The ctx parameter allows the callback to be canceled if the agent is shutting down.
 // The result parameter contains the tool's execution result.
 //
-// Example usage in an async tool:
+// Async tools return immediately with an AsyncResult and find the turn's
+// callback in its ExecutionContext, so they hold no per-turn state:
 //
 //	func (t *MyAsyncTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
-//	    // Start async work in background
+//	    callback := GetExecutionContext(ctx).Callback
 //	    go func() {
 //	        result := doAsyncWork()
-//	        if t.callback != nil {
-//	            t.callback(ctx, result)
+//	        if callback != nil {
+//	            callback(ctx, result)
 //	        }
 //	    }()
 //	    return AsyncResult("Async task started")
 //	}
 type AsyncCallback func(ctx context.Context, result *ToolResult)
 
-// AsyncTool is an optional interface that tools can implement to support
-// asynchronous execution with completion callbacks.
-//
-// Async tools return immediately with an AsyncResult, then notify completion
-// via the callback set by SetCallback.
-//
-// This is useful for:
-// - Long-running operations that shouldn't block the agent loop
-// - Subagent spawns that complete independently
-// - Background tasks that need to report results later
-//
-// Example:
-//
-//	type SpawnTool struct {
-//	    callback AsyncCallback
-//	}
-//
-//	func (t *SpawnTool) SetCallback(cb AsyncCallback) {
-//	    t.callback = cb
-//	}
-//
-//	func (t *SpawnTool) Execute(ctx context.Context, args map[string]interface{}) *ToolResult {
-//	    go t.runSubagent(ctx, args)
-//	    return AsyncResult("Subagent spawned, will report back")
-//	}
-type AsyncTool interface {
+// ConcurrentTool is an optional interface for tools that can run at the same
+// time as other calls in the same turn. Tools that only read state or fetch
+// remote data qualify; tools whose effects depend on call order do not.
+type ConcurrentTool interface {
 	Tool
-	// SetCallback registers a callback function to be invoked when the async operation completes.
-	// The callback will be called from a goroutine and should handle thread-safety if needed.
-	SetCallback(cb AsyncCallback)
+	// ConcurrencySafe reports whether calls may overlap with other
+	// concurrency-safe calls.
+	ConcurrencySafe() bool
 }
 
 func ToolToSchema(tool Tool) map[string]any {
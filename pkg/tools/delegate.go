@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// DefaultDelegateTimeout is how long delegate waits for an answer when the
+	// call does not say.
+	DefaultDelegateTimeout = 5 * time.Minute
+	// maxDelegateTimeout caps the timeout a call may ask for.
+	maxDelegateTimeout = 30 * time.Minute
+)
+
+// DelegateTool hands a task to another agent and waits for its answer, which
+// becomes the tool result. Unlike spawn, the calling agent keeps its turn and
+// can use the answer straight away.
+type DelegateTool struct {
+	manager *SubagentManager
+	agentID string
+	targets map[string]string // agent ID -> display name
+}
+
+// NewDelegateTool creates the delegate tool of agentID, which may call the
+// agents in targets, keyed by ID with their display names.
+func NewDelegateTool(manager *SubagentManager, agentID string, targets map[string]string) *DelegateTool {
+	return &DelegateTool{manager: manager, agentID: agentID, targets: targets}
+}
+
+func (t *DelegateTool) Name() string {
+	return "delegate"
+}
+
+func (t *DelegateTool) Description() string {
+	ids := make([]string, 0, len(t.targets))
+	for id := range t.targets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for i, id := range ids {
+		if name := t.targets[id]; name != "" && name != id {
+			ids[i] = fmt.Sprintf("%s (%s)", id, name)
+		}
+	}
+	return "Give a task to another agent and wait for its answer, which is returned as the result. " +
+		"The agent works with its own model, tools and workspace but cannot see this conversation, " +
+		"so include everything it needs in the task. Available agents: " + strings.Join(ids, ", ") + "."
+}
+
+func (t *DelegateTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"agent_id": map[string]any{
+				"type":        "string",
+				"description": "The agent to delegate to",
+			},
+			"task": map[string]any{
+				"type":        "string",
+				"description": "The complete task for the agent",
+			},
+			"timeout_seconds": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("How long to wait for the answer (default %d)", int(DefaultDelegateTimeout.Seconds())),
+				"minimum":     1.0,
+			},
+		},
+		"required": []string{"agent_id", "task"},
+	}
+}
+
+func (t *DelegateTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	agentID, _ := args["agent_id"].(string)
+	task, _ := args["task"].(string)
+	if agentID == "" || strings.TrimSpace(task) == "" {
+		return ErrorResult("agent_id and task are required")
+	}
+	if _, ok := t.targets[agentID]; !ok {
+		return ErrorResult(fmt.Sprintf("not allowed to delegate to agent '%s'", agentID))
+	}
+	timeout := DefaultDelegateTimeout
+	if seconds, ok := args["timeout_seconds"].(float64); ok && seconds > 0 {
+		timeout = min(time.Duration(seconds*float64(time.Second)), maxDelegateTimeout)
+	}
+
+	chain := delegationChain(ctx, t.agentID)
+	for _, id := range chain {
+		if id == agentID {
+			return ErrorResult(fmt.Sprintf("agent '%s' is already working on this request (%s); do the task yourself",
+				agentID, strings.Join(append(chain, agentID), " -> ")))
+		}
+	}
+	if err := t.manager.checkDepth(ctx); err != nil {
+		return ErrorResult(fmt.Sprintf("delegation failed: %v", err)).WithError(err)
+	}
+
+	ctx, trace := withDelegation(ctx, chain, agentID)
+	call := trace.start(chain, agentID)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	channel, chatID := originOf(ctx)
+	started := time.Now()
+	loopResult, err := t.manager.run(runCtx, agentID, task, spawnedSubagentPrompt,
+		ExecutionContext{Channel: channel, ChatID: chatID}, nil)
+	elapsed := time.Since(started).Round(time.Millisecond)
+
+	switch {
+	case err != nil && errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("agent '%s' did not answer within %s", agentID, timeout)
+		trace.finish(call, elapsed, "timed out")
+	case err != nil:
+		trace.finish(call, elapsed, "failed")
+	default:
+		trace.finish(call, elapsed, "ok")
+	}
+
+	logger.InfoCF("delegate", "Delegated task finished",
+		map[string]any{
+			"from":     t.agentID,
+			"to":       agentID,
+			"chain":    strings.Join(append(chain, agentID), " -> "),
+			"duration": elapsed.String(),
+			"error":    err != nil,
+		})
+
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Delegation to '%s' failed: %v\n\n%s", agentID, err, trace.render(call))).
+			WithError(err)
+	}
+	return NewToolResult(fmt.Sprintf("Agent '%s' answered (iterations: %d):\n%s\n\n%s",
+		agentID, loopResult.Iterations, loopResult.Content, trace.render(call)))
+}
+
+// delegationKey holds the delegation a turn is running under.
+type delegationKey struct{}
+
+type delegation struct {
+	chain []string // agent IDs from the top-level agent down to the current one
+	trace *delegationTrace
+}
+
+// delegationChain returns the agents that led to self delegating, ending with
+// self.
+func delegationChain(ctx context.Context, self string) []string {
+	if d, ok := ctx.Value(delegationKey{}).(*delegation); ok {
+		return append([]string(nil), d.chain...)
+	}
+	return []string{self}
+}
+
+// withDelegation returns a context for the agent target called through chain.
+// Calls nested below it record themselves in the same trace.
+func withDelegation(ctx context.Context, chain []string, target string) (context.Context, *delegationTrace) {
+	trace := &delegationTrace{}
+	if d, ok := ctx.Value(delegationKey{}).(*delegation); ok {
+		trace = d.trace
+	}
+	next := &delegation{chain: append(append([]string(nil), chain...), target), trace: trace}
+	return context.WithValue(ctx, delegationKey{}, next), trace
+}
+
+// delegationTrace records every delegate call made while serving one
+// top-level call, in the order they started.
+type delegationTrace struct {
+	mu    sync.Mutex
+	calls []*delegateCall
+}
+
+type delegateCall struct {
+	depth   int
+	from    string
+	to      string
+	elapsed time.Duration
+	status  string
+}
+
+func (tr *delegationTrace) start(chain []string, target string) *delegateCall {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	call := &delegateCall{depth: len(chain) - 1, from: chain[len(chain)-1], to: target, status: "running"}
+	tr.calls = append(tr.calls, call)
+	return call
+}
+
+func (tr *delegationTrace) finish(call *delegateCall, elapsed time.Duration, status string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	call.elapsed, call.status = elapsed, status
+}
+
+// render lists root and the calls nested below it, indented by depth.
+func (tr *delegationTrace) render(root *delegateCall) string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var sb strings.Builder
+	sb.WriteString("Delegation trace:")
+	inside := false
+	for _, call := range tr.calls {
+		if call == root {
+			inside = true
+		} else if inside && call.depth <= root.depth {
+			break
+		}
+		if !inside {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n%s%s -> %s (%s, %s)", strings.Repeat("  ", call.depth-root.depth),
+			call.from, call.to, call.elapsed, call.status)
+	}
+	return sb.String()
+}
@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// delegatingProvider delegates its task to next once, then answers with
+// what came back.
+type delegatingProvider struct {
+	MockLLMProvider
+	next string
+}
+
+func (p *delegatingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	if last.Role == "tool" {
+		return &providers.LLMResponse{Content: "relayed: " + last.Content}, nil
+	}
+	return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+		ID:        "1",
+		Name:      "delegate",
+		Arguments: map[string]any{"agent_id": p.next, "task": "draft it"},
+	}}}, nil
+}
+
+func newDelegateTestManager(t *testing.T) *SubagentManager {
+	t.Helper()
+	manager := NewSubagentManager(&MockLLMProvider{}, "test-model", t.TempDir(), nil)
+	manager.SetMaxDepth(2)
+	manager.SetResolver(func(agentID string, depth int) (*SubagentProfile, error) {
+		profile := &SubagentProfile{Model: agentID + "-model", Tools: NewToolRegistry(), MaxIterations: 5}
+		switch agentID {
+		case "researcher":
+			profile.Provider = &delegatingProvider{next: "writer"}
+			profile.Tools.Register(NewDelegateTool(manager, "researcher", map[string]string{"writer": "", "main": ""}))
+		case "loop":
+			profile.Provider = &delegatingProvider{next: "main"}
+			profile.Tools.Register(NewDelegateTool(manager, "loop", map[string]string{"main": ""}))
+		case "slow":
+			profile.Provider = &blockingProvider{}
+		default:
+			profile.Provider = &MockLLMProvider{}
+		}
+		return profile, nil
+	})
+	return manager
+}
+
+func TestDelegateTool_ReturnsAnswerWithTrace(t *testing.T) {
+	manager := newDelegateTestManager(t)
+	tool := NewDelegateTool(manager, "main", map[string]string{"researcher": "Researcher", "writer": "", "loop": ""})
+
+	if desc := tool.Description(); !strings.Contains(desc, "loop, researcher (Researcher), writer") {
+		t.Errorf("expected the targets in the description, got %q", desc)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"agent_id": "coder", "task": "x"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not allowed") {
+		t.Errorf("expected an unlisted agent to be rejected, got %q", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"agent_id": "researcher", "task": "write a report"})
+	if result.IsError {
+		t.Fatalf("delegate failed: %s", result.ForLLM)
+	}
+	for _, want := range []string{
+		"Agent 'researcher' answered",
+		"relayed: Agent 'writer' answered (iterations: 1):\nTask completed: draft it",
+		"Delegation trace:\nmain -> researcher (",
+		"\n  researcher -> writer (",
+	} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("expected %q in:\n%s", want, result.ForLLM)
+		}
+	}
+}
+
+func TestDelegateTool_RejectsCyclesAndDepth(t *testing.T) {
+	manager := newDelegateTestManager(t)
+	tool := NewDelegateTool(manager, "main", map[string]string{"loop": "", "writer": ""})
+
+	result := tool.Execute(context.Background(), map[string]any{"agent_id": "loop", "task": "go around"})
+	if !strings.Contains(result.ForLLM, "agent 'main' is already working on this request (main -> loop -> main)") {
+		t.Errorf("expected the cycle to be refused, got:\n%s", result.ForLLM)
+	}
+
+	manager.SetMaxDepth(1)
+	ctx := context.WithValue(context.Background(), subagentDepthKey{}, 1)
+	result = tool.Execute(ctx, map[string]any{"agent_id": "writer", "task": "x"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "nested") {
+		t.Errorf("expected the depth limit to apply, got:\n%s", result.ForLLM)
+	}
+}
+
+func TestDelegateTool_TimesOut(t *testing.T) {
+	manager := newDelegateTestManager(t)
+	tool := NewDelegateTool(manager, "main", map[string]string{"slow": ""})
+
+	result := tool.Execute(context.Background(), map[string]any{"agent_id": "slow", "task": "x", "timeout_seconds": 1.0})
+	if !result.IsError || !strings.Contains(result.ForLLM, "did not answer within 1s") ||
+		!strings.Contains(result.ForLLM, "main -> slow (") || !strings.Contains(result.ForLLM, "timed out") {
+		t.Errorf("expected a timeout, got:\n%s", result.ForLLM)
+	}
+}
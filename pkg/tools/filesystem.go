@@ -1,8 +1,11 @@
 package tools
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -79,6 +82,21 @@ func isWithinWorkspace(candidate, workspace string) bool {
 	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
 }
 
+const (
+	readFileDefaultLimit = 2000
+	readFileMaxLineChars = 2000
+	// readFileMaxChars keeps a page below the registry's output limit so
+	// read_file results are paged rather than spilled to a file.
+	readFileMaxChars = 20000
+	binarySniffBytes = 8000
+)
+
+// looksBinary reports whether the start of a file contains a NUL byte, which
+// text files never do.
+func looksBinary(head []byte) bool {
+	return bytes.IndexByte(head, 0) >= 0
+}
+
 type ReadFileTool struct {
 	workspace string
 	restrict  bool
@@ -92,8 +110,13 @@ func (t *ReadFileTool) Name() string {
 	return "read_file"
 }
 
+func (t *ReadFileTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *ReadFileTool) Description() string {
-	return "Read the contents of a file"
+	return "Read a text file with line numbers. Long files are returned a page at a time; " +
+		"use offset and limit to read further or to read a specific range of lines."
 }
 
 func (t *ReadFileTool) Parameters() map[string]any {
@@ -104,6 +127,16 @@ func (t *ReadFileTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Path to the file to read",
 			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Line number to start reading from, starting at 1 (default 1)",
+				"minimum":     1.0,
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of lines to read (default %d)", readFileDefaultLimit),
+				"minimum":     1.0,
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -115,17 +148,73 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("path is required")
 	}
 
+	offset := 1
+	if v, ok := args["offset"].(float64); ok && v > 1 {
+		offset = int(v)
+	}
+	limit := readFileDefaultLimit
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		limit = int(v)
+	}
+
 	resolvedPath, err := validatePath(path, t.workspace, t.restrict)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
 
-	content, err := os.ReadFile(resolvedPath)
+	f, err := os.Open(resolvedPath)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to read file: %v", err))
 	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	head, _ := reader.Peek(binarySniffBytes)
+	if looksBinary(head) {
+		info, _ := f.Stat()
+		size := int64(0)
+		if info != nil {
+			size = info.Size()
+		}
+		return ErrorResult(fmt.Sprintf("%s appears to be a binary file (%d bytes) and cannot be read as text", path, size))
+	}
 
-	return NewToolResult(string(content))
+	var out strings.Builder
+	total, last := 0, 0
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			total++
+			if total >= offset && total < offset+limit && out.Len() < readFileMaxChars {
+				line = strings.TrimRight(line, "\r\n")
+				if len(line) > readFileMaxLineChars {
+					line = line[:readFileMaxLineChars] + "... (line truncated)"
+				}
+				fmt.Fprintf(&out, "%6d\t%s\n", total, line)
+				last = total
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to read file: %v", err))
+		}
+	}
+
+	if total == 0 {
+		return NewToolResult(fmt.Sprintf("File: %s (empty)", path))
+	}
+	if offset > total {
+		return ErrorResult(fmt.Sprintf("offset %d is past the end of %s (%d lines)", offset, path, total))
+	}
+
+	header := fmt.Sprintf("File: %s (%d lines total, showing lines %d-%d)\n", path, total, offset, last)
+	footer := ""
+	if last < total {
+		footer = fmt.Sprintf("... %d more lines. Use offset=%d to continue reading.\n", total-last, last+1)
+	}
+	return NewToolResult(header + out.String() + footer)
 }
 
 type WriteFileTool struct {
@@ -203,6 +292,10 @@ func (t *ListDirTool) Name() string {
 	return "list_dir"
 }
 
+func (t *ListDirTool) ConcurrencySafe() bool {
+	return true
+}
+
 func (t *ListDirTool) Description() string {
 	return "List files and directories in a path"
 }
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -279,3 +280,62 @@ func TestFilesystemTool_ReadFile_RejectsSymlinkEscape(t *testing.T) {
 		t.Fatalf("expected symlink escape error, got: %s", result.ForLLM)
 	}
 }
+
+func TestFilesystemTool_ReadFile_Pages(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	for i := 1; i <= 50; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	path := filepath.Join(dir, "log.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewReadFileTool(dir, true)
+	result := tool.Execute(context.Background(), map[string]any{"path": "log.txt", "offset": 10.0, "limit": 3.0})
+	want := "File: log.txt (50 lines total, showing lines 10-12)\n" +
+		"    10\tline 10\n    11\tline 11\n    12\tline 12\n" +
+		"... 38 more lines. Use offset=13 to continue reading.\n"
+	if result.ForLLM != want {
+		t.Errorf("got:\n%s\nwant:\n%s", result.ForLLM, want)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"path": "log.txt", "offset": 49.0})
+	if !strings.HasSuffix(result.ForLLM, "    50\tline 50\n") || strings.Contains(result.ForLLM, "more lines") {
+		t.Errorf("expected the last page without a continuation note, got:\n%s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"path": "log.txt", "offset": 51.0})
+	if !result.IsError {
+		t.Errorf("expected an offset past the end to fail, got:\n%s", result.ForLLM)
+	}
+}
+
+func TestFilesystemTool_ReadFile_LimitsPageSize(t *testing.T) {
+	dir := t.TempDir()
+	line := strings.Repeat("x", 1000) + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "wide.txt"), []byte(strings.Repeat(line, 100)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := NewReadFileTool(dir, true).Execute(context.Background(), map[string]any{"path": "wide.txt"})
+	if len(result.ForLLM) > readFileMaxChars+2000 {
+		t.Errorf("expected the page to stay near %d chars, got %d", readFileMaxChars, len(result.ForLLM))
+	}
+	if !strings.Contains(result.ForLLM, "more lines. Use offset=") {
+		t.Errorf("expected a continuation note, got:\n%s", result.ForLLM[len(result.ForLLM)-200:])
+	}
+}
+
+func TestFilesystemTool_ReadFile_RejectsBinary(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "blob.bin"), []byte("ELF\x00\x01\x02"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := NewReadFileTool(dir, true).Execute(context.Background(), map[string]any{"path": "blob.bin"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "binary file") {
+		t.Errorf("expected binary files to be refused, got: %s", result.ForLLM)
+	}
+}
@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
+)
+
+// mcpToolCaller is the part of *mcp.Client an MCPTool needs.
+type mcpToolCaller interface {
+	Name() string
+	CallTool(ctx context.Context, name string, args map[string]any) (*mcp.CallResult, error)
+}
+
+// MCPTool exposes one tool of an MCP server to the agent.
+type MCPTool struct {
+	client mcpToolCaller
+	tool   mcp.Tool
+	name   string
+}
+
+var invalidToolNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// maxToolNameLen is the longest function name providers accept.
+const maxToolNameLen = 64
+
+// newMCPTool wraps tool from the given server, named mcp_<server>_<tool>.
+func newMCPTool(client mcpToolCaller, tool mcp.Tool) *MCPTool {
+	t := &MCPTool{client: client, tool: tool}
+	t.name = invalidToolNameChars.ReplaceAllString(fmt.Sprintf("mcp_%s_%s", client.Name(), tool.Name), "_")
+	if len(t.name) > maxToolNameLen {
+		t.name = t.hashedName()
+	}
+	return t
+}
+
+// hashedName returns the tool's name, shortened if needed, with a hash of
+// the server and tool names appended. Names that are cut to the length
+// limit or differ only in replaced characters stay distinct this way.
+func (t *MCPTool) hashedName() string {
+	sum := sha256.Sum256([]byte(t.client.Name() + "\x00" + t.tool.Name))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	name := t.name
+	if len(name) > maxToolNameLen-len(suffix) {
+		name = name[:maxToolNameLen-len(suffix)]
+	}
+	return name + suffix
+}
+
+// RegisterMCPTool exposes tool of the given server to the agent owning
+// registry. If a different tool already holds its name, the new one gets a
+// hash suffix rather than replacing it.
+func RegisterMCPTool(registry *ToolRegistry, client *mcp.Client, tool mcp.Tool) *MCPTool {
+	return registerMCPTool(registry, client, tool)
+}
+
+func registerMCPTool(registry *ToolRegistry, client mcpToolCaller, tool mcp.Tool) *MCPTool {
+	t := newMCPTool(client, tool)
+	if registry.RegisterIfAbsent(t) {
+		return t
+	}
+	if existing, ok := registry.Get(t.name); ok && t.sameAs(existing) {
+		registry.Register(t)
+		return t
+	}
+
+	clashed := t.name
+	t.name = t.hashedName()
+	logger.WarnCF("mcp", "MCP tool name already taken, adding a suffix", map[string]any{
+		"server": client.Name(),
+		"tool":   tool.Name,
+		"taken":  clashed,
+		"name":   t.name,
+	})
+	registry.Register(t)
+	return t
+}
+
+// sameAs reports whether other wraps the same tool of the same server.
+func (t *MCPTool) sameAs(other Tool) bool {
+	o, ok := other.(*MCPTool)
+	return ok && o.client.Name() == t.client.Name() && o.tool.Name == t.tool.Name
+}
+
+func (t *MCPTool) Name() string {
+	return t.name
+}
+
+func (t *MCPTool) Description() string {
+	desc := t.tool.Description
+	if desc == "" {
+		desc = t.tool.Name
+	}
+	return fmt.Sprintf("[MCP server %s] %s", t.client.Name(), desc)
+}
+
+func (t *MCPTool) Parameters() map[string]any {
+	schema := map[string]any{}
+	for k, v := range t.tool.InputSchema {
+		schema[k] = v
+	}
+	if _, ok := schema["type"]; !ok {
+		schema["type"] = "object"
+	}
+	if _, ok := schema["properties"]; !ok {
+		schema["properties"] = map[string]any{}
+	}
+	return schema
+}
+
+func (t *MCPTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	result, err := t.client.CallTool(ctx, t.tool.Name, args)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("MCP tool %s failed: %v", t.name, err)).WithError(err)
+	}
+	text := result.Text()
+	if result.IsError {
+		return ErrorResult(text)
+	}
+	if text == "" {
+		text = "(no output)"
+	}
+	return NewToolResult(text)
+}
@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/mcp"
+)
+
+type fakeMCPClient struct {
+	name   string
+	result *mcp.CallResult
+	err    error
+	called string
+	args   map[string]any
+}
+
+func (c *fakeMCPClient) Name() string {
+	if c.name == "" {
+		return "home assistant"
+	}
+	return c.name
+}
+
+func (c *fakeMCPClient) CallTool(ctx context.Context, name string, args map[string]any) (*mcp.CallResult, error) {
+	c.called, c.args = name, args
+	return c.result, c.err
+}
+
+func TestMCPTool_NameAndSchema(t *testing.T) {
+	tool := newMCPTool(&fakeMCPClient{}, mcp.Tool{Name: "lights.toggle"})
+
+	if tool.Name() != "mcp_home_assistant_lights_toggle" {
+		t.Errorf("Name() = %q", tool.Name())
+	}
+	params := tool.Parameters()
+	if params["type"] != "object" || params["properties"] == nil {
+		t.Errorf("expected a default object schema, got %v", params)
+	}
+}
+
+func TestMCPTool_LongNamesStayDistinct(t *testing.T) {
+	client := &fakeMCPClient{}
+	prefix := strings.Repeat("very_long_tool_name_", 4)
+	a := newMCPTool(client, mcp.Tool{Name: prefix + "read"})
+	b := newMCPTool(client, mcp.Tool{Name: prefix + "write"})
+
+	if len(a.Name()) > maxToolNameLen || len(b.Name()) > maxToolNameLen {
+		t.Errorf("names exceed %d characters: %q, %q", maxToolNameLen, a.Name(), b.Name())
+	}
+	if a.Name() == b.Name() {
+		t.Errorf("truncated names collide: %q", a.Name())
+	}
+}
+
+func TestRegisterMCPTool_DisambiguatesCollisions(t *testing.T) {
+	registry := NewToolRegistry()
+	client := &fakeMCPClient{}
+
+	first := registerMCPTool(registry, client, mcp.Tool{Name: "lights.toggle"})
+	second := registerMCPTool(registry, client, mcp.Tool{Name: "lights_toggle"})
+	if first.Name() != "mcp_home_assistant_lights_toggle" {
+		t.Errorf("first tool renamed to %q", first.Name())
+	}
+	if second.Name() == first.Name() || !strings.HasPrefix(second.Name(), first.Name()+"_") {
+		t.Errorf("second tool named %q, want %q with a suffix", second.Name(), first.Name())
+	}
+	if registry.Count() != 2 {
+		t.Errorf("expected both tools registered, got %v", registry.List())
+	}
+
+	// Registering the same tool again replaces it instead of adding a copy.
+	registerMCPTool(registry, client, mcp.Tool{Name: "lights.toggle"})
+	if registry.Count() != 2 {
+		t.Errorf("re-registering added a tool: %v", registry.List())
+	}
+}
+
+func TestMCPTool_Execute(t *testing.T) {
+	client := &fakeMCPClient{result: &mcp.CallResult{Content: []mcp.Content{{Type: "text", Text: "lights on"}}}}
+	tool := newMCPTool(client, mcp.Tool{Name: "toggle"})
+
+	result := tool.Execute(context.Background(), map[string]any{"room": "kitchen"})
+	if result.IsError || result.ForLLM != "lights on" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if client.called != "toggle" || client.args["room"] != "kitchen" {
+		t.Errorf("forwarded %q %v", client.called, client.args)
+	}
+
+	client.result = &mcp.CallResult{IsError: true, Content: []mcp.Content{{Type: "text", Text: "no such room"}}}
+	if result := tool.Execute(context.Background(), nil); !result.IsError || result.ForLLM != "no such room" {
+		t.Errorf("expected tool error, got %+v", result)
+	}
+
+	client.err = errors.New("connection refused")
+	if result := tool.Execute(context.Background(), nil); !result.IsError || result.Err == nil {
+		t.Errorf("expected call error, got %+v", result)
+	}
+}
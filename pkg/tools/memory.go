@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+// MemorySearchTool retrieves relevant snippets from long-term memory,
+// daily notes and archived conversations.
+type MemorySearchTool struct {
+	index      *memory.Index
+	maxResults int
+}
+
+// NewMemorySearchTool creates a memory_search tool over index.
+// maxResults is the default number of snippets returned.
+func NewMemorySearchTool(index *memory.Index, maxResults int) *MemorySearchTool {
+	if maxResults <= 0 {
+		maxResults = 5
+	}
+	return &MemorySearchTool{index: index, maxResults: maxResults}
+}
+
+func (t *MemorySearchTool) Name() string {
+	return "memory_search"
+}
+
+func (t *MemorySearchTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *MemorySearchTool) Description() string {
+	return "Search long-term memory (MEMORY.md), daily notes and archived past conversations. Returns the most relevant snippets with their source file. Use this before answering questions about the user, earlier conversations or anything you may have saved."
+}
+
+func (t *MemorySearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "What to look for, e.g. 'user's preferred coffee' or 'router password discussion'",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of snippets to return (1-20)",
+				"minimum":     1.0,
+				"maximum":     20.0,
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return ErrorResult("query is required")
+	}
+
+	limit := t.maxResults
+	if l, ok := args["limit"].(float64); ok && l >= 1 && l <= 20 {
+		limit = int(l)
+	}
+
+	results, err := t.index.Search(ctx, query, limit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("memory search failed: %v", err)).WithError(err)
+	}
+	if len(results) == 0 {
+		return SilentResult(fmt.Sprintf("No memories found for %q.", query))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d memory snippets for %q:\n", len(results), query)
+	for i, r := range results {
+		fmt.Fprintf(&sb, "\n[%d] %s:%d (score %.2f)\n%s\n", i+1, r.Source, r.Line, r.Score, r.Snippet)
+	}
+	return SilentResult(sb.String())
+}
+
+// MemoryWriter persists notes to the agent's memory files.
+type MemoryWriter interface {
+	AppendLongTerm(content string) error
+	AppendToday(content string) error
+}
+
+// MemorySaveTool stores a note in long-term memory or today's daily note.
+type MemorySaveTool struct {
+	writer MemoryWriter
+}
+
+// NewMemorySaveTool creates a memory_save tool writing through writer.
+func NewMemorySaveTool(writer MemoryWriter) *MemorySaveTool {
+	return &MemorySaveTool{writer: writer}
+}
+
+func (t *MemorySaveTool) Name() string {
+	return "memory_save"
+}
+
+func (t *MemorySaveTool) Description() string {
+	return "Save a note to memory so it can be found later with memory_search. Use 'long_term' for durable facts and preferences about the user, 'daily' (default) for things that happened today."
+}
+
+func (t *MemorySaveTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "The note to save, written so it makes sense without the current conversation",
+			},
+			"target": map[string]any{
+				"type":        "string",
+				"description": "Where to save the note",
+				"enum":        []string{"daily", "long_term"},
+			},
+		},
+		"required": []string{"content"},
+	}
+}
+
+func (t *MemorySaveTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	content, _ := args["content"].(string)
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return ErrorResult("content is required")
+	}
+
+	target, _ := args["target"].(string)
+	var err error
+	switch target {
+	case "", "daily":
+		target = "daily"
+		err = t.writer.AppendToday(content)
+	case "long_term":
+		err = t.writer.AppendLongTerm(content)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown target %q (use 'daily' or 'long_term')", target))
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save memory: %v", err)).WithError(err)
+	}
+	return SilentResult(fmt.Sprintf("Saved to %s memory.", target))
+}
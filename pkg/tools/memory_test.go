@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+type fakeMemoryWriter struct {
+	longTerm []string
+	daily    []string
+}
+
+func (w *fakeMemoryWriter) AppendLongTerm(content string) error {
+	w.longTerm = append(w.longTerm, content)
+	return nil
+}
+
+func (w *fakeMemoryWriter) AppendToday(content string) error {
+	w.daily = append(w.daily, content)
+	return nil
+}
+
+func TestMemorySearchTool_ReturnsSnippets(t *testing.T) {
+	workspace := t.TempDir()
+	dir := filepath.Join(workspace, "memory")
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "MEMORY.md"), []byte("# Wifi\n\nGuest network is called picoguest.\n"), 0o644)
+
+	tool := NewMemorySearchTool(memory.NewIndex(workspace, "memory"), 5)
+
+	result := tool.Execute(context.Background(), map[string]any{"query": "guest wifi"})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if !result.Silent {
+		t.Error("expected search results to be silent")
+	}
+	if !strings.Contains(result.ForLLM, "picoguest") || !strings.Contains(result.ForLLM, "memory/MEMORY.md:1") {
+		t.Errorf("unexpected result: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"query": "bicycle"})
+	if result.IsError || !strings.Contains(result.ForLLM, "No memories found") {
+		t.Errorf("expected empty result, got %s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{})
+	if !result.IsError {
+		t.Error("expected error for missing query")
+	}
+}
+
+func TestMemorySaveTool_Targets(t *testing.T) {
+	writer := &fakeMemoryWriter{}
+	tool := NewMemorySaveTool(writer)
+
+	if result := tool.Execute(context.Background(), map[string]any{"content": "walked the dog"}); result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if result := tool.Execute(context.Background(), map[string]any{
+		"content": "prefers metric units",
+		"target":  "long_term",
+	}); result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if result := tool.Execute(context.Background(), map[string]any{
+		"content": "x",
+		"target":  "somewhere",
+	}); !result.IsError {
+		t.Error("expected error for unknown target")
+	}
+
+	if len(writer.daily) != 1 || writer.daily[0] != "walked the dog" {
+		t.Errorf("daily = %v", writer.daily)
+	}
+	if len(writer.longTerm) != 1 || writer.longTerm[0] != "prefers metric units" {
+		t.Errorf("longTerm = %v", writer.longTerm)
+	}
+}
@@ -8,7 +8,6 @@ import (
 
 func TestMessageTool_Execute_Success(t *testing.T) {
 	tool := NewMessageTool()
-	tool.SetContext("test-channel", "test-chat-id")
 
 	var sentChannel, sentChatID, sentContent string
 	tool.SetSendCallback(func(channel, chatID, content string) error {
@@ -18,7 +17,7 @@ func TestMessageTool_Execute_Success(t *testing.T) {
 		return nil
 	})
 
-	ctx := context.Background()
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "test-channel", ChatID: "test-chat-id"})
 	args := map[string]any{
 		"content": "Hello, world!",
 	}
@@ -60,7 +59,6 @@ func TestMessageTool_Execute_Success(t *testing.T) {
 
 func TestMessageTool_Execute_WithCustomChannel(t *testing.T) {
 	tool := NewMessageTool()
-	tool.SetContext("default-channel", "default-chat-id")
 
 	var sentChannel, sentChatID string
 	tool.SetSendCallback(func(channel, chatID, content string) error {
@@ -69,7 +67,7 @@ func TestMessageTool_Execute_WithCustomChannel(t *testing.T) {
 		return nil
 	})
 
-	ctx := context.Background()
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "default-channel", ChatID: "default-chat-id"})
 	args := map[string]any{
 		"content": "Test message",
 		"channel": "custom-channel",
@@ -94,16 +92,55 @@ func TestMessageTool_Execute_WithCustomChannel(t *testing.T) {
 	}
 }
 
+func TestMessageTool_Execute_UsesExecutionContext(t *testing.T) {
+	tool := NewMessageTool()
+
+	var sentChannel, sentChatID string
+	tool.SetSendCallback(func(channel, chatID, content string) error {
+		sentChannel = channel
+		sentChatID = chatID
+		return nil
+	})
+
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "turn-channel", ChatID: "turn-chat-id"})
+	result := tool.Execute(ctx, map[string]any{"content": "hi"})
+	if result.IsError {
+		t.Fatalf("Expected success, got error: %s", result.ForLLM)
+	}
+
+	if sentChannel != "turn-channel" || sentChatID != "turn-chat-id" {
+		t.Errorf("Expected turn-channel:turn-chat-id, got %s:%s", sentChannel, sentChatID)
+	}
+}
+
+func TestMessageTool_Execute_SentTrackerIsPerRound(t *testing.T) {
+	tool := NewMessageTool()
+	tool.SetSendCallback(func(channel, chatID, content string) error {
+		return nil
+	})
+
+	ctxA, sentA := WithSentTracker(WithExecutionContext(context.Background(), ExecutionContext{Channel: "telegram", ChatID: "a"}))
+	_, sentB := WithSentTracker(WithExecutionContext(context.Background(), ExecutionContext{Channel: "telegram", ChatID: "b"}))
+
+	tool.Execute(ctxA, map[string]any{"content": "hello"})
+
+	if !sentA() {
+		t.Error("Expected round A to report a sent message")
+	}
+	if sentB() {
+		t.Error("Expected round B to be unaffected by round A's send")
+	}
+}
+
 func TestMessageTool_Execute_SendFailure(t *testing.T) {
 	tool := NewMessageTool()
-	tool.SetContext("test-channel", "test-chat-id")
 
 	sendErr := errors.New("network error")
 	tool.SetSendCallback(func(channel, chatID, content string) error {
 		return sendErr
 	})
 
-	ctx := context.Background()
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "test-channel", ChatID: "test-chat-id"})
 	args := map[string]any{
 		"content": "Test message",
 	}
@@ -133,9 +170,8 @@ func TestMessageTool_Execute_SendFailure(t *testing.T) {
 
 func TestMessageTool_Execute_MissingContent(t *testing.T) {
 	tool := NewMessageTool()
-	tool.SetContext("test-channel", "test-chat-id")
 
-	ctx := context.Background()
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "test-channel", ChatID: "test-chat-id"})
 	args := map[string]any{} // content missing
 
 	result := tool.Execute(ctx, args)
@@ -151,7 +187,7 @@ func TestMessageTool_Execute_MissingContent(t *testing.T) {
 
 func TestMessageTool_Execute_NoTargetChannel(t *testing.T) {
 	tool := NewMessageTool()
-	// No SetContext called, so defaultChannel and defaultChatID are empty
+	// No execution context, so there is no default channel or chat ID
 
 	tool.SetSendCallback(func(channel, chatID, content string) error {
 		return nil
@@ -175,10 +211,9 @@ func TestMessageTool_Execute_NoTargetChannel(t *testing.T) {
 
 func TestMessageTool_Execute_NotConfigured(t *testing.T) {
 	tool := NewMessageTool()
-	tool.SetContext("test-channel", "test-chat-id")
 	// No SetSendCallback called
 
-	ctx := context.Background()
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "test-channel", ChatID: "test-chat-id"})
 	args := map[string]any{
 		"content": "Test message",
 	}
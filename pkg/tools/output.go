@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// DefaultMaxToolOutputChars is how much of a tool result reaches the model
+// when no limit is configured.
+const DefaultMaxToolOutputChars = 30000
+
+// spillKeepFiles is how many saved outputs are kept in a spill directory;
+// older ones are removed as new ones are written.
+const spillKeepFiles = 20
+
+// OutputLimit bounds how much of a tool result the registry passes to the
+// model. Longer results keep their beginning and end; when Dir is set the full
+// output is saved there first and the model is told how to page through it.
+type OutputLimit struct {
+	MaxChars int    // 0 uses DefaultMaxToolOutputChars
+	Dir      string // empty discards the omitted part
+}
+
+// apply returns result, or a copy with ForLLM cut down to the limit.
+func (l OutputLimit) apply(tool string, result *ToolResult) *ToolResult {
+	maxChars := l.MaxChars
+	if maxChars <= 0 {
+		maxChars = DefaultMaxToolOutputChars
+	}
+	if result == nil || result.Async || len(result.ForLLM) <= maxChars {
+		return result
+	}
+
+	output := result.ForLLM
+	lines := strings.Count(output, "\n") + 1
+	note := fmt.Sprintf("[Output too long: %d chars, %d lines. The middle was omitted; "+
+		"narrow the command or query to see it.]", len(output), lines)
+	if l.Dir != "" {
+		path, err := spillOutput(l.Dir, tool, output)
+		if err == nil {
+			note = fmt.Sprintf("[Output too long: %d chars, %d lines. Full output saved to %s; "+
+				"use read_file with offset and limit, or grep, on that file to see the omitted part.]",
+				len(output), lines, path)
+		} else {
+			logger.WarnCF("tool", "Failed to save long tool output",
+				map[string]any{
+					"tool":  tool,
+					"error": err.Error(),
+				})
+		}
+	}
+
+	budget := maxChars - len(note) - 64
+	if budget < 0 {
+		budget = 0
+	}
+	head := cutHead(output, budget*3/4)
+	tail := cutTail(output, budget-len(head))
+	omitted := len(output) - len(head) - len(tail)
+
+	limited := *result
+	limited.ForLLM = fmt.Sprintf("%s\n... [%d chars omitted] ...\n%s\n\n%s", head, omitted, tail, note)
+	return &limited
+}
+
+// cutHead returns at most n bytes from the start of s, ending at a line break
+// when one is reasonably close.
+func cutHead(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	if i := strings.LastIndexByte(s[:n], '\n'); i >= n/2 {
+		return s[:i+1]
+	}
+	return s[:n]
+}
+
+// cutTail returns at most n bytes from the end of s, starting after a line
+// break when one is reasonably close.
+func cutTail(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	start := len(s) - n
+	for start < len(s) && !utf8.RuneStart(s[start]) {
+		start++
+	}
+	if i := strings.IndexByte(s[start:], '\n'); i >= 0 && i < n/2 {
+		return s[start+i+1:]
+	}
+	return s[start:]
+}
+
+// spillOutput writes output to a new file in dir and prunes old files.
+func spillOutput(dir, tool, output string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, tool)
+	f, err := os.CreateTemp(dir, name+"-"+time.Now().Format("20060102-150405")+"-*.txt")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(output); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	pruneSpillFiles(dir, spillKeepFiles)
+	return f.Name(), nil
+}
+
+// pruneSpillFiles removes all but the newest keep files in dir.
+func pruneSpillFiles(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type spilled struct {
+		path    string
+		modTime time.Time
+	}
+	files := make([]spilled, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".txt") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, spilled{filepath.Join(dir, entry.Name()), info.ModTime()})
+		}
+	}
+	if len(files) <= keep {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime.Equal(files[j].modTime) {
+			return files[i].path > files[j].path
+		}
+		return files[i].modTime.After(files[j].modTime)
+	})
+	for _, f := range files[keep:] {
+		os.Remove(f.path)
+	}
+}
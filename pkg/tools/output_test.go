@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func longOutput(lines int) string {
+	var sb strings.Builder
+	for i := 1; i <= lines; i++ {
+		fmt.Fprintf(&sb, "log line %d\n", i)
+	}
+	return sb.String()
+}
+
+func TestOutputLimit_SpillsToFile(t *testing.T) {
+	dir := t.TempDir()
+	output := longOutput(5000)
+	tool := newMockTool("exec", "run")
+	tool.result = NewToolResult(output)
+
+	r := NewToolRegistry()
+	r.Register(tool)
+	r.SetOutputLimit(OutputLimit{MaxChars: 2000, Dir: dir})
+
+	result := r.Execute(context.Background(), "exec", map[string]any{})
+	if len(result.ForLLM) > 2000 {
+		t.Errorf("expected at most 2000 chars, got %d", len(result.ForLLM))
+	}
+	if !strings.HasPrefix(result.ForLLM, "log line 1\n") || !strings.Contains(result.ForLLM, "log line 5000\n") {
+		t.Errorf("expected the head and tail to be kept, got:\n%s", result.ForLLM)
+	}
+	if tool.result.ForLLM != output {
+		t.Error("expected the tool's own result to be left untouched")
+	}
+
+	m := regexp.MustCompile(`saved to (\S+);`).FindStringSubmatch(result.ForLLM)
+	if m == nil {
+		t.Fatalf("expected the saved path in:\n%s", result.ForLLM)
+	}
+	saved, err := os.ReadFile(m[1])
+	if err != nil || string(saved) != output {
+		t.Errorf("expected the full output in %s, err=%v", m[1], err)
+	}
+	if filepath.Dir(m[1]) != dir {
+		t.Errorf("expected the file in %s, got %s", dir, m[1])
+	}
+}
+
+func TestOutputLimit_WithoutDir(t *testing.T) {
+	short := NewToolResult("fine")
+	if got := (OutputLimit{}).apply("x", short); got != short {
+		t.Error("expected short results to pass through")
+	}
+
+	result := OutputLimit{MaxChars: 1000}.apply("x", ErrorResult(longOutput(1000)))
+	if len(result.ForLLM) > 1000 || !result.IsError {
+		t.Errorf("expected a truncated error result, got %d chars", len(result.ForLLM))
+	}
+	if !strings.Contains(result.ForLLM, "chars omitted") || strings.Contains(result.ForLLM, "saved to") {
+		t.Errorf("unexpected truncation note:\n%s", result.ForLLM)
+	}
+}
+
+func TestPruneSpillFiles(t *testing.T) {
+	dir := t.TempDir()
+	limit := OutputLimit{MaxChars: 100, Dir: dir}
+	for i := 0; i < spillKeepFiles+5; i++ {
+		limit.apply("exec", NewToolResult(longOutput(50)))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != spillKeepFiles {
+		t.Errorf("expected %d saved outputs, got %d", spillKeepFiles, len(entries))
+	}
+}
@@ -0,0 +1,609 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ApplyPatchTool applies a unified diff touching any number of files. Either
+// every hunk applies and all files are written, or nothing is changed and the
+// failing hunks are reported.
+type ApplyPatchTool struct {
+	workspace string
+	restrict  bool
+}
+
+func NewApplyPatchTool(workspace string, restrict bool) *ApplyPatchTool {
+	return &ApplyPatchTool{workspace: workspace, restrict: restrict}
+}
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "Apply a unified diff to one or more files in a single step. " +
+		"Use --- /dev/null to create a file, +++ /dev/null to delete one, and different --- and +++ paths to rename. " +
+		"Hunk line numbers are hints: hunks are located by their context lines, tolerating shifted lines and " +
+		"whitespace differences, and a bare @@ line may be used instead of a range. " +
+		"If any hunk fails, no file is changed."
+}
+
+func (t *ApplyPatchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"patch": map[string]any{
+				"type": "string",
+				"description": "The patch in unified diff format, e.g.\n" +
+					"--- a/main.go\n+++ b/main.go\n@@ -10,3 +10,3 @@\n func main() {\n-\told()\n+\tnew()\n }",
+			},
+		},
+		"required": []string{"patch"},
+	}
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	text, ok := args["patch"].(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return ErrorResult("patch is required")
+	}
+
+	patches, err := parsePatch(text)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid patch: %v", err))
+	}
+
+	tree := newPatchTree()
+	var summary, failures []string
+	for _, fp := range patches {
+		line, problems := t.applyFile(tree, fp)
+		if len(problems) > 0 {
+			failures = append(failures, problems...)
+			continue
+		}
+		summary = append(summary, line)
+	}
+
+	if len(failures) > 0 {
+		return ErrorResult(fmt.Sprintf("Patch not applied; no files were changed.\n%s\n"+
+			"Re-read the affected files and send a corrected patch.", strings.Join(failures, "\n")))
+	}
+	if err := tree.commit(); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to write patched files: %v", err))
+	}
+	return SilentResult("Patch applied:\n" + strings.Join(summary, "\n"))
+}
+
+// applyFile applies one file's section of the patch to tree and returns a
+// summary line, or the problems that stopped it.
+func (t *ApplyPatchTool) applyFile(tree *patchTree, fp *filePatch) (string, []string) {
+	var oldPath, newPath string
+	var err error
+	if fp.oldPath != "" {
+		if oldPath, err = validatePath(fp.oldPath, t.workspace, t.restrict); err != nil {
+			return "", []string{fmt.Sprintf("- %s: %v", fp.oldPath, err)}
+		}
+	}
+	if fp.newPath != "" {
+		if newPath, err = validatePath(fp.newPath, t.workspace, t.restrict); err != nil {
+			return "", []string{fmt.Sprintf("- %s: %v", fp.newPath, err)}
+		}
+	}
+
+	name := fp.displayPath()
+	content := ""
+	mode := fs.FileMode(0o644)
+	if oldPath != "" {
+		file, err := tree.get(oldPath)
+		if err != nil {
+			return "", []string{fmt.Sprintf("- %s: %v", fp.oldPath, err)}
+		}
+		if !file.exists {
+			return "", []string{fmt.Sprintf("- %s: file not found", fp.oldPath)}
+		}
+		content, mode = file.content, file.mode
+	}
+	if newPath != "" && newPath != oldPath {
+		file, err := tree.get(newPath)
+		if err != nil {
+			return "", []string{fmt.Sprintf("- %s: %v", fp.newPath, err)}
+		}
+		if file.exists {
+			return "", []string{fmt.Sprintf("- %s: file already exists", fp.newPath)}
+		}
+	}
+
+	patched, fuzzy, problems := applyHunks(name, content, fp.hunks)
+	if len(problems) > 0 {
+		return "", problems
+	}
+
+	var line string
+	switch {
+	case newPath == "":
+		if len(fp.hunks) > 0 && strings.TrimSpace(patched) != "" {
+			return "", []string{fmt.Sprintf("- %s: the patch deletes the file but does not remove all of its lines", name)}
+		}
+		tree.remove(oldPath)
+		line = "D " + fp.oldPath
+	case oldPath == "":
+		tree.set(newPath, patched, mode)
+		line = "A " + fp.newPath
+	case oldPath != newPath:
+		tree.remove(oldPath)
+		tree.set(newPath, patched, mode)
+		line = fmt.Sprintf("R %s -> %s", fp.oldPath, fp.newPath)
+	default:
+		tree.set(newPath, patched, mode)
+		line = "M " + fp.newPath
+	}
+	if len(fp.hunks) > 0 {
+		line += fmt.Sprintf(" (%d hunks", len(fp.hunks))
+		if fuzzy > 0 {
+			line += fmt.Sprintf(", %d matched ignoring whitespace", fuzzy)
+		}
+		line += ")"
+	}
+	return line, nil
+}
+
+// filePatch is the part of a patch that changes one file. An empty oldPath
+// creates the file and an empty newPath deletes it.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []*patchHunk
+}
+
+type patchHunk struct {
+	header   string
+	hasRange bool // false for a bare @@ line
+	oldStart int
+	oldCount int
+	lines    []patchLine
+	oldNoEOL bool // "\ No newline at end of file" after the old side
+	newNoEOL bool
+}
+
+type patchLine struct {
+	op    byte // ' ', '-' or '+'
+	text  string
+	blank bool // an empty patch line, read as a blank context line
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parsePatch reads a unified diff, with or without git's extended headers.
+// Text outside file sections, such as commentary or code fences, is ignored.
+func parsePatch(text string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var patches []*filePatch
+	var current *filePatch
+	var hunk *patchHunk
+	gitHeader := false // current came from a "diff --git" line and has no ---/+++ yet
+
+	endHunk := func() {
+		if hunk == nil {
+			return
+		}
+		// Blank lines at the end of a hunk are usually separators, not context.
+		for len(hunk.lines) > 0 {
+			last := hunk.lines[len(hunk.lines)-1]
+			if !last.blank {
+				break
+			}
+			hunk.lines = hunk.lines[:len(hunk.lines)-1]
+		}
+		hunk = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			endHunk()
+			oldPath, newPath := splitGitPaths(strings.TrimPrefix(line, "diff --git "))
+			current = &filePatch{oldPath: oldPath, newPath: newPath}
+			patches = append(patches, current)
+			gitHeader = true
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			endHunk()
+			oldPath := parsePatchPath(strings.TrimPrefix(line, "--- "))
+			newPath := parsePatchPath(strings.TrimPrefix(lines[i+1], "+++ "))
+			if strings.HasPrefix(oldPath, "a/") && (strings.HasPrefix(newPath, "b/") || newPath == "") ||
+				oldPath == "" && strings.HasPrefix(newPath, "b/") {
+				oldPath = strings.TrimPrefix(oldPath, "a/")
+				newPath = strings.TrimPrefix(newPath, "b/")
+			}
+			if current == nil || !gitHeader {
+				current = &filePatch{}
+				patches = append(patches, current)
+			}
+			current.oldPath, current.newPath = oldPath, newPath
+			gitHeader = false
+			i++
+		case strings.HasPrefix(line, "@@"):
+			endHunk()
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk before any --- / +++ file header", i+1)
+			}
+			hunk = &patchHunk{header: line}
+			if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+				hunk.hasRange = true
+				hunk.oldStart, _ = strconv.Atoi(m[1])
+				hunk.oldCount = 1
+				if m[2] != "" {
+					hunk.oldCount, _ = strconv.Atoi(m[2])
+				}
+			}
+			current.hunks = append(current.hunks, hunk)
+		case hunk != nil && (line == "" || line[0] == ' ' || line[0] == '-' || line[0] == '+'):
+			if line == "" {
+				// Editors and models often strip the space from blank context lines.
+				hunk.lines = append(hunk.lines, patchLine{op: ' ', blank: true})
+				continue
+			}
+			hunk.lines = append(hunk.lines, patchLine{op: line[0], text: line[1:]})
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			if len(hunk.lines) > 0 {
+				switch hunk.lines[len(hunk.lines)-1].op {
+				case '-':
+					hunk.oldNoEOL = true
+				case '+':
+					hunk.newNoEOL = true
+				default:
+					hunk.oldNoEOL, hunk.newNoEOL = true, true
+				}
+			}
+		case gitHeader && strings.HasPrefix(line, "rename from "):
+			current.oldPath = strings.TrimPrefix(line, "rename from ")
+		case gitHeader && strings.HasPrefix(line, "rename to "):
+			current.newPath = strings.TrimPrefix(line, "rename to ")
+		case gitHeader && strings.HasPrefix(line, "new file mode"):
+			current.oldPath = ""
+		case gitHeader && strings.HasPrefix(line, "deleted file mode"):
+			current.newPath = ""
+		case strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch"):
+			return nil, fmt.Errorf("line %d: binary patches are not supported", i+1)
+		default:
+			endHunk()
+		}
+	}
+	endHunk()
+
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file headers found; start each file with --- and +++ lines")
+	}
+	for _, fp := range patches {
+		if fp.oldPath == "" && fp.newPath == "" {
+			return nil, fmt.Errorf("a file section has no path")
+		}
+		for _, h := range fp.hunks {
+			if len(h.lines) == 0 {
+				return nil, fmt.Errorf("%s: hunk %q has no lines", fp.displayPath(), h.header)
+			}
+		}
+		if len(fp.hunks) == 0 && fp.oldPath != "" && fp.newPath != "" && fp.oldPath == fp.newPath {
+			return nil, fmt.Errorf("%s: no hunks", fp.displayPath())
+		}
+	}
+	return patches, nil
+}
+
+func (fp *filePatch) displayPath() string {
+	if fp.newPath != "" {
+		return fp.newPath
+	}
+	return fp.oldPath
+}
+
+// parsePatchPath reads the path from a ---/+++ line, dropping a trailing
+// timestamp and git's quoting. /dev/null becomes "".
+func parsePatchPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+	}
+	if s == "/dev/null" {
+		return ""
+	}
+	return s
+}
+
+// splitGitPaths splits the "a/old b/new" part of a diff --git line.
+func splitGitPaths(s string) (string, string) {
+	if strings.HasPrefix(s, "a/") {
+		if i := strings.Index(s, " b/"); i >= 0 {
+			return s[2:i], s[i+3:]
+		}
+	}
+	if fields := strings.Fields(s); len(fields) == 2 {
+		return fields[0], fields[1]
+	}
+	return s, s
+}
+
+// applyHunks applies hunks to content in order. It returns the new content,
+// how many hunks only matched after ignoring whitespace, and one problem per
+// hunk that could not be placed.
+func applyHunks(name, content string, hunks []*patchHunk) (string, int, []string) {
+	crlf := strings.Contains(content, "\r\n")
+	if crlf {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	}
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	var problems []string
+	fuzzy, delta, from := 0, 0, 0
+	for n, h := range hunks {
+		var old []string
+		for _, l := range h.lines {
+			if l.op != '+' {
+				old = append(old, l.text)
+			}
+		}
+
+		// The range is only a hint; without one, search on from the last hunk.
+		hint := from
+		if h.hasRange {
+			hint = h.oldStart - 1 + delta
+			if h.oldCount == 0 {
+				hint = h.oldStart + delta // an empty range inserts after oldStart
+			}
+		}
+		pos, level := findHunk(lines, old, hint, from)
+		if pos < 0 {
+			problems = append(problems, hunkProblem(name, n+1, h, old))
+			continue
+		}
+		if level > 0 {
+			fuzzy++
+		}
+
+		replacement := make([]string, 0, len(h.lines))
+		k := pos
+		for _, l := range h.lines {
+			switch l.op {
+			case ' ':
+				replacement = append(replacement, lines[k]) // keep the file's own whitespace
+				k++
+			case '-':
+				k++
+			case '+':
+				replacement = append(replacement, l.text)
+			}
+		}
+		rest := append(replacement, lines[pos+len(old):]...)
+		lines = append(lines[:pos:pos], rest...)
+		delta += len(replacement) - len(old)
+		from = pos + len(replacement)
+
+		if h.newNoEOL {
+			trailingNewline = false
+		} else if h.oldNoEOL {
+			trailingNewline = true
+		}
+	}
+	if len(problems) > 0 {
+		return "", 0, problems
+	}
+
+	out := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		out += "\n"
+	}
+	if crlf {
+		out = strings.ReplaceAll(out, "\n", "\r\n")
+	}
+	return out, fuzzy, nil
+}
+
+// findHunk finds where old occurs in lines at or after from, preferring the
+// position closest to hint. It tries an exact match first, then ignores
+// trailing whitespace, then all surrounding whitespace, and returns the
+// position and the level that matched, or -1.
+func findHunk(lines, old []string, hint, from int) (int, int) {
+	if len(old) == 0 {
+		return min(max(hint, from), len(lines)), 0
+	}
+	normalizers := []func(string) string{
+		func(s string) string { return s },
+		func(s string) string { return strings.TrimRight(s, " \t\r") },
+		strings.TrimSpace,
+	}
+	for level, normalize := range normalizers {
+		best := -1
+		for pos := from; pos+len(old) <= len(lines); pos++ {
+			if !linesMatch(lines[pos:pos+len(old)], old, normalize) {
+				continue
+			}
+			if best < 0 || abs(pos-hint) < abs(best-hint) {
+				best = pos
+			}
+		}
+		if best >= 0 {
+			return best, level
+		}
+	}
+	return -1, 0
+}
+
+func linesMatch(a, b []string, normalize func(string) string) bool {
+	for i := range b {
+		if normalize(a[i]) != normalize(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// hunkProblem describes a hunk that could not be placed, with the lines that
+// were expected so the model can compare them with the file.
+func hunkProblem(name string, n int, h *patchHunk, old []string) string {
+	const maxShown = 8
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "- %s: hunk %d (%s) does not match the file. Expected these lines:", name, n, h.header)
+	for i, line := range old {
+		if i == maxShown {
+			fmt.Fprintf(&sb, "\n    ... (%d more)", len(old)-maxShown)
+			break
+		}
+		sb.WriteString("\n    " + line)
+	}
+	return sb.String()
+}
+
+// patchTree holds the files touched by a patch in memory until every hunk
+// has been applied, then writes them all.
+type patchTree struct {
+	files map[string]*patchFile
+	order []string
+}
+
+type patchFile struct {
+	exists       bool // as the patch has left it so far
+	existed      bool // on disk before the patch
+	content      string
+	original     string
+	mode         fs.FileMode
+	changed      bool
+	originalMode fs.FileMode
+}
+
+func newPatchTree() *patchTree {
+	return &patchTree{files: make(map[string]*patchFile)}
+}
+
+func (t *patchTree) get(path string) (*patchFile, error) {
+	if f, ok := t.files[path]; ok {
+		return f, nil
+	}
+	f := &patchFile{mode: 0o644}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return nil, fmt.Errorf("is a directory")
+	case err == nil:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f.exists, f.existed = true, true
+		f.content, f.original = string(data), string(data)
+		f.mode, f.originalMode = info.Mode().Perm(), info.Mode().Perm()
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	t.files[path] = f
+	t.order = append(t.order, path)
+	return f, nil
+}
+
+func (t *patchTree) set(path, content string, mode fs.FileMode) {
+	f, _ := t.get(path)
+	f.exists, f.content, f.mode, f.changed = true, content, mode, true
+}
+
+func (t *patchTree) remove(path string) {
+	f, _ := t.get(path)
+	f.exists, f.content, f.changed = false, "", true
+}
+
+// commit writes every changed file. New contents are first written to
+// temporary files next to their targets and then renamed into place; if any
+// step fails, files already replaced or removed are restored.
+func (t *patchTree) commit() error {
+	temps := make(map[string]string)
+	cleanup := func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+	}
+	for _, path := range t.order {
+		f := t.files[path]
+		if !f.changed || !f.exists {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			cleanup()
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".patch-*")
+		if err != nil {
+			cleanup()
+			return err
+		}
+		temps[path] = tmp.Name()
+		_, err = tmp.WriteString(f.content)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), f.mode)
+		}
+		if err != nil {
+			cleanup()
+			return err
+		}
+	}
+
+	var done []string
+	rollback := func() {
+		for _, path := range done {
+			f := t.files[path]
+			if f.existed {
+				os.WriteFile(path, []byte(f.original), f.originalMode)
+			} else {
+				os.Remove(path)
+			}
+		}
+		cleanup()
+	}
+	for _, path := range t.order {
+		f := t.files[path]
+		if !f.changed {
+			continue
+		}
+		var err error
+		switch {
+		case f.exists:
+			err = os.Rename(temps[path], path)
+			if err == nil {
+				delete(temps, path)
+			}
+		case f.existed:
+			err = os.Remove(path)
+		default:
+			continue
+		}
+		if err != nil {
+			rollback()
+			return err
+		}
+		done = append(done, path)
+	}
+	return nil
+}
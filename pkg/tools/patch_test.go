@@ -0,0 +1,197 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePatchFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func readPatchFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApplyPatch_MultipleFiles(t *testing.T) {
+	dir := writePatchFiles(t, map[string]string{
+		"main.go":   "package main\n\nfunc main() {\n\told()\n}\n",
+		"old.txt":   "obsolete\n",
+		"notes.txt": "one\ntwo\n",
+	})
+	patch := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -3,3 +3,3 @@
+ func main() {
+-	old()
++	updated()
+ }
+--- /dev/null
++++ b/pkg/new.go
+@@ -0,0 +1,2 @@
++package pkg
++// added
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-obsolete
+diff --git a/notes.txt b/docs/notes.md
+similarity index 50%
+rename from notes.txt
+rename to docs/notes.md
+--- a/notes.txt
++++ b/docs/notes.md
+@@ -1,2 +1,2 @@
+ one
+-two
++three
+`
+	result := NewApplyPatchTool(dir, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("patch failed: %s", result.ForLLM)
+	}
+	want := "Patch applied:\nM main.go (1 hunks)\nA pkg/new.go (1 hunks)\nD old.txt (1 hunks)\nR notes.txt -> docs/notes.md (1 hunks)"
+	if result.ForLLM != want {
+		t.Errorf("summary = %q, want %q", result.ForLLM, want)
+	}
+
+	if got := readPatchFile(t, dir, "main.go"); got != "package main\n\nfunc main() {\n\tupdated()\n}\n" {
+		t.Errorf("main.go = %q", got)
+	}
+	if got := readPatchFile(t, dir, "pkg/new.go"); got != "package pkg\n// added\n" {
+		t.Errorf("pkg/new.go = %q", got)
+	}
+	if got := readPatchFile(t, dir, "docs/notes.md"); got != "one\nthree\n" {
+		t.Errorf("docs/notes.md = %q", got)
+	}
+	for _, gone := range []string{"old.txt", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, gone)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, err=%v", gone, err)
+		}
+	}
+}
+
+func TestApplyPatch_FuzzyMatching(t *testing.T) {
+	dir := writePatchFiles(t, map[string]string{
+		"app.py": "import os\n\n\ndef a():\n    return 1\n\n\ndef b():  \n    return 2\n",
+	})
+	// Wrong line numbers, a bare @@ and context with lost trailing whitespace.
+	patch := `--- app.py
++++ app.py
+@@ -1,2 +1,2 @@
+ def a():
+-    return 1
++    return 10
+@@
+ def b():
+-    return 2
++    return 20
+`
+	result := NewApplyPatchTool(dir, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("patch failed: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "1 matched ignoring whitespace") {
+		t.Errorf("expected the fuzzy hunk to be reported, got: %s", result.ForLLM)
+	}
+	want := "import os\n\n\ndef a():\n    return 10\n\n\ndef b():  \n    return 20\n"
+	if got := readPatchFile(t, dir, "app.py"); got != want {
+		t.Errorf("app.py = %q, want %q", got, want)
+	}
+}
+
+func TestApplyPatch_FailureChangesNothing(t *testing.T) {
+	files := map[string]string{
+		"a.txt": "alpha\nbeta\n",
+		"b.txt": "gamma\n",
+	}
+	dir := writePatchFiles(t, files)
+	patch := `--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ alpha
+-beta
++BETA
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-delta
++DELTA
+--- /dev/null
++++ b/c.txt
+@@ -0,0 +1 @@
++new
+`
+	result := NewApplyPatchTool(dir, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if !result.IsError {
+		t.Fatalf("expected the patch to fail, got: %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "b.txt: hunk 1 (@@ -1 +1 @@) does not match the file. Expected these lines:\n    delta") {
+		t.Errorf("expected the failing hunk to be described, got: %s", result.ForLLM)
+	}
+	for name, content := range files {
+		if got := readPatchFile(t, dir, name); got != content {
+			t.Errorf("%s changed to %q", name, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected c.txt not to be created, err=%v", err)
+	}
+}
+
+func TestApplyPatch_NoNewlineAtEOF(t *testing.T) {
+	dir := writePatchFiles(t, map[string]string{"v.txt": "1.0\n"})
+	patch := "--- a/v.txt\n+++ b/v.txt\n@@ -1 +1 @@\n-1.0\n+1.1\n\\ No newline at end of file\n"
+	result := NewApplyPatchTool(dir, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if result.IsError {
+		t.Fatalf("patch failed: %s", result.ForLLM)
+	}
+	if got := readPatchFile(t, dir, "v.txt"); got != "1.1" {
+		t.Errorf("v.txt = %q", got)
+	}
+}
+
+func TestApplyPatch_RestrictsToWorkspace(t *testing.T) {
+	dir := writePatchFiles(t, map[string]string{"a.txt": "a\n"})
+	patch := "--- /dev/null\n+++ ../escape.txt\n@@ -0,0 +1 @@\n+x\n"
+	result := NewApplyPatchTool(dir, true).Execute(context.Background(), map[string]any{"patch": patch})
+	if !result.IsError || !strings.Contains(result.ForLLM, "outside the workspace") {
+		t.Errorf("expected the path to be denied, got: %s", result.ForLLM)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected no file outside the workspace, err=%v", err)
+	}
+}
+
+func TestParsePatch_Errors(t *testing.T) {
+	for _, patch := range []string{
+		"just some text",
+		"@@ -1 +1 @@\n-a\n+b\n",
+		"--- a/x\n+++ b/x\n",
+		"diff --git a/img.png b/img.png\nBinary files a/img.png and b/img.png differ\n",
+	} {
+		if _, err := parsePatch(patch); err == nil {
+			t.Errorf("expected an error for %q", patch)
+		}
+	}
+}
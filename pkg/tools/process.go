@@ -0,0 +1,445 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxRunningProcesses = 8
+	maxTrackedProcesses = 16
+	processOutputBuffer = 64 * 1024
+	processPollMaxBytes = 10000
+	processMaxWait      = 60 * time.Second
+)
+
+// ProcessManager keeps track of the background commands started by one
+// agent's exec tool so the process tool can read their output, feed their
+// stdin and stop them. Finished processes stay listed until they are evicted
+// to make room for new ones.
+type ProcessManager struct {
+	mu        sync.Mutex
+	processes map[string]*BackgroundProcess
+	nextID    int
+}
+
+// BackgroundProcess is a command running detached from the turn that
+// started it. Only the newest processOutputBuffer bytes of each stream are
+// kept.
+type BackgroundProcess struct {
+	ID        string
+	Command   string
+	Dir       string
+	StartedAt time.Time
+
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stdin  io.WriteCloser
+	stdout *ringBuffer
+	stderr *ringBuffer
+	done   chan struct{}
+
+	mu        sync.Mutex
+	waitErr   error
+	killed    bool
+	stdoutPos int64
+	stderrPos int64
+}
+
+func NewProcessManager() *ProcessManager {
+	return &ProcessManager{processes: make(map[string]*BackgroundProcess)}
+}
+
+// Start runs cmd in the background. cancel is called once the process has
+// exited or been killed, releasing whatever context cmd was created with.
+func (m *ProcessManager) Start(cmd *exec.Cmd, cancel context.CancelFunc, command string) (*BackgroundProcess, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.makeRoomLocked(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	p := &BackgroundProcess{
+		Command:   command,
+		Dir:       cmd.Dir,
+		StartedAt: time.Now(),
+		cmd:       cmd,
+		cancel:    cancel,
+		stdin:     stdin,
+		stdout:    newRingBuffer(processOutputBuffer),
+		stderr:    newRingBuffer(processOutputBuffer),
+		done:      make(chan struct{}),
+	}
+	cmd.Stdout = p.stdout
+	cmd.Stderr = p.stderr
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	m.nextID++
+	p.ID = fmt.Sprintf("p%d", m.nextID)
+	m.processes[p.ID] = p
+
+	go func() {
+		err := cmd.Wait()
+		p.mu.Lock()
+		p.waitErr = err
+		p.mu.Unlock()
+		cancel()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// makeRoomLocked evicts the oldest finished processes once too many are
+// tracked and refuses to start more than maxRunningProcesses at once.
+func (m *ProcessManager) makeRoomLocked() error {
+	running := 0
+	var finished []*BackgroundProcess
+	for _, p := range m.processes {
+		if p.Running() {
+			running++
+		} else {
+			finished = append(finished, p)
+		}
+	}
+	if running >= maxRunningProcesses {
+		return fmt.Errorf("too many background processes running (%d); kill one first", running)
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].StartedAt.Before(finished[j].StartedAt) })
+	for i := 0; len(m.processes) >= maxTrackedProcesses && i < len(finished); i++ {
+		delete(m.processes, finished[i].ID)
+	}
+	return nil
+}
+
+// Get returns the process with the given id.
+func (m *ProcessManager) Get(id string) (*BackgroundProcess, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.processes[id]
+	return p, ok
+}
+
+// List returns all tracked processes, oldest first.
+func (m *ProcessManager) List() []*BackgroundProcess {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*BackgroundProcess, 0, len(m.processes))
+	for _, p := range m.processes {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+	return list
+}
+
+// Shutdown kills every running process. It is called when the agent stops so
+// no process trees outlive it.
+func (m *ProcessManager) Shutdown() {
+	var wg sync.WaitGroup
+	for _, p := range m.List() {
+		if !p.Running() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Kill()
+		}()
+	}
+	wg.Wait()
+}
+
+// Running reports whether the process has not exited yet.
+func (p *BackgroundProcess) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Kill terminates the process tree and waits briefly for it to exit.
+func (p *BackgroundProcess) Kill() {
+	if !p.Running() {
+		return
+	}
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+
+	_ = terminateProcessTree(p.cmd)
+	select {
+	case <-p.done:
+	case <-time.After(2 * time.Second):
+		if p.cmd.Process != nil {
+			_ = p.cmd.Process.Kill()
+		}
+		p.cancel()
+	}
+}
+
+// Write sends data to the process's stdin, closing it afterwards if eof is set.
+func (p *BackgroundProcess) Write(data string, eof bool) error {
+	if !p.Running() {
+		return errors.New("process has exited")
+	}
+	if data != "" {
+		if _, err := io.WriteString(p.stdin, data); err != nil {
+			return err
+		}
+	}
+	if eof {
+		return p.stdin.Close()
+	}
+	return nil
+}
+
+// Wait blocks until the process has new output or exits, for at most d.
+func (p *BackgroundProcess) Wait(ctx context.Context, d time.Duration) {
+	p.mu.Lock()
+	stdoutPos, stderrPos := p.stdoutPos, p.stderrPos
+	p.mu.Unlock()
+
+	deadline := time.NewTimer(d)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for p.stdout.Total() == stdoutPos && p.stderr.Total() == stderrPos {
+		select {
+		case <-p.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status describes whether the process is running or how it ended.
+func (p *BackgroundProcess) Status() string {
+	if p.Running() {
+		return fmt.Sprintf("running for %s", time.Since(p.StartedAt).Round(time.Second))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.killed:
+		return "killed"
+	case p.waitErr == nil:
+		return "exited with code 0"
+	case p.cmd.ProcessState != nil && p.cmd.ProcessState.ExitCode() >= 0:
+		return fmt.Sprintf("exited with code %d", p.cmd.ProcessState.ExitCode())
+	default:
+		return fmt.Sprintf("exited: %v", p.waitErr)
+	}
+}
+
+// ReadNew returns the output written since the previous call, at most
+// processPollMaxBytes of it, and notes any output lost to the buffer limit.
+func (p *BackgroundProcess) ReadNew() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var sb strings.Builder
+	budget := processPollMaxBytes
+	for _, stream := range []struct {
+		name string
+		buf  *ringBuffer
+		pos  *int64
+	}{
+		{"STDOUT", p.stdout, &p.stdoutPos},
+		{"STDERR", p.stderr, &p.stderrPos},
+	} {
+		data, next, dropped := stream.buf.ReadFrom(*stream.pos, budget)
+		if len(data) == 0 && dropped == 0 {
+			continue
+		}
+		*stream.pos = next
+		budget -= len(data)
+		fmt.Fprintf(&sb, "%s:\n", stream.name)
+		if dropped > 0 {
+			fmt.Fprintf(&sb, "... (%d earlier bytes dropped)\n", dropped)
+		}
+		sb.Write(data)
+		if !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteByte('\n')
+		}
+		if pending := stream.buf.Total() - next; pending > 0 {
+			fmt.Fprintf(&sb, "... (%d more bytes, poll again)\n", pending)
+		}
+	}
+	return sb.String()
+}
+
+// ringBuffer is an io.Writer that keeps the newest size bytes written to it
+// and counts how many were written in total.
+type ringBuffer struct {
+	mu    sync.Mutex
+	size  int
+	data  []byte
+	total int64
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size}
+}
+
+func (r *ringBuffer) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total += int64(len(b))
+	if len(b) >= r.size {
+		r.data = append(r.data[:0], b[len(b)-r.size:]...)
+		return len(b), nil
+	}
+	if over := len(r.data) + len(b) - r.size; over > 0 {
+		r.data = append(r.data[:0], r.data[over:]...)
+	}
+	r.data = append(r.data, b...)
+	return len(b), nil
+}
+
+// Total is the number of bytes ever written.
+func (r *ringBuffer) Total() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+// ReadFrom returns up to limit bytes starting at absolute offset, the offset
+// to continue from, and how many bytes after offset were already discarded.
+func (r *ringBuffer) ReadFrom(offset int64, limit int) ([]byte, int64, int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := r.total - int64(len(r.data))
+	var dropped int64
+	if offset < start {
+		dropped = start - offset
+		offset = start
+	}
+	data := r.data[offset-start:]
+	if len(data) > limit {
+		data = data[:limit]
+	}
+	return append([]byte(nil), data...), offset + int64(len(data)), dropped
+}
+
+// ProcessTool lets the agent inspect and control the background processes
+// started with exec's background option.
+type ProcessTool struct {
+	manager *ProcessManager
+}
+
+func NewProcessTool(manager *ProcessManager) *ProcessTool {
+	return &ProcessTool{manager: manager}
+}
+
+func (t *ProcessTool) Name() string {
+	return "process"
+}
+
+func (t *ProcessTool) Description() string {
+	return "Manage background processes started with exec's background option: list them, poll one for new output and status, write to its stdin, or kill it."
+}
+
+func (t *ProcessTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "poll", "write", "kill"},
+				"description": "What to do",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "Process ID returned by exec (required except for list)",
+			},
+			"wait_seconds": map[string]any{
+				"type":        "integer",
+				"description": "For poll: wait up to this long for new output or exit (max 60)",
+			},
+			"data": map[string]any{
+				"type":        "string",
+				"description": "For write: text to send to stdin, including any trailing newline",
+			},
+			"eof": map[string]any{
+				"type":        "boolean",
+				"description": "For write: close stdin after writing",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ProcessTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	if action == "list" {
+		return t.list()
+	}
+
+	id, _ := args["id"].(string)
+	if id == "" {
+		return ErrorResult("id is required")
+	}
+	proc, ok := t.manager.Get(id)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("no background process %q", id))
+	}
+
+	switch action {
+	case "poll":
+		if wait, ok := args["wait_seconds"].(float64); ok && wait > 0 {
+			proc.Wait(ctx, min(time.Duration(wait*float64(time.Second)), processMaxWait))
+		}
+		output := proc.ReadNew()
+		if output == "" {
+			output = "(no new output)\n"
+		}
+		return SilentResult(fmt.Sprintf("Process %s: %s\n%s", proc.ID, proc.Status(), output))
+	case "write":
+		data, _ := args["data"].(string)
+		eof, _ := args["eof"].(bool)
+		if err := proc.Write(data, eof); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to write to process %s: %v", proc.ID, err)).WithError(err)
+		}
+		return SilentResult(fmt.Sprintf("Wrote %d bytes to process %s", len(data), proc.ID))
+	case "kill":
+		proc.Kill()
+		return SilentResult(fmt.Sprintf("Process %s: %s", proc.ID, proc.Status()))
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q", action))
+	}
+}
+
+func (t *ProcessTool) list() *ToolResult {
+	procs := t.manager.List()
+	if len(procs) == 0 {
+		return SilentResult("No background processes")
+	}
+	var sb strings.Builder
+	for _, proc := range procs {
+		fmt.Fprintf(&sb, "%s [%s] %s\n", proc.ID, proc.Status(), proc.Command)
+	}
+	return SilentResult(sb.String())
+}
@@ -0,0 +1,136 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startBackground(t *testing.T, exec *ExecTool, command string) string {
+	t.Helper()
+	result := exec.Execute(context.Background(), map[string]any{"command": command, "background": true})
+	if result.IsError {
+		t.Fatalf("failed to start background command: %s", result.ForLLM)
+	}
+	id := regexp.MustCompile(`process (p\d+)`).FindStringSubmatch(result.ForLLM)
+	if id == nil {
+		t.Fatalf("expected a process id, got: %s", result.ForLLM)
+	}
+	return id[1]
+}
+
+func TestProcessTool_PollWriteAndExit(t *testing.T) {
+	manager := NewProcessManager()
+	defer manager.Shutdown()
+	exec := NewExecTool(t.TempDir(), false)
+	exec.SetProcessManager(manager)
+	tool := NewProcessTool(manager)
+
+	id := startBackground(t, exec, `echo ready; read line; echo "got $line"; echo oops >&2; exit 3`)
+
+	poll := func() string {
+		result := tool.Execute(context.Background(), map[string]any{"action": "poll", "id": id, "wait_seconds": 5.0})
+		if result.IsError {
+			t.Fatalf("poll failed: %s", result.ForLLM)
+		}
+		return result.ForLLM
+	}
+
+	if out := poll(); !strings.Contains(out, "running") || !strings.Contains(out, "ready") {
+		t.Fatalf("expected the process to be running and ready, got: %s", out)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "write", "id": id, "data": "hello\n"})
+	if result.IsError {
+		t.Fatalf("write failed: %s", result.ForLLM)
+	}
+
+	var out string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		out += poll()
+		if strings.Contains(out, "exited") {
+			break
+		}
+	}
+	if !strings.Contains(out, "got hello") || !strings.Contains(out, "STDERR:\noops") {
+		t.Errorf("expected new stdout and stderr, got: %s", out)
+	}
+	if strings.Contains(out, "ready") {
+		t.Errorf("expected poll to return only new output, got: %s", out)
+	}
+	if !strings.Contains(out, "exited with code 3") {
+		t.Errorf("expected the exit code, got: %s", out)
+	}
+
+	list := tool.Execute(context.Background(), map[string]any{"action": "list"})
+	if !strings.Contains(list.ForLLM, id+" [exited with code 3]") {
+		t.Errorf("expected the process in the list, got: %s", list.ForLLM)
+	}
+}
+
+func TestProcessManager_KillAndShutdownStopProcessTree(t *testing.T) {
+	manager := NewProcessManager()
+	dir := t.TempDir()
+	exec := NewExecTool(dir, false)
+	exec.SetProcessManager(manager)
+	tool := NewProcessTool(manager)
+
+	killed := startBackground(t, exec, "sleep 60 & echo $! > killed.pid; wait")
+	orphaned := startBackground(t, exec, "sleep 60 & echo $! > orphaned.pid; wait")
+
+	childPID := func(name string) int {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+			if data, err := os.ReadFile(path); err == nil && strings.HasSuffix(string(data), "\n") {
+				pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+				return pid
+			}
+		}
+		t.Fatalf("child pid file %s was not written", name)
+		return 0
+	}
+	killedPID, orphanedPID := childPID("killed.pid"), childPID("orphaned.pid")
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "kill", "id": killed})
+	if !strings.Contains(result.ForLLM, "killed") {
+		t.Errorf("expected the process to be killed, got: %s", result.ForLLM)
+	}
+
+	manager.Shutdown()
+	if p, _ := manager.Get(orphaned); p.Running() {
+		t.Error("expected Shutdown to stop every process")
+	}
+
+	for _, pid := range []int{killedPID, orphanedPID} {
+		deadline := time.Now().Add(2 * time.Second)
+		for processExists(pid) && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if processExists(pid) {
+			t.Errorf("child process %d is still running", pid)
+		}
+	}
+}
+
+func TestRingBuffer_KeepsNewestBytes(t *testing.T) {
+	buf := newRingBuffer(8)
+	buf.Write([]byte("hello "))
+	buf.Write([]byte("world"))
+
+	data, next, dropped := buf.ReadFrom(0, 100)
+	if string(data) != "lo world" || next != 11 || dropped != 3 {
+		t.Errorf("ReadFrom(0) = %q, %d, %d", data, next, dropped)
+	}
+	data, next, _ = buf.ReadFrom(6, 3)
+	if string(data) != "wor" || next != 9 {
+		t.Errorf("ReadFrom(6, 3) = %q, %d", data, next)
+	}
+}
@@ -11,8 +11,10 @@ import (
 )
 
 type ToolRegistry struct {
-	tools map[string]Tool
-	mu    sync.RWMutex
+	tools    map[string]Tool
+	approval *ApprovalPolicy
+	output   OutputLimit
+	mu       sync.RWMutex
 }
 
 func NewToolRegistry() *ToolRegistry {
@@ -27,6 +29,51 @@ func (r *ToolRegistry) Register(tool Tool) {
 	r.tools[tool.Name()] = tool
 }
 
+// RegisterIfAbsent registers tool unless a tool with its name is already
+// registered, and reports whether it did.
+func (r *ToolRegistry) RegisterIfAbsent(tool Tool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[tool.Name()]; exists {
+		return false
+	}
+	r.tools[tool.Name()] = tool
+	return true
+}
+
+// SetApprovalPolicy makes tool calls go through policy before they run.
+func (r *ToolRegistry) SetApprovalPolicy(policy *ApprovalPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.approval = policy
+}
+
+// SetOutputLimit changes how long tool results are cut down before they
+// reach the model.
+func (r *ToolRegistry) SetOutputLimit(limit OutputLimit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.output = limit
+}
+
+// Filter returns a registry holding the tools keep accepts, with the same
+// approval policy and output limit.
+func (r *ToolRegistry) Filter(keep func(name string) bool) *ToolRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	filtered := &ToolRegistry{
+		tools:    make(map[string]Tool),
+		approval: r.approval,
+		output:   r.output,
+	}
+	for name, tool := range r.tools {
+		if keep(name) {
+			filtered.tools[name] = tool
+		}
+	}
+	return filtered
+}
+
 func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -35,17 +82,23 @@ func (r *ToolRegistry) Get(name string) (Tool, bool) {
 }
 
 func (r *ToolRegistry) Execute(ctx context.Context, name string, args map[string]any) *ToolResult {
-	return r.ExecuteWithContext(ctx, name, args, "", "", nil)
+	return r.ExecuteWithContext(ctx, name, args, GetExecutionContext(ctx), nil)
 }
 
-// ExecuteWithContext executes a tool with channel/chatID context and optional async callback.
-// If the tool implements AsyncTool and a non-nil callback is provided,
-// the callback will be set on the tool before execution.
+// ExecuteWithContext executes a tool within the given execution context and optional async callback.
+// The execution context is attached to ctx so tools can read it via GetExecutionContext.
+// A non-nil asyncCallback becomes the execution context's Callback, where
+// async tools find it, so tools stay free of per-turn state.
+// Arguments are checked and converted against the tool's Parameters() schema
+// first; invalid ones are reported back without running the tool.
+// With an approval policy set, calls that need approval block until a human
+// answers; rejected calls return the policy's result without running.
+// Results longer than the registry's OutputLimit are cut down afterwards.
 func (r *ToolRegistry) ExecuteWithContext(
 	ctx context.Context,
 	name string,
 	args map[string]any,
-	channel, chatID string,
+	execCtx ExecutionContext,
 	asyncCallback AsyncCallback,
 ) *ToolResult {
 	logger.InfoCF("tool", "Tool execution started",
@@ -63,24 +116,44 @@ func (r *ToolRegistry) ExecuteWithContext(
 		return ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found"))
 	}
 
-	// If tool implements ContextualTool, set context
-	if contextualTool, ok := tool.(ContextualTool); ok && channel != "" && chatID != "" {
-		contextualTool.SetContext(channel, chatID)
-	}
-
-	// If tool implements AsyncTool and callback is provided, set callback
-	if asyncTool, ok := tool.(AsyncTool); ok && asyncCallback != nil {
-		asyncTool.SetCallback(asyncCallback)
-		logger.DebugCF("tool", "Async callback injected",
+	args, problems := validateArgs(tool.Parameters(), args)
+	if len(problems) > 0 {
+		logger.WarnCF("tool", "Invalid tool arguments",
 			map[string]any{
-				"tool": name,
+				"tool":     name,
+				"problems": problems,
 			})
+		return ErrorResult(formatArgProblems(name, problems)).WithError(fmt.Errorf("invalid arguments"))
+	}
+
+	if asyncCallback != nil {
+		execCtx.Callback = asyncCallback
+	}
+	ctx = WithExecutionContext(ctx, execCtx)
+
+	r.mu.RLock()
+	approval := r.approval
+	r.mu.RUnlock()
+	if approval != nil {
+		if result := approval.Check(ctx, name, args, execCtx); result != nil {
+			logger.WarnCF("tool", "Tool call not approved",
+				map[string]any{
+					"tool":   name,
+					"reason": result.ForLLM,
+				})
+			return result
+		}
 	}
 
 	start := time.Now()
 	result := tool.Execute(ctx, args)
 	duration := time.Since(start)
 
+	r.mu.RLock()
+	output := r.output
+	r.mu.RUnlock()
+	result = output.apply(name, result)
+
 	// Log based on result type
 	if result.IsError {
 		logger.ErrorCF("tool", "Tool execution failed",
@@ -107,6 +180,71 @@ func (r *ToolRegistry) ExecuteWithContext(
 	return result
 }
 
+// DefaultMaxParallelToolCalls bounds how many tool calls ExecuteToolCalls runs
+// at once when the caller does not choose a limit.
+const DefaultMaxParallelToolCalls = 4
+
+// IsConcurrencySafe reports whether the named tool may run alongside others.
+func (r *ToolRegistry) IsConcurrencySafe(name string) bool {
+	tool, ok := r.Get(name)
+	if !ok {
+		return false
+	}
+	concurrent, ok := tool.(ConcurrentTool)
+	return ok && concurrent.ConcurrencySafe()
+}
+
+// ExecuteToolCalls runs execute for every call and returns the results in call
+// order. Consecutive calls to concurrency-safe tools run together on up to
+// maxParallel goroutines; any other call runs alone, after the calls before it
+// have finished and before the ones after it start.
+func (r *ToolRegistry) ExecuteToolCalls(
+	calls []providers.ToolCall,
+	maxParallel int,
+	execute func(tc providers.ToolCall) *ToolResult,
+) []*ToolResult {
+	if maxParallel <= 0 {
+		maxParallel = DefaultMaxParallelToolCalls
+	}
+
+	results := make([]*ToolResult, len(calls))
+	for start := 0; start < len(calls); {
+		end := start + 1
+		if maxParallel > 1 && r.IsConcurrencySafe(calls[start].Name) {
+			for end < len(calls) && r.IsConcurrencySafe(calls[end].Name) {
+				end++
+			}
+		}
+		if end-start == 1 {
+			results[start] = execute(calls[start])
+			start = end
+			continue
+		}
+
+		logger.DebugCF("tool", "Running tool calls in parallel",
+			map[string]any{
+				"count":   end - start,
+				"workers": min(maxParallel, end-start),
+			})
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxParallel)
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				results[i] = execute(calls[i])
+			}()
+		}
+		wg.Wait()
+		start = end
+	}
+	return results
+}
+
 func (r *ToolRegistry) GetDefinitions() []map[string]any {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -4,7 +4,9 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
@@ -27,13 +29,12 @@ func (m *mockRegistryTool) Execute(_ context.Context, _ map[string]any) *ToolRes
 
 type mockCtxTool struct {
 	mockRegistryTool
-	channel string
-	chatID  string
+	execCtx ExecutionContext
 }
 
-func (m *mockCtxTool) SetContext(channel, chatID string) {
-	m.channel = channel
-	m.chatID = chatID
+func (m *mockCtxTool) Execute(ctx context.Context, _ map[string]any) *ToolResult {
+	m.execCtx = GetExecutionContext(ctx)
+	return m.result
 }
 
 type mockAsyncRegistryTool struct {
@@ -41,10 +42,17 @@ type mockAsyncRegistryTool struct {
 	cb AsyncCallback
 }
 
-func (m *mockAsyncRegistryTool) SetCallback(cb AsyncCallback) {
-	m.cb = cb
+func (m *mockAsyncRegistryTool) Execute(ctx context.Context, _ map[string]any) *ToolResult {
+	m.cb = GetExecutionContext(ctx).Callback
+	return m.result
+}
+
+type mockConcurrentTool struct {
+	mockRegistryTool
 }
 
+func (m *mockConcurrentTool) ConcurrencySafe() bool { return true }
+
 // --- helpers ---
 
 func newMockTool(name, desc string) *mockRegistryTool {
@@ -136,34 +144,48 @@ func TestToolRegistry_Execute_NotFound(t *testing.T) {
 	}
 }
 
-func TestToolRegistry_ExecuteWithContext_ContextualTool(t *testing.T) {
+func TestToolRegistry_ExecuteWithContext_PassesExecutionContext(t *testing.T) {
 	r := NewToolRegistry()
 	ct := &mockCtxTool{
 		mockRegistryTool: *newMockTool("ctx_tool", "needs context"),
 	}
 	r.Register(ct)
 
-	r.ExecuteWithContext(context.Background(), "ctx_tool", nil, "telegram", "chat-42", nil)
+	r.ExecuteWithContext(context.Background(), "ctx_tool", nil, ExecutionContext{
+		Channel:    "telegram",
+		ChatID:     "chat-42",
+		SenderID:   "user-7",
+		SessionKey: "agent:main:main",
+		AgentID:    "main",
+		Metadata:   map[string]string{"peer_kind": "direct"},
+	}, nil)
 
-	if ct.channel != "telegram" {
-		t.Errorf("expected channel 'telegram', got %q", ct.channel)
+	if ct.execCtx.Channel != "telegram" {
+		t.Errorf("expected channel 'telegram', got %q", ct.execCtx.Channel)
+	}
+	if ct.execCtx.ChatID != "chat-42" {
+		t.Errorf("expected chatID 'chat-42', got %q", ct.execCtx.ChatID)
+	}
+	if ct.execCtx.SenderID != "user-7" || ct.execCtx.AgentID != "main" || ct.execCtx.SessionKey != "agent:main:main" {
+		t.Errorf("unexpected execution context: %+v", ct.execCtx)
 	}
-	if ct.chatID != "chat-42" {
-		t.Errorf("expected chatID 'chat-42', got %q", ct.chatID)
+	if ct.execCtx.Metadata["peer_kind"] != "direct" {
+		t.Errorf("expected metadata to be passed through, got %v", ct.execCtx.Metadata)
 	}
 }
 
-func TestToolRegistry_ExecuteWithContext_SkipsEmptyContext(t *testing.T) {
+func TestToolRegistry_Execute_InheritsExecutionContext(t *testing.T) {
 	r := NewToolRegistry()
 	ct := &mockCtxTool{
 		mockRegistryTool: *newMockTool("ctx_tool", "needs context"),
 	}
 	r.Register(ct)
 
-	r.ExecuteWithContext(context.Background(), "ctx_tool", nil, "", "", nil)
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "slack", ChatID: "C1"})
+	r.Execute(ctx, "ctx_tool", nil)
 
-	if ct.channel != "" || ct.chatID != "" {
-		t.Error("SetContext should not be called with empty channel/chatID")
+	if ct.execCtx.Channel != "slack" || ct.execCtx.ChatID != "C1" {
+		t.Errorf("expected inherited slack:C1, got %s:%s", ct.execCtx.Channel, ct.execCtx.ChatID)
 	}
 }
 
@@ -178,9 +200,9 @@ func TestToolRegistry_ExecuteWithContext_AsyncCallback(t *testing.T) {
 	called := false
 	cb := func(_ context.Context, _ *ToolResult) { called = true }
 
-	result := r.ExecuteWithContext(context.Background(), "async_tool", nil, "", "", cb)
+	result := r.ExecuteWithContext(context.Background(), "async_tool", nil, ExecutionContext{}, cb)
 	if at.cb == nil {
-		t.Error("expected SetCallback to have been called")
+		t.Error("expected the callback in the execution context")
 	}
 	if !result.Async {
 		t.Error("expected async result")
@@ -348,3 +370,65 @@ func TestToolRegistry_ConcurrentAccess(t *testing.T) {
 		t.Error("expected tools to be registered after concurrent access")
 	}
 }
+
+func TestToolRegistry_ExecuteToolCalls_ParallelKeepsOrder(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(&mockConcurrentTool{*newMockTool("fetch", "safe")})
+	r.Register(newMockTool("write", "unsafe"))
+
+	calls := []providers.ToolCall{
+		{ID: "1", Name: "fetch"}, {ID: "2", Name: "fetch"}, {ID: "3", Name: "fetch"},
+		{ID: "4", Name: "write"},
+		{ID: "5", Name: "fetch"}, {ID: "6", Name: "fetch"},
+	}
+
+	var running, peak, unsafeOverlap atomic.Int32
+	results := r.ExecuteToolCalls(calls, 2, func(tc providers.ToolCall) *ToolResult {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		if tc.Name == "write" && n > 1 {
+			unsafeOverlap.Store(1)
+		}
+		// Later calls finish first, so ordering cannot come from timing.
+		id := tc.ID[0] - '0'
+		time.Sleep(time.Duration(7-id) * 10 * time.Millisecond)
+		return SilentResult(tc.ID)
+	})
+
+	for i, result := range results {
+		if result.ForLLM != calls[i].ID {
+			t.Errorf("result %d = %q, want %q", i, result.ForLLM, calls[i].ID)
+		}
+	}
+	if peak.Load() != 2 {
+		t.Errorf("expected 2 calls to run at once, got %d", peak.Load())
+	}
+	if unsafeOverlap.Load() != 0 {
+		t.Error("expected the unsafe call to run alone")
+	}
+}
+
+func TestToolRegistry_ExecuteToolCalls_SequentialWithLimitOne(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(&mockConcurrentTool{*newMockTool("fetch", "safe")})
+
+	var running, peak atomic.Int32
+	r.ExecuteToolCalls([]providers.ToolCall{{Name: "fetch"}, {Name: "fetch"}, {Name: "fetch"}}, 1,
+		func(tc providers.ToolCall) *ToolResult {
+			if n := running.Add(1); n > peak.Load() {
+				peak.Store(n)
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return SilentResult("ok")
+		})
+	if peak.Load() != 1 {
+		t.Errorf("expected calls to run one at a time, got %d at once", peak.Load())
+	}
+}
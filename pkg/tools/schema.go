@@ -0,0 +1,345 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// validateArgs checks args against a tool's Parameters() schema before the
+// tool runs. It understands the subset of JSON Schema tools use here: type,
+// properties, required, enum, items and numeric and length bounds; other
+// keywords are ignored. Values that are unambiguous but of the wrong type are
+// converted, so "12" becomes 12 for an integer, 12 becomes "12" for a string
+// and a single value becomes a one-element array. Numbers always come out as
+// float64, matching what tools get from decoded JSON.
+//
+// It returns the converted arguments and one line per problem found.
+func validateArgs(schema, args map[string]any) (map[string]any, []string) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	v := &argValidator{}
+	return v.object(schema, args, ""), v.problems
+}
+
+// formatArgProblems builds the error returned to the model for invalid arguments.
+func formatArgProblems(tool string, problems []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Invalid arguments for %s:\n", tool)
+	for _, problem := range problems {
+		fmt.Fprintf(&sb, "- %s\n", problem)
+	}
+	fmt.Fprintf(&sb, "Call %s again with corrected arguments.", tool)
+	return sb.String()
+}
+
+type argValidator struct {
+	problems []string
+}
+
+func (v *argValidator) fail(path, format string, a ...any) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, a...))
+}
+
+func (v *argValidator) object(schema, obj map[string]any, path string) map[string]any {
+	properties, _ := schema["properties"].(map[string]any)
+	out := make(map[string]any, len(obj))
+	for key, value := range obj {
+		prop, known := properties[key].(map[string]any)
+		switch {
+		case !known:
+			out[key] = value
+		case value == nil && !containsString(schemaTypes(prop), "null"):
+			// Models often send null for optional arguments they mean to omit.
+		default:
+			out[key] = v.value(prop, value, joinPath(path, key))
+		}
+	}
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := out[name]; !ok {
+			v.fail(joinPath(path, name), "is required")
+		}
+	}
+	return out
+}
+
+func (v *argValidator) value(schema map[string]any, value any, path string) any {
+	if types := schemaTypes(schema); len(types) > 0 {
+		converted, ok := convertValue(types, value)
+		if !ok {
+			v.fail(path, "expected %s, got %s", strings.Join(types, " or "), describeValue(value))
+			return value
+		}
+		value = converted
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		options := anyList(enum)
+		if !containsValue(options, value) {
+			v.fail(path, "must be one of %s, got %s", formatOptions(options), describeValue(value))
+		}
+	}
+
+	switch value := value.(type) {
+	case float64:
+		if minimum, ok := toFloat(schema["minimum"]); ok && value < minimum {
+			v.fail(path, "must be at least %v, got %v", minimum, value)
+		}
+		if maximum, ok := toFloat(schema["maximum"]); ok && value > maximum {
+			v.fail(path, "must be at most %v, got %v", maximum, value)
+		}
+	case string:
+		if minLength, ok := toFloat(schema["minLength"]); ok && float64(len([]rune(value))) < minLength {
+			v.fail(path, "must be at least %v characters long", minLength)
+		}
+		if maxLength, ok := toFloat(schema["maxLength"]); ok && float64(len([]rune(value))) > maxLength {
+			v.fail(path, "must be at most %v characters long", maxLength)
+		}
+	case []any:
+		if minItems, ok := toFloat(schema["minItems"]); ok && float64(len(value)) < minItems {
+			v.fail(path, "must have at least %v items", minItems)
+		}
+		if maxItems, ok := toFloat(schema["maxItems"]); ok && float64(len(value)) > maxItems {
+			v.fail(path, "must have at most %v items", maxItems)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			out := make([]any, len(value))
+			for i, item := range value {
+				out[i] = v.value(items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+			return out
+		}
+	case map[string]any:
+		if _, ok := schema["properties"]; ok {
+			return v.object(schema, value, path)
+		}
+	}
+	return value
+}
+
+// convertValue returns value as one of the given JSON types, preferring an
+// exact match over a conversion.
+func convertValue(types []string, value any) (any, bool) {
+	for _, typ := range types {
+		if converted, ok := exactValue(typ, value); ok {
+			return converted, true
+		}
+	}
+	for _, typ := range types {
+		if converted, ok := coerceValue(typ, value); ok {
+			return converted, true
+		}
+	}
+	return nil, false
+}
+
+// exactValue accepts value if it already has the JSON type typ, normalizing
+// Go numeric and slice types.
+func exactValue(typ string, value any) (any, bool) {
+	switch typ {
+	case "string":
+		s, ok := value.(string)
+		return s, ok
+	case "number":
+		return toFloat(value)
+	case "integer":
+		f, ok := toFloat(value)
+		return f, ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+	case "boolean":
+		b, ok := value.(bool)
+		return b, ok
+	case "array":
+		switch value := value.(type) {
+		case []any:
+			return value, true
+		case []string:
+			out := make([]any, len(value))
+			for i, s := range value {
+				out[i] = s
+			}
+			return out, true
+		}
+	case "object":
+		m, ok := value.(map[string]any)
+		return m, ok
+	case "null":
+		return nil, value == nil
+	default:
+		return value, true
+	}
+	return nil, false
+}
+
+// coerceValue converts value to the JSON type typ where the meaning is clear.
+func coerceValue(typ string, value any) (any, bool) {
+	switch typ {
+	case "string":
+		switch value.(type) {
+		case float64, bool, int, int64:
+			return fmt.Sprint(value), true
+		}
+	case "number":
+		if s, ok := value.(string); ok {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+		}
+	case "integer":
+		if s, ok := value.(string); ok {
+			s = strings.TrimSpace(s)
+			// Hex is accepted because that is how register addresses are written.
+			if hex, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+				i, err := strconv.ParseUint(hex, 16, 53)
+				return float64(i), err == nil
+			}
+			if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return float64(i), true
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+				return f, true
+			}
+		}
+	case "boolean":
+		if s, ok := value.(string); ok {
+			b, err := strconv.ParseBool(strings.TrimSpace(s))
+			return b, err == nil
+		}
+	case "array":
+		if s, ok := value.(string); ok && strings.HasPrefix(strings.TrimSpace(s), "[") {
+			var list []any
+			if json.Unmarshal([]byte(s), &list) == nil {
+				return list, true
+			}
+		}
+		if value != nil {
+			return []any{value}, true
+		}
+	case "object":
+		if s, ok := value.(string); ok && strings.HasPrefix(strings.TrimSpace(s), "{") {
+			var obj map[string]any
+			if json.Unmarshal([]byte(s), &obj) == nil {
+				return obj, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func schemaTypes(schema map[string]any) []string {
+	if typ, ok := schema["type"].(string); ok {
+		return []string{typ}
+	}
+	return stringList(schema["type"])
+}
+
+func stringList(value any) []string {
+	switch value := value.(type) {
+	case []string:
+		return value
+	case []any:
+		out := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func anyList(value any) []any {
+	switch value := value.(type) {
+	case []any:
+		return value
+	case []string:
+		out := make([]any, len(value))
+		for i, s := range value {
+			out[i] = s
+		}
+		return out
+	}
+	return nil
+}
+
+func toFloat(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(options []any, value any) bool {
+	for _, option := range options {
+		if f, ok := toFloat(option); ok {
+			if v, ok := toFloat(value); ok && f == v {
+				return true
+			}
+			continue
+		}
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatOptions(options []any) string {
+	parts := make([]string, len(options))
+	for i, option := range options {
+		parts[i] = fmt.Sprintf("%q", fmt.Sprint(option))
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func describeValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if len(value) > 40 {
+			value = value[:40] + "..."
+		}
+		return fmt.Sprintf("string %q", value)
+	case float64, int, int64:
+		return fmt.Sprintf("number %v", value)
+	case bool:
+		return fmt.Sprintf("boolean %v", value)
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
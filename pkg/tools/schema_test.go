@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"action":  map[string]any{"type": "string", "enum": []string{"read", "write"}},
+		"bus":     map[string]any{"type": "string"},
+		"address": map[string]any{"type": "integer", "minimum": 3.0, "maximum": 119.0},
+		"ratio":   map[string]any{"type": "number"},
+		"confirm": map[string]any{"type": "boolean"},
+		"data":    map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+		"tags":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"options": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"depth": map[string]any{"type": "integer"}},
+			"required":   []any{"depth"},
+		},
+	},
+	"required": []string{"action"},
+}
+
+func TestValidateArgs_Coerces(t *testing.T) {
+	args, problems := validateArgs(testSchema, map[string]any{
+		"action":  "write",
+		"bus":     1.0,
+		"address": "0x40",
+		"ratio":   " 2.5 ",
+		"confirm": "true",
+		"data":    "[1, \"2\"]",
+		"tags":    "solo",
+		"options": map[string]any{"depth": "010"},
+		"extra":   "kept",
+		"ignored": nil,
+	})
+	if len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	want := map[string]any{
+		"action":  "write",
+		"bus":     "1",
+		"address": 64.0,
+		"ratio":   2.5,
+		"confirm": true,
+		"data":    []any{1.0, 2.0},
+		"tags":    []any{"solo"},
+		"options": map[string]any{"depth": 10.0},
+		"extra":   "kept",
+		"ignored": nil,
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v\nwant %#v", args, want)
+	}
+}
+
+func TestValidateArgs_ReportsEveryProblem(t *testing.T) {
+	_, problems := validateArgs(testSchema, map[string]any{
+		"action":  "erase",
+		"address": 200,
+		"ratio":   "fast",
+		"confirm": nil,
+		"data":    []any{1.0, 1.5},
+		"options": map[string]any{},
+	})
+	want := []string{
+		`action: must be one of ["read", "write"], got string "erase"`,
+		"address: must be at most 119, got 200",
+		`ratio: expected number, got string "fast"`,
+		"data[1]: expected integer, got number 1.5",
+		"options.depth: is required",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("problems = %q, want %d of them", problems, len(want))
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			found = found || p == w
+		}
+		if !found {
+			t.Errorf("missing problem %q in %q", w, problems)
+		}
+	}
+
+	_, problems = validateArgs(testSchema, map[string]any{"action": nil})
+	if len(problems) != 1 || problems[0] != "action: is required" {
+		t.Errorf("expected a null required argument to be missing, got %q", problems)
+	}
+}
+
+func TestToolRegistry_Execute_RejectsInvalidArgs(t *testing.T) {
+	tool := &mockCtxTool{mockRegistryTool: *newMockTool("i2c", "bus access")}
+	tool.params = testSchema
+	r := NewToolRegistry()
+	r.Register(tool)
+
+	result := r.Execute(context.Background(), "i2c", map[string]any{"address": 4.0})
+	if !result.IsError {
+		t.Fatal("expected invalid arguments to be rejected")
+	}
+	if !strings.Contains(result.ForLLM, "Invalid arguments for i2c:\n- action: is required") {
+		t.Errorf("expected a structured error, got: %s", result.ForLLM)
+	}
+
+	var got map[string]any
+	capture := &argsCaptureTool{mockRegistryTool: *newMockTool("capture", ""), args: &got}
+	capture.params = testSchema
+	r.Register(capture)
+	if result := r.Execute(context.Background(), "capture", map[string]any{"action": "read", "address": "64"}); result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if got["address"] != 64.0 {
+		t.Errorf("expected the tool to receive coerced args, got %#v", got)
+	}
+}
+
+type argsCaptureTool struct {
+	mockRegistryTool
+	args *map[string]any
+}
+
+func (m *argsCaptureTool) Execute(_ context.Context, args map[string]any) *ToolResult {
+	*m.args = args
+	return m.result
+}
@@ -0,0 +1,488 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	searchMaxOutputChars = 20000
+	searchMaxLineChars   = 300
+	searchMaxFileBytes   = 8 << 20
+	grepDefaultMatches   = 100
+	grepMaxMatches       = 1000
+	grepMaxContext       = 10
+	globDefaultResults   = 200
+	globMaxResults       = 1000
+)
+
+// errStopWalk ends a search walk early once enough results were collected.
+var errStopWalk = errors.New("stop walk")
+
+// skippedSearchDirs are never descended into by grep and glob.
+var skippedSearchDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+}
+
+// GrepTool searches file contents under the workspace with a regular expression.
+type GrepTool struct {
+	workspace string
+	restrict  bool
+}
+
+func NewGrepTool(workspace string, restrict bool) *GrepTool {
+	return &GrepTool{workspace: workspace, restrict: restrict}
+}
+
+func (t *GrepTool) Name() string {
+	return "grep"
+}
+
+func (t *GrepTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *GrepTool) Description() string {
+	return "Search file contents with a regular expression (Go RE2 syntax). Returns matching lines as path:line: text. " +
+		"Skips binary files and .git/node_modules. Prefer this over running grep with exec."
+}
+
+func (t *GrepTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Regular expression to search for",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File or directory to search (default: workspace root)",
+			},
+			"include": map[string]any{
+				"type":        "string",
+				"description": "Only search files matching this glob, e.g. \"*.go\" or \"src/**/*.{ts,tsx}\"",
+			},
+			"ignore_case": map[string]any{
+				"type":        "boolean",
+				"description": "Match case-insensitively",
+			},
+			"context": map[string]any{
+				"type":        "integer",
+				"description": "Lines of context to show before and after each match",
+				"minimum":     0.0,
+				"maximum":     float64(grepMaxContext),
+			},
+			"max_matches": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Stop after this many matches (default %d)", grepDefaultMatches),
+				"minimum":     1.0,
+				"maximum":     float64(grepMaxMatches),
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GrepTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	if ignoreCase, _ := args["ignore_case"].(bool); ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("invalid pattern: %v", err))
+	}
+
+	include, _ := args["include"].(string)
+	if include != "" {
+		if err := checkGlob(include); err != nil {
+			return ErrorResult(fmt.Sprintf("invalid include pattern: %v", err))
+		}
+	}
+	contextLines := 0
+	if c, ok := args["context"].(float64); ok {
+		contextLines = min(max(int(c), 0), grepMaxContext)
+	}
+	maxMatches := grepDefaultMatches
+	if m, ok := args["max_matches"].(float64); ok && m >= 1 {
+		maxMatches = min(int(m), grepMaxMatches)
+	}
+
+	root, errResult := searchRoot(args, t.workspace, t.restrict)
+	if errResult != nil {
+		return errResult
+	}
+
+	var out strings.Builder
+	matches, files := 0, 0
+	truncated := false
+	err = walkSearchFiles(ctx, root, t.workspace, t.restrict, func(path, rel string, _ fs.FileInfo) error {
+		if include != "" && !matchInclude(include, rel) {
+			return nil
+		}
+		n, err := grepFile(path, rel, re, contextLines, maxMatches-matches, &out)
+		if err != nil {
+			return nil // Unreadable and binary files are skipped
+		}
+		if n > 0 {
+			files++
+			matches += n
+		}
+		if matches >= maxMatches || out.Len() >= searchMaxOutputChars {
+			truncated = true
+			return errStopWalk
+		}
+		return nil
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("search failed: %v", err))
+	}
+
+	if matches == 0 {
+		return NewToolResult("No matches found")
+	}
+	result := out.String()
+	if len(result) > searchMaxOutputChars {
+		result = result[:searchMaxOutputChars]
+	}
+	summary := fmt.Sprintf("\nFound %d matches in %d files", matches, files)
+	if truncated {
+		summary += " (results truncated; narrow the pattern, path or include filter)"
+	}
+	return NewToolResult(result + summary)
+}
+
+// grepFile appends the matches in one file to out, in grep's format: matches
+// as path:line: text, context as path-line- text and "--" between groups.
+func grepFile(path, rel string, re *regexp.Regexp, contextLines, limit int, out *strings.Builder) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	head, _ := reader.Peek(binarySniffBytes)
+	if looksBinary(head) {
+		return 0, errors.New("binary file")
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var before []string // the last contextLines lines, oldest first
+	matches, after, lastPrinted := 0, 0, 0
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		isMatch := matches < limit && re.MatchString(line)
+		switch {
+		case isMatch:
+			if lastPrinted > 0 && lineNo-len(before) > lastPrinted+1 {
+				out.WriteString("--\n")
+			}
+			for i, ctxLine := range before {
+				fmt.Fprintf(out, "%s-%d- %s\n", rel, lineNo-len(before)+i, clipLine(ctxLine))
+			}
+			fmt.Fprintf(out, "%s:%d: %s\n", rel, lineNo, clipLine(line))
+			before = before[:0]
+			after = contextLines
+			lastPrinted = lineNo
+			matches++
+		case after > 0:
+			fmt.Fprintf(out, "%s-%d- %s\n", rel, lineNo, clipLine(line))
+			after--
+			lastPrinted = lineNo
+		case matches >= limit:
+			return matches, nil
+		case contextLines > 0:
+			if len(before) == contextLines {
+				before = before[1:]
+			}
+			before = append(before, line)
+		}
+	}
+	if err := scanner.Err(); err != nil && matches == 0 {
+		return 0, err
+	}
+	return matches, nil
+}
+
+func clipLine(line string) string {
+	if len(line) > searchMaxLineChars {
+		return line[:searchMaxLineChars] + "..."
+	}
+	return line
+}
+
+// GlobTool lists files under the workspace matching a glob pattern.
+type GlobTool struct {
+	workspace string
+	restrict  bool
+}
+
+func NewGlobTool(workspace string, restrict bool) *GlobTool {
+	return &GlobTool{workspace: workspace, restrict: restrict}
+}
+
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+func (t *GlobTool) ConcurrencySafe() bool {
+	return true
+}
+
+func (t *GlobTool) Description() string {
+	return "Find files by name pattern. \"**\" matches any number of directories and {a,b} matches either, " +
+		"e.g. \"**/*.go\" or \"docs/**/*.{md,txt}\". Returns paths relative to the search path, most recently modified first."
+}
+
+func (t *GlobTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Glob pattern relative to path",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to search (default: workspace root)",
+			},
+			"max_results": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of paths to return (default %d)", globDefaultResults),
+				"minimum":     1.0,
+				"maximum":     float64(globMaxResults),
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GlobTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, _ := args["pattern"].(string)
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	if err := checkGlob(pattern); err != nil {
+		return ErrorResult(fmt.Sprintf("invalid pattern: %v", err))
+	}
+	maxResults := globDefaultResults
+	if m, ok := args["max_results"].(float64); ok && m >= 1 {
+		maxResults = min(int(m), globMaxResults)
+	}
+
+	root, errResult := searchRoot(args, t.workspace, t.restrict)
+	if errResult != nil {
+		return errResult
+	}
+
+	type match struct {
+		rel  string
+		info fs.FileInfo
+	}
+	var found []match
+	err := walkSearchFiles(ctx, root, t.workspace, t.restrict, func(_, rel string, info fs.FileInfo) error {
+		if matchGlob(pattern, rel) {
+			found = append(found, match{rel, info})
+		}
+		return nil
+	})
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("search failed: %v", err))
+	}
+	if len(found) == 0 {
+		return NewToolResult("No files found")
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].info.ModTime().After(found[j].info.ModTime())
+	})
+	var out strings.Builder
+	for i, m := range found {
+		if i == maxResults || out.Len() >= searchMaxOutputChars {
+			fmt.Fprintf(&out, "... and %d more files (narrow the pattern)\n", len(found)-i)
+			break
+		}
+		out.WriteString(m.rel + "\n")
+	}
+	return NewToolResult(out.String())
+}
+
+// searchRoot resolves the optional path argument of grep and glob.
+func searchRoot(args map[string]any, workspace string, restrict bool) (string, *ToolResult) {
+	root, _ := args["path"].(string)
+	if root == "" {
+		root = "."
+	}
+	resolved, err := validatePath(root, workspace, restrict)
+	if err != nil {
+		return "", ErrorResult(err.Error())
+	}
+	if _, err := os.Stat(resolved); err != nil {
+		return "", ErrorResult(fmt.Sprintf("cannot search %s: %v", root, err))
+	}
+	return resolved, nil
+}
+
+// walkSearchFiles calls fn for every regular file below root, with its path
+// relative to root in slash form. Symlinked files are only followed when they
+// stay inside the workspace, and symlinked directories are not descended.
+func walkSearchFiles(
+	ctx context.Context,
+	root, workspace string,
+	restrict bool,
+	fn func(path, rel string, info fs.FileInfo) error,
+) error {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if path != root && skippedSearchDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 && restrict {
+			if _, err := validatePath(path, workspace, true); err != nil {
+				return nil
+			}
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() > searchMaxFileBytes {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		if rel == "." {
+			rel = filepath.Base(path)
+		}
+		return fn(path, filepath.ToSlash(rel), info)
+	})
+	if errors.Is(err, errStopWalk) {
+		return nil
+	}
+	return err
+}
+
+// matchInclude matches grep's include filter: patterns without a slash apply
+// to the file name, others to the path relative to the search root.
+func matchInclude(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		return matchGlob(pattern, path.Base(rel))
+	}
+	return matchGlob(pattern, rel)
+}
+
+// matchGlob reports whether the slash-separated name matches pattern, where
+// "**" matches any number of path segments and {a,b} matches either a or b.
+func matchGlob(pattern, name string) bool {
+	nameParts := strings.Split(name, "/")
+	for _, p := range expandBraces(pattern) {
+		if matchSegments(strings.Split(p, "/"), nameParts) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// expandBraces expands {a,b} alternatives, including nested ones.
+func expandBraces(pattern string) []string {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		return []string{pattern}
+	}
+	depth := 0
+	var options []string
+	last := start + 1
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case ',':
+			if depth == 1 {
+				options = append(options, pattern[last:i])
+				last = i + 1
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				options = append(options, pattern[last:i])
+				var expanded []string
+				for _, option := range options {
+					expanded = append(expanded, expandBraces(pattern[:start]+option+pattern[i+1:])...)
+				}
+				return expanded
+			}
+		}
+	}
+	return []string{pattern} // Unbalanced braces match literally
+}
+
+// checkGlob reports syntax errors in pattern.
+func checkGlob(pattern string) error {
+	for _, p := range expandBraces(pattern) {
+		for _, segment := range strings.Split(p, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
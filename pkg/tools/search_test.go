@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeSearchTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":               "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+		"pkg/util/util.go":      "package util\n\n// Hello says hello.\nfunc Hello() string { return \"hello\" }\n",
+		"docs/guide.md":         "# Guide\nSay Hello to the team.\n",
+		"docs/notes.txt":        "nothing here\n",
+		".git/config":           "hello from git\n",
+		"node_modules/x/x.js":   "hello from deps\n",
+		"assets/logo.bin":       "hello\x00binary",
+		"pkg/util/util_test.go": "package util\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGrepTool_FindsMatches(t *testing.T) {
+	dir := writeSearchTree(t)
+	tool := NewGrepTool(dir, true)
+
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "hello"})
+	if result.IsError {
+		t.Fatalf("grep failed: %s", result.ForLLM)
+	}
+	for _, want := range []string{`main.go:4: 	println("hello")`, "pkg/util/util.go:4: ", "Found 3 matches in 2 files"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("expected %q in:\n%s", want, result.ForLLM)
+		}
+	}
+	for _, unwanted := range []string{".git", "node_modules", "logo.bin", "guide.md"} {
+		if strings.Contains(result.ForLLM, unwanted) {
+			t.Errorf("expected %s to be skipped:\n%s", unwanted, result.ForLLM)
+		}
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{
+		"pattern": "hello", "ignore_case": true, "include": "*.{md,txt}", "context": 1.0,
+	})
+	want := "docs/guide.md-1- # Guide\ndocs/guide.md:2: Say Hello to the team.\n"
+	if !strings.HasPrefix(result.ForLLM, want) {
+		t.Errorf("expected context and include filter, got:\n%s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "hello", "max_matches": 1.0})
+	if !strings.Contains(result.ForLLM, "Found 1 matches") || !strings.Contains(result.ForLLM, "truncated") {
+		t.Errorf("expected the match limit to apply, got:\n%s", result.ForLLM)
+	}
+}
+
+func TestGrepTool_ContextGroups(t *testing.T) {
+	dir := t.TempDir()
+	lines := "a\nmatch\nb\nc\nd\ne\nmatch\nf\n"
+	if err := os.WriteFile(filepath.Join(dir, "f.txt"), []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result := NewGrepTool(dir, true).Execute(context.Background(), map[string]any{"pattern": "^match$", "context": 1.0})
+	want := "f.txt-1- a\nf.txt:2: match\nf.txt-3- b\n--\nf.txt-6- e\nf.txt:7: match\nf.txt-8- f\n"
+	if !strings.HasPrefix(result.ForLLM, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", result.ForLLM, want)
+	}
+}
+
+func TestGrepTool_RestrictsToWorkspace(t *testing.T) {
+	dir := writeSearchTree(t)
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("hello secret\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	tool := NewGrepTool(dir, true)
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "hello", "path": outside})
+	if !result.IsError {
+		t.Errorf("expected a path outside the workspace to be denied, got:\n%s", result.ForLLM)
+	}
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "secret"})
+	if strings.Contains(result.ForLLM, "link.txt") {
+		t.Errorf("expected the symlink leaving the workspace to be skipped, got:\n%s", result.ForLLM)
+	}
+}
+
+func TestGlobTool_MatchesAndSortsByMtime(t *testing.T) {
+	dir := writeSearchTree(t)
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "main.go"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewGlobTool(dir, true)
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "**/*.go"})
+	got := strings.Split(strings.TrimSpace(result.ForLLM), "\n")
+	if len(got) != 3 || got[2] != "main.go" {
+		t.Errorf("expected 3 Go files with main.go last, got:\n%s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "*.{md,txt}", "path": "docs"})
+	if strings.TrimSpace(result.ForLLM) != "guide.md\nnotes.txt" && strings.TrimSpace(result.ForLLM) != "notes.txt\nguide.md" {
+		t.Errorf("expected the docs files, got:\n%s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "**/*.go", "max_results": 1.0})
+	if !strings.Contains(result.ForLLM, "and 2 more files") {
+		t.Errorf("expected the result limit to apply, got:\n%s", result.ForLLM)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "[", "path": "docs"})
+	if !result.IsError {
+		t.Errorf("expected an invalid pattern error, got:\n%s", result.ForLLM)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"pkg/**", "pkg/a/b", true},
+		{"pkg/**/util.go", "pkg/util.go", true},
+		{"src/**/*.{ts,tsx}", "src/app/view.tsx", true},
+		{"{a,b/{c,d}}.txt", "b/d.txt", true},
+		{"docs/*.md", "docs/sub/x.md", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
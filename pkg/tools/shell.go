@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/sandbox"
 )
 
 type ExecTool struct {
@@ -22,8 +23,14 @@ type ExecTool struct {
 	denyPatterns        []*regexp.Regexp
 	allowPatterns       []*regexp.Regexp
 	restrictToWorkspace bool
+	sandbox             *sandbox.Options // nil runs commands directly on the host
+	processes           *ProcessManager  // nil disables background commands
+	maxOutputChars      int
 }
 
+// execMaxUserChars caps the command output echoed to the chat.
+const execMaxUserChars = 10000
+
 var defaultDenyPatterns = []*regexp.Regexp{
 	regexp.MustCompile(`\brm\s+-[rf]{1,2}\b`),
 	regexp.MustCompile(`\bdel\s+/[fq]\b`),
@@ -102,13 +109,26 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 		denyPatterns = append(denyPatterns, defaultDenyPatterns...)
 	}
 
-	return &ExecTool{
+	tool := &ExecTool{
 		workingDir:          workingDir,
 		timeout:             60 * time.Second,
 		denyPatterns:        denyPatterns,
 		allowPatterns:       nil,
 		restrictToWorkspace: restrict,
+		maxOutputChars:      execMaxUserChars,
+	}
+	if config != nil && config.Tools.Exec.Sandbox.Enabled {
+		sb := config.Tools.Exec.Sandbox
+		tool.sandbox = &sandbox.Options{
+			Workspace:     workingDir,
+			ReadOnlyPaths: sb.ReadOnlyPaths,
+			AllowNetwork:  sb.AllowNetwork,
+			MaxCPUSeconds: sb.MaxCPUSeconds,
+			MaxMemoryMB:   sb.MaxMemoryMB,
+			MaxProcesses:  sb.MaxProcesses,
+		}
 	}
+	return tool
 }
 
 func (t *ExecTool) Name() string {
@@ -120,19 +140,26 @@ func (t *ExecTool) Description() string {
 }
 
 func (t *ExecTool) Parameters() map[string]any {
-	return map[string]any{
-		"type": "object",
-		"properties": map[string]any{
-			"command": map[string]any{
-				"type":        "string",
-				"description": "The shell command to execute",
-			},
-			"working_dir": map[string]any{
-				"type":        "string",
-				"description": "Optional working directory for the command",
-			},
+	properties := map[string]any{
+		"command": map[string]any{
+			"type":        "string",
+			"description": "The shell command to execute",
+		},
+		"working_dir": map[string]any{
+			"type":        "string",
+			"description": "Optional working directory for the command",
 		},
-		"required": []string{"command"},
+	}
+	if t.processes != nil {
+		properties["background"] = map[string]any{
+			"type":        "boolean",
+			"description": "Run without waiting or a timeout and return a process ID for the process tool. Use for servers, watchers and long builds.",
+		}
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"command"},
 	}
 }
 
@@ -166,6 +193,10 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		return ErrorResult(guardError)
 	}
 
+	if background, _ := args["background"].(bool); background {
+		return t.startBackground(command, cwd)
+	}
+
 	// timeout == 0 means no timeout
 	var cmdCtx context.Context
 	var cancel context.CancelFunc
@@ -176,18 +207,11 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	}
 	defer cancel()
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
-	} else {
-		cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
-	}
-	if cwd != "" {
-		cmd.Dir = cwd
+	cmd, errResult := t.command(cmdCtx, command, cwd)
+	if errResult != nil {
+		return errResult
 	}
 
-	prepareCommandForTermination(cmd)
-
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -237,26 +261,77 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		output = "(no output)"
 	}
 
-	maxLen := 10000
-	if len(output) > maxLen {
-		output = output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
-	}
+	forLLM := truncateOutput(output, t.maxOutputChars)
+	forUser := truncateOutput(output, execMaxUserChars)
 
 	if err != nil {
 		return &ToolResult{
-			ForLLM:  output,
-			ForUser: output,
+			ForLLM:  forLLM,
+			ForUser: forUser,
 			IsError: true,
 		}
 	}
 
 	return &ToolResult{
-		ForLLM:  output,
-		ForUser: output,
+		ForLLM:  forLLM,
+		ForUser: forUser,
 		IsError: false,
 	}
 }
 
+func truncateOutput(output string, maxLen int) string {
+	if len(output) > maxLen {
+		return output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
+	}
+	return output
+}
+
+// command builds the shell invocation for command, sandboxed if configured.
+func (t *ExecTool) command(ctx context.Context, command, cwd string) (*exec.Cmd, *ToolResult) {
+	var cmd *exec.Cmd
+	switch {
+	case t.sandbox != nil:
+		var err error
+		if cmd, err = sandbox.Command(ctx, *t.sandbox, cwd, "sh", "-c", command); err != nil {
+			return nil, ErrorResult(fmt.Sprintf("Command blocked: sandbox unavailable (%v)", err)).WithError(err)
+		}
+	case runtime.GOOS == "windows":
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	if cwd != "" {
+		cmd.Dir = cwd
+	}
+
+	prepareCommandForTermination(cmd)
+	return cmd, nil
+}
+
+// startBackground starts command detached from the current turn and hands it
+// to the process manager. It runs until it exits or is killed.
+func (t *ExecTool) startBackground(command, cwd string) *ToolResult {
+	if t.processes == nil {
+		return ErrorResult("background commands are not available")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd, errResult := t.command(ctx, command, cwd)
+	if errResult != nil {
+		cancel()
+		return errResult
+	}
+
+	proc, err := t.processes.Start(cmd, cancel, command)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to start command: %v", err)).WithError(err)
+	}
+	return SilentResult(fmt.Sprintf(
+		"Started background process %s. Use the process tool to poll its output, write to its stdin or kill it.",
+		proc.ID,
+	))
+}
+
 func (t *ExecTool) guardCommand(command, cwd string) string {
 	cmd := strings.TrimSpace(command)
 	lower := strings.ToLower(cmd)
@@ -313,6 +388,17 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	return ""
 }
 
+// SetProcessManager enables background commands, tracked by manager.
+func (t *ExecTool) SetProcessManager(manager *ProcessManager) {
+	t.processes = manager
+}
+
+// SetMaxOutputChars changes where command output is cut off. Raise it when
+// the registry saves long results to a file, so the saved copy is complete.
+func (t *ExecTool) SetMaxOutputChars(n int) {
+	t.maxOutputChars = n
+}
+
 func (t *ExecTool) SetTimeout(timeout time.Duration) {
 	t.timeout = timeout
 }
@@ -4,9 +4,12 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 // TestShellTool_Success verifies successful command execution
@@ -272,3 +275,34 @@ func TestShellTool_RestrictToWorkspace(t *testing.T) {
 		)
 	}
 }
+
+// TestShellTool_Sandbox verifies sandboxed commands only see the workspace
+func TestShellTool_Sandbox(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644)
+
+	cfg := config.DefaultConfig()
+	cfg.Tools.Exec.Sandbox.Enabled = true
+	tool := NewExecToolWithConfig(workspace, false, cfg)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "echo ok > made.txt; cat " + filepath.Join(outside, "secret.txt"),
+	})
+	if runtime.GOOS != "linux" {
+		if !result.IsError || !strings.Contains(result.ForLLM, "sandbox unavailable") {
+			t.Errorf("Expected commands to be refused without a sandbox, got: %s", result.ForLLM)
+		}
+		return
+	}
+	if strings.Contains(result.ForLLM, "sandbox: ") {
+		t.Skipf("sandbox unavailable here: %s", result.ForLLM)
+	}
+
+	if strings.HasPrefix(result.ForLLM, "secret") {
+		t.Errorf("Expected files outside the workspace to be hidden, got: %s", result.ForLLM)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "made.txt")); err != nil {
+		t.Errorf("Expected the command to write to the workspace: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,16 +11,74 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
+const (
+	// DefaultMaxSubagentDepth lets agents spawn subagents that cannot spawn
+	// subagents of their own.
+	DefaultMaxSubagentDepth = 1
+	// maxRunningSubagents bounds the background subagents one manager runs at once.
+	maxRunningSubagents = 8
+	// maxTaskProgressChars is how much partial output is kept per task.
+	maxTaskProgressChars = 2000
+)
+
+// SubagentProfile is what a subagent runs as: the model, prompt and tools of
+// the agent it was spawned for.
+type SubagentProfile struct {
+	Provider      providers.LLMProvider
+	Model         string
+	Chat          ChatFunc // optional; replaces Provider.Chat, e.g. to try fallback models
+	SystemPrompt  string
+	Tools         *ToolRegistry
+	MaxIterations int
+	LLMOptions    map[string]any
+}
+
+// SubagentResolver returns the profile a subagent of agentID runs with; an
+// empty agentID means the spawning agent itself. depth is the nesting level
+// of the new subagent, starting at 1.
+type SubagentResolver func(agentID string, depth int) (*SubagentProfile, error)
+
+type subagentDepthKey struct{}
+
+// subagentDepth returns how deeply nested the subagent running ctx is; 0 for
+// a top-level agent turn.
+func subagentDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(subagentDepthKey{}).(int)
+	return depth
+}
+
+// spawnedSubagentPrompt is the system prompt of background subagents when no
+// resolver supplies the target agent's own.
+const spawnedSubagentPrompt = `You are a subagent. Complete the given task independently and report the result.
+You have access to tools - use them as needed to complete your task.
+After completing the task, provide a clear summary of what was done.`
+
+// SubagentTask is a background subagent started by Spawn. Status is one of
+// running, completed, failed, cancelled or interrupted.
 type SubagentTask struct {
-	ID            string
-	Task          string
-	Label         string
-	AgentID       string
-	OriginChannel string
-	OriginChatID  string
-	Status        string
-	Result        string
-	Created       int64
+	ID            string `json:"id"`
+	Task          string `json:"task"`
+	Label         string `json:"label,omitempty"`
+	AgentID       string `json:"agent_id,omitempty"`
+	OriginChannel string `json:"origin_channel"`
+	OriginChatID  string `json:"origin_chat_id"`
+	Status        string `json:"status"`
+	Result        string `json:"result,omitempty"`
+	Progress      string `json:"progress,omitempty"` // the tail of what the subagent has said and done so far
+	Created       int64  `json:"created"`
+	Finished      int64  `json:"finished,omitempty"`
+	Notified      bool   `json:"notified,omitempty"` // the origin chat has been told the outcome
+
+	cancel context.CancelFunc
+}
+
+// Elapsed returns how long the task ran, or has been running.
+func (t *SubagentTask) Elapsed() time.Duration {
+	end := t.Finished
+	if end == 0 {
+		end = time.Now().UnixMilli()
+	}
+	return time.Duration(end-t.Created) * time.Millisecond
 }
 
 type SubagentManager struct {
@@ -35,7 +94,13 @@ type SubagentManager struct {
 	temperature    float64
 	hasMaxTokens   bool
 	hasTemperature bool
+	resolver       SubagentResolver
+	maxDepth       int
 	nextID         int
+	storePath      string
+
+	ctx  context.Context // cancelled by Shutdown; background tasks run under it
+	stop context.CancelFunc
 }
 
 func NewSubagentManager(
@@ -43,6 +108,7 @@ func NewSubagentManager(
 	defaultModel, workspace string,
 	bus *bus.MessageBus,
 ) *SubagentManager {
+	ctx, stop := context.WithCancel(context.Background())
 	return &SubagentManager{
 		tasks:         make(map[string]*SubagentTask),
 		provider:      provider,
@@ -51,10 +117,19 @@ func NewSubagentManager(
 		workspace:     workspace,
 		tools:         NewToolRegistry(),
 		maxIterations: 10,
+		maxDepth:      DefaultMaxSubagentDepth,
 		nextID:        1,
+		ctx:           ctx,
+		stop:          stop,
 	}
 }
 
+// Shutdown cancels the running background tasks. They are kept as
+// interrupted, to be reported to their chats after a restart.
+func (sm *SubagentManager) Shutdown() {
+	sm.stop()
+}
+
 // SetLLMOptions sets max tokens and temperature for subagent LLM calls.
 func (sm *SubagentManager) SetLLMOptions(maxTokens int, temperature float64) {
 	sm.mu.Lock()
@@ -73,6 +148,22 @@ func (sm *SubagentManager) SetTools(tools *ToolRegistry) {
 	sm.tools = tools
 }
 
+// SetResolver makes subagents run as the agent they were spawned for, with
+// the profile resolver returns, instead of the manager's own model and tools.
+func (sm *SubagentManager) SetResolver(resolver SubagentResolver) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resolver = resolver
+}
+
+// SetMaxDepth sets how deeply subagents started through this manager may
+// nest: 1 means subagents cannot spawn subagents of their own.
+func (sm *SubagentManager) SetMaxDepth(depth int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.maxDepth = depth
+}
+
 // RegisterTool registers a tool for subagent execution.
 func (sm *SubagentManager) RegisterTool(tool Tool) {
 	sm.mu.Lock()
@@ -85,9 +176,23 @@ func (sm *SubagentManager) Spawn(
 	task, label, agentID, originChannel, originChatID string,
 	callback AsyncCallback,
 ) (string, error) {
+	if err := sm.checkDepth(ctx); err != nil {
+		return "", err
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
+	running := 0
+	for _, t := range sm.tasks {
+		if t.Status == "running" {
+			running++
+		}
+	}
+	if running >= maxRunningSubagents {
+		return "", fmt.Errorf("%d subagents are already running; wait for one to finish", running)
+	}
+
 	taskID := fmt.Sprintf("subagent-%d", sm.nextID)
 	sm.nextID++
 
@@ -103,8 +208,17 @@ func (sm *SubagentManager) Spawn(
 	}
 	sm.tasks[taskID] = subagentTask
 
-	// Start task in background with context cancellation support
-	go sm.runTask(ctx, subagentTask, callback)
+	// The task outlives the turn that spawned it: it keeps the turn's values
+	// but is cancelled only by Cancel or Shutdown.
+	taskCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stopOnShutdown := context.AfterFunc(sm.ctx, cancel)
+	subagentTask.cancel = func() {
+		stopOnShutdown()
+		cancel()
+	}
+	sm.saveLocked()
+
+	go sm.runTask(taskCtx, subagentTask, callback)
 
 	if label != "" {
 		return fmt.Sprintf("Spawned subagent '%s' for task: %s", label, task), nil
@@ -112,84 +226,38 @@ func (sm *SubagentManager) Spawn(
 	return fmt.Sprintf("Spawned subagent for task: %s", task), nil
 }
 
-func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
-	task.Status = "running"
-	task.Created = time.Now().UnixMilli()
-
-	// Build system prompt for subagent
-	systemPrompt := `You are a subagent. Complete the given task independently and report the result.
-You have access to tools - use them as needed to complete your task.
-After completing the task, provide a clear summary of what was done.`
-
-	messages := []providers.Message{
-		{
-			Role:    "system",
-			Content: systemPrompt,
-		},
-		{
-			Role:    "user",
-			Content: task.Task,
-		},
-	}
+// runTask runs task under taskCtx, which is cancelled by Cancel or Shutdown,
+// and reports the outcome to the chat that started it.
+func (sm *SubagentManager) runTask(
+	taskCtx context.Context,
+	task *SubagentTask,
+	callback AsyncCallback,
+) {
+	loopResult, err := sm.run(taskCtx, task.AgentID, task.Task, spawnedSubagentPrompt,
+		ExecutionContext{Channel: task.OriginChannel, ChatID: task.OriginChatID},
+		func(note string) { sm.addProgress(task, note) })
 
-	// Check if context is already cancelled before starting
-	select {
-	case <-ctx.Done():
-		sm.mu.Lock()
-		task.Status = "cancelled"
-		task.Result = "Task cancelled before execution"
+	sm.mu.Lock()
+	task.cancel()
+	if task.Status != "running" {
+		// Cancelled through Cancel, which already recorded the outcome.
 		sm.mu.Unlock()
 		return
-	default:
 	}
-
-	// Run tool loop with access to tools
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
+	task.Finished = time.Now().UnixMilli()
+	if err != nil && sm.ctx.Err() != nil {
+		// The agent is shutting down; keep the task to report after a restart.
+		task.Status = "interrupted"
+		task.Result = "Task interrupted before it finished"
+		sm.saveLocked()
+		sm.mu.Unlock()
+		return
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, task.OriginChannel, task.OriginChatID)
-
-	sm.mu.Lock()
 	var result *ToolResult
-	defer func() {
-		sm.mu.Unlock()
-		// Call callback if provided and result is set
-		if callback != nil && result != nil {
-			callback(ctx, result)
-		}
-	}()
-
 	if err != nil {
 		task.Status = "failed"
 		task.Result = fmt.Sprintf("Error: %v", err)
-		// Check if it was cancelled
-		if ctx.Err() != nil {
-			task.Status = "cancelled"
-			task.Result = "Task cancelled during execution"
-		}
 		result = &ToolResult{
 			ForLLM:  task.Result,
 			ForUser: "",
@@ -214,35 +282,176 @@ After completing the task, provide a clear summary of what was done.`
 			Async:   false,
 		}
 	}
+	task.Notified = true
+	sm.saveLocked()
+	announceContent := fmt.Sprintf("Task '%s' completed.\n\nResult:\n%s", task.Label, task.Result)
+	sm.mu.Unlock()
+
+	if callback != nil {
+		callback(context.WithoutCancel(taskCtx), result)
+	}
+	sm.announce(task, announceContent)
+}
+
+// announce sends content about task back to the main agent of the chat that
+// started it.
+func (sm *SubagentManager) announce(task *SubagentTask, content string) {
+	if sm.bus == nil {
+		return
+	}
+	sm.bus.PublishInbound(bus.InboundMessage{
+		Channel:  "system",
+		SenderID: fmt.Sprintf("subagent:%s", task.ID),
+		// Format: "original_channel:original_chat_id" for routing back
+		ChatID:  fmt.Sprintf("%s:%s", task.OriginChannel, task.OriginChatID),
+		Content: content,
+	})
+}
+
+// addProgress appends note to the task's partial output, keeping only the
+// most recent maxTaskProgressChars, and saves it so a restart can report it.
+func (sm *SubagentManager) addProgress(task *SubagentTask, note string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	progress := task.Progress
+	if progress != "" {
+		progress += "\n"
+	}
+	task.Progress = cutTail(progress+strings.TrimSpace(note), maxTaskProgressChars)
+	sm.saveLocked()
+}
+
+// Cancel stops a running task without reporting it back to its chat. It
+// returns false if there is no such task or it has already finished.
+func (sm *SubagentManager) Cancel(taskID string) bool {
+	sm.mu.Lock()
+	task, ok := sm.tasks[taskID]
+	if !ok || task.Status != "running" {
+		sm.mu.Unlock()
+		return false
+	}
+	task.Status = "cancelled"
+	task.Result = "Task cancelled by user"
+	task.Finished = time.Now().UnixMilli()
+	task.Notified = true
+	cancel := task.cancel
+	sm.saveLocked()
+	sm.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	return true
+}
+
+// checkDepth refuses to start a subagent from a subagent already nested as
+// deeply as the manager allows.
+func (sm *SubagentManager) checkDepth(ctx context.Context) error {
+	sm.mu.RLock()
+	maxDepth := sm.maxDepth
+	sm.mu.RUnlock()
+	if depth := subagentDepth(ctx); depth >= maxDepth {
+		if depth == 0 {
+			return fmt.Errorf("subagents are disabled")
+		}
+		return fmt.Errorf("subagents may only be nested %d deep; do this task yourself", maxDepth)
+	}
+	return nil
+}
+
+// run executes task as a subagent of agentID and returns its final answer.
+// Without a resolver it uses the manager's own model and tools and
+// defaultPrompt as the system prompt.
+func (sm *SubagentManager) run(
+	ctx context.Context,
+	agentID, task, defaultPrompt string,
+	execCtx ExecutionContext,
+	progress func(note string),
+) (*ToolLoopResult, error) {
+	depth := subagentDepth(ctx) + 1
+
+	sm.mu.RLock()
+	resolver := sm.resolver
+	profile := &SubagentProfile{
+		Provider:      sm.provider,
+		Model:         sm.defaultModel,
+		SystemPrompt:  defaultPrompt,
+		Tools:         sm.tools,
+		MaxIterations: sm.maxIterations,
+	}
+	if sm.hasMaxTokens || sm.hasTemperature {
+		profile.LLMOptions = map[string]any{}
+		if sm.hasMaxTokens {
+			profile.LLMOptions["max_tokens"] = sm.maxTokens
+		}
+		if sm.hasTemperature {
+			profile.LLMOptions["temperature"] = sm.temperature
+		}
+	}
+	sm.mu.RUnlock()
+
+	if resolver != nil {
+		var err error
+		if profile, err = resolver(agentID, depth); err != nil {
+			return nil, err
+		}
+	}
 
-	// Send announce message back to main agent
-	if sm.bus != nil {
-		announceContent := fmt.Sprintf("Task '%s' completed.\n\nResult:\n%s", task.Label, task.Result)
-		sm.bus.PublishInbound(bus.InboundMessage{
-			Channel:  "system",
-			SenderID: fmt.Sprintf("subagent:%s", task.ID),
-			// Format: "original_channel:original_chat_id" for routing back
-			ChatID:  fmt.Sprintf("%s:%s", task.OriginChannel, task.OriginChatID),
-			Content: announceContent,
-		})
+	messages := []providers.Message{
+		{
+			Role:    "system",
+			Content: profile.SystemPrompt,
+		},
+		{
+			Role:    "user",
+			Content: task,
+		},
 	}
+
+	return RunToolLoop(context.WithValue(ctx, subagentDepthKey{}, depth), ToolLoopConfig{
+		Provider:      profile.Provider,
+		Model:         profile.Model,
+		Chat:          profile.Chat,
+		Tools:         profile.Tools,
+		MaxIterations: profile.MaxIterations,
+		LLMOptions:    profile.LLMOptions,
+		Progress:      progress,
+	}, messages, execCtx)
 }
 
+// originOf returns the channel and chat ID a subagent should report back to,
+// defaulting to the CLI when the turn has no routable origin.
+func originOf(ctx context.Context) (string, string) {
+	channel, chatID := ToolChannel(ctx), ToolChatID(ctx)
+	if channel == "" || chatID == "" {
+		return "cli", "direct"
+	}
+	return channel, chatID
+}
+
+// GetTask returns a copy of the task with the given ID.
 func (sm *SubagentManager) GetTask(taskID string) (*SubagentTask, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 	task, ok := sm.tasks[taskID]
-	return task, ok
+	if !ok {
+		return nil, false
+	}
+	copied := *task
+	return &copied, true
 }
 
+// ListTasks returns copies of all known tasks, oldest first.
 func (sm *SubagentManager) ListTasks() []*SubagentTask {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	tasks := make([]*SubagentTask, 0, len(sm.tasks))
 	for _, task := range sm.tasks {
-		tasks = append(tasks, task)
+		copied := *task
+		tasks = append(tasks, &copied)
 	}
+	sortTasks(tasks)
 	return tasks
 }
 
@@ -250,16 +459,12 @@ func (sm *SubagentManager) ListTasks() []*SubagentTask {
 // Unlike SpawnTool which runs tasks asynchronously, SubagentTool waits for completion
 // and returns the result directly in the ToolResult.
 type SubagentTool struct {
-	manager       *SubagentManager
-	originChannel string
-	originChatID  string
+	manager *SubagentManager
 }
 
 func NewSubagentTool(manager *SubagentManager) *SubagentTool {
 	return &SubagentTool{
-		manager:       manager,
-		originChannel: "cli",
-		originChatID:  "direct",
+		manager: manager,
 	}
 }
 
@@ -288,11 +493,6 @@ func (t *SubagentTool) Parameters() map[string]any {
 	}
 }
 
-func (t *SubagentTool) SetContext(channel, chatID string) {
-	t.originChannel = channel
-	t.originChatID = chatID
-}
-
 func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	task, ok := args["task"].(string)
 	if !ok {
@@ -305,47 +505,14 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("Subagent manager not configured").WithError(fmt.Errorf("manager is nil"))
 	}
 
-	// Build messages for subagent
-	messages := []providers.Message{
-		{
-			Role:    "system",
-			Content: "You are a subagent. Complete the given task independently and provide a clear, concise result.",
-		},
-		{
-			Role:    "user",
-			Content: task,
-		},
-	}
-
-	// Use RunToolLoop to execute with tools (same as async SpawnTool)
-	sm := t.manager
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
+	if err := t.manager.checkDepth(ctx); err != nil {
+		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, t.originChannel, t.originChatID)
+	originChannel, originChatID := originOf(ctx)
+	loopResult, err := t.manager.run(ctx, "", task,
+		"You are a subagent. Complete the given task independently and provide a clear, concise result.",
+		ExecutionContext{Channel: originChannel, ChatID: originChatID}, nil)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxFinishedTasks is how many finished tasks a manager remembers; running
+// tasks are always kept.
+const maxFinishedTasks = 50
+
+// SetStorePath keeps task records and results in the JSON file at path so
+// they outlive the process. Tasks a previous process did not get to finish
+// are marked interrupted and reported to the chats that started them.
+func (sm *SubagentManager) SetStorePath(path string) error {
+	var stored []*SubagentTask
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	sm.mu.Lock()
+	sm.storePath = path
+	var interrupted []*SubagentTask
+	for _, task := range stored {
+		if task == nil || task.ID == "" {
+			continue
+		}
+		if task.Status == "running" {
+			task.Status = "interrupted"
+			task.Result = "Task interrupted by a restart before it finished"
+			task.Finished = time.Now().UnixMilli()
+		}
+		if task.Status == "interrupted" && !task.Notified {
+			task.Notified = true
+			interrupted = append(interrupted, task)
+		}
+		sm.tasks[task.ID] = task
+		if n := taskNumber(task.ID); n >= sm.nextID {
+			sm.nextID = n + 1
+		}
+	}
+	if len(interrupted) > 0 {
+		sm.saveLocked()
+	}
+	sm.mu.Unlock()
+
+	if len(interrupted) > 0 {
+		// The bus is not consumed until the agent loop runs; don't block startup on it.
+		go func() {
+			for _, task := range interrupted {
+				content := fmt.Sprintf("Task '%s' was interrupted by a restart before it finished.\n\nTask:\n%s",
+					task.Label, task.Task)
+				if task.Progress != "" {
+					content += "\n\nPartial output:\n" + task.Progress
+				}
+				sm.announce(task, content)
+			}
+		}()
+	}
+	return nil
+}
+
+// saveLocked writes the task records to the store, if there is one, after
+// dropping the oldest finished tasks. sm.mu must be held.
+func (sm *SubagentManager) saveLocked() {
+	tasks := make([]*SubagentTask, 0, len(sm.tasks))
+	for _, task := range sm.tasks {
+		tasks = append(tasks, task)
+	}
+	sortTasks(tasks)
+
+	finished := 0
+	for i := len(tasks) - 1; i >= 0; i-- {
+		if tasks[i].Status == "running" {
+			continue
+		}
+		if finished++; finished > maxFinishedTasks {
+			delete(sm.tasks, tasks[i].ID)
+			tasks = append(tasks[:i], tasks[i+1:]...)
+		}
+	}
+
+	if sm.storePath == "" {
+		return
+	}
+	if err := writeTaskStore(sm.storePath, tasks); err != nil {
+		logger.WarnCF("subagent", "Failed to save subagent tasks",
+			map[string]any{
+				"path":  sm.storePath,
+				"error": err.Error(),
+			})
+	}
+}
+
+// writeTaskStore replaces the file at path with tasks, through a temporary
+// file so a crash never leaves it half written.
+func writeTaskStore(path string, tasks []*SubagentTask) error {
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tasks-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// sortTasks orders tasks by when they were started.
+func sortTasks(tasks []*SubagentTask) {
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Created != tasks[j].Created {
+			return tasks[i].Created < tasks[j].Created
+		}
+		return taskNumber(tasks[i].ID) < taskNumber(tasks[j].ID)
+	})
+}
+
+// taskNumber returns the N of a "subagent-N" task ID, or 0.
+func taskNumber(id string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(id, "subagent-"))
+	return n
+}
@@ -2,8 +2,10 @@ package tools
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/providers"
@@ -50,7 +52,6 @@ func TestSubagentManager_SetLLMOptions_AppliesToRunToolLoop(t *testing.T) {
 	manager := NewSubagentManager(provider, "test-model", "/tmp/test", nil)
 	manager.SetLLMOptions(2048, 0.6)
 	tool := NewSubagentTool(manager)
-	tool.SetContext("cli", "direct")
 
 	ctx := context.Background()
 	args := map[string]any{"task": "Do something"}
@@ -147,17 +148,18 @@ func TestSubagentTool_Parameters(t *testing.T) {
 	}
 }
 
-// TestSubagentTool_SetContext verifies context setting
-func TestSubagentTool_SetContext(t *testing.T) {
-	provider := &MockLLMProvider{}
-	manager := NewSubagentManager(provider, "test-model", "/tmp/test", nil)
-	tool := NewSubagentTool(manager)
-
-	tool.SetContext("test-channel", "test-chat")
+// TestSubagentTool_OriginDefaultsToCLI verifies the origin used without an execution context
+func TestSubagentTool_OriginDefaultsToCLI(t *testing.T) {
+	channel, chatID := originOf(context.Background())
+	if channel != "cli" || chatID != "direct" {
+		t.Errorf("Expected cli:direct origin, got %s:%s", channel, chatID)
+	}
 
-	// Verify context is set (we can't directly access private fields,
-	// but we can verify it doesn't crash)
-	// The actual context usage is tested in Execute tests
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "telegram", ChatID: "chat-1"})
+	channel, chatID = originOf(ctx)
+	if channel != "telegram" || chatID != "chat-1" {
+		t.Errorf("Expected telegram:chat-1 origin, got %s:%s", channel, chatID)
+	}
 }
 
 // TestSubagentTool_Execute_Success tests successful execution
@@ -166,9 +168,8 @@ func TestSubagentTool_Execute_Success(t *testing.T) {
 	msgBus := bus.NewMessageBus()
 	manager := NewSubagentManager(provider, "test-model", "/tmp/test", msgBus)
 	tool := NewSubagentTool(manager)
-	tool.SetContext("telegram", "chat-123")
 
-	ctx := context.Background()
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "telegram", ChatID: "chat-123"})
 	args := map[string]any{
 		"task":  "Write a haiku about coding",
 		"label": "haiku-task",
@@ -297,12 +298,10 @@ func TestSubagentTool_Execute_ContextPassing(t *testing.T) {
 	manager := NewSubagentManager(provider, "test-model", "/tmp/test", msgBus)
 	tool := NewSubagentTool(manager)
 
-	// Set context
+	// Set execution context
 	channel := "test-channel"
 	chatID := "test-chat"
-	tool.SetContext(channel, chatID)
-
-	ctx := context.Background()
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: channel, ChatID: chatID})
 	args := map[string]any{
 		"task": "Test context passing",
 	}
@@ -348,3 +347,87 @@ func TestSubagentTool_ForUserTruncation(t *testing.T) {
 		t.Error("ForLLM should contain reference to original task")
 	}
 }
+
+func TestSubagentManager_Resolver(t *testing.T) {
+	provider := &MockLLMProvider{}
+	manager := NewSubagentManager(provider, "test-model", "/tmp/test", nil)
+	var gotAgent string
+	var gotDepth int
+	manager.SetResolver(func(agentID string, depth int) (*SubagentProfile, error) {
+		gotAgent, gotDepth = agentID, depth
+		return &SubagentProfile{
+			Provider:      provider,
+			Model:         "target-model",
+			SystemPrompt:  "You are the target agent.",
+			Tools:         NewToolRegistry(),
+			MaxIterations: 3,
+		}, nil
+	})
+
+	result, err := manager.run(context.Background(), "coder", "Do it", "default prompt", ExecutionContext{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Content != "Task completed: Do it" {
+		t.Errorf("Content = %q", result.Content)
+	}
+	if gotAgent != "coder" || gotDepth != 1 {
+		t.Errorf("resolver called with (%q, %d), want (coder, 1)", gotAgent, gotDepth)
+	}
+
+	manager.SetResolver(func(string, int) (*SubagentProfile, error) {
+		return nil, fmt.Errorf("agent not found")
+	})
+	if _, err := manager.Spawn(context.Background(), "x", "", "ghost", "cli", "direct", nil); err != nil {
+		t.Fatalf("Spawn should start the task and fail later, got %v", err)
+	}
+}
+
+func TestSubagentManager_MaxDepth(t *testing.T) {
+	manager := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	nested := context.WithValue(context.Background(), subagentDepthKey{}, 1)
+
+	if _, err := manager.Spawn(nested, "recurse", "", "", "cli", "direct", nil); err == nil {
+		t.Error("expected a subagent at the depth limit to be refused")
+	}
+	if result := NewSubagentTool(manager).Execute(nested, map[string]any{"task": "recurse"}); !result.IsError {
+		t.Errorf("expected the subagent tool to refuse too, got: %s", result.ForLLM)
+	}
+
+	manager.SetMaxDepth(2)
+	if result := NewSubagentTool(manager).Execute(nested, map[string]any{"task": "recurse"}); result.IsError {
+		t.Errorf("expected one more level to be allowed, got: %s", result.ForLLM)
+	}
+}
+
+func TestSpawnTool_CallbackComesFromExecutionContext(t *testing.T) {
+	manager := NewSubagentManager(&MockLLMProvider{}, "test-model", t.TempDir(), bus.NewMessageBus())
+	registry := NewToolRegistry()
+	registry.Register(NewSpawnTool(manager))
+
+	// Two turns share the tool; each must hear about its own task only.
+	results := make(chan string, 2)
+	for _, chatID := range []string{"chat-a", "chat-b"} {
+		callback := func(_ context.Context, result *ToolResult) {
+			results <- chatID + ": " + result.ForUser
+		}
+		result := registry.ExecuteWithContext(context.Background(), "spawn",
+			map[string]any{"task": "task for " + chatID},
+			ExecutionContext{Channel: "cli", ChatID: chatID}, callback)
+		if result.IsError {
+			t.Fatalf("spawn failed: %s", result.ForLLM)
+		}
+	}
+
+	for range 2 {
+		select {
+		case got := <-results:
+			chatID, rest, _ := strings.Cut(got, ": ")
+			if !strings.Contains(rest, "task for "+chatID) {
+				t.Errorf("callback of %s got another task's result: %q", chatID, rest)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("callback not called")
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// TasksTool lets an agent check on and cancel the background subagents it
+// spawned from the current chat.
+type TasksTool struct {
+	manager *SubagentManager
+}
+
+func NewTasksTool(manager *SubagentManager) *TasksTool {
+	return &TasksTool{manager: manager}
+}
+
+func (t *TasksTool) Name() string {
+	return "tasks"
+}
+
+func (t *TasksTool) Description() string {
+	return "List, inspect or cancel the background subagents spawned from this chat. " +
+		"'list' shows each task's status and elapsed time, 'get' shows a task's result or, " +
+		"while it runs, its partial output, and 'cancel' stops a running task."
+}
+
+func (t *TasksTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "get", "cancel"},
+				"description": "What to do; defaults to list",
+			},
+			"task_id": map[string]any{
+				"type":        "string",
+				"description": "The task to get or cancel, e.g. subagent-3",
+			},
+		},
+	}
+}
+
+func (t *TasksTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	taskID, _ := args["task_id"].(string)
+	channel, chatID := originOf(ctx)
+
+	switch action {
+	case "", "list":
+		return SilentResult(FormatSubagentTasks(t.manager.TasksFor(channel, chatID)))
+	case "get", "cancel":
+		if taskID == "" {
+			return ErrorResult(fmt.Sprintf("task_id is required for %s", action))
+		}
+		task, ok := t.manager.GetTask(taskID)
+		if !ok || task.OriginChannel != channel || task.OriginChatID != chatID {
+			return ErrorResult(fmt.Sprintf("task %s not found in this chat", taskID))
+		}
+		if action == "get" {
+			return SilentResult(FormatSubagentTask(task))
+		}
+		if !t.manager.Cancel(taskID) {
+			return ErrorResult(fmt.Sprintf("task %s is not running (status: %s)", taskID, task.Status))
+		}
+		return SilentResult(fmt.Sprintf("Cancelled task %s", taskID))
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
+	}
+}
+
+// TasksFor returns copies of the tasks spawned from the given chat, oldest
+// first.
+func (sm *SubagentManager) TasksFor(channel, chatID string) []*SubagentTask {
+	var tasks []*SubagentTask
+	for _, task := range sm.ListTasks() {
+		if task.OriginChannel == channel && task.OriginChatID == chatID {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// FormatSubagentTasks renders tasks one per line with their status and
+// elapsed time.
+func FormatSubagentTasks(tasks []*SubagentTask) string {
+	if len(tasks) == 0 {
+		return "No subagent tasks."
+	}
+	var sb strings.Builder
+	sb.WriteString("Subagent tasks:\n")
+	for _, task := range tasks {
+		name := task.Label
+		if name == "" {
+			name = utils.Truncate(strings.Join(strings.Fields(task.Task), " "), 60)
+		}
+		fmt.Fprintf(&sb, "- %s [%s, %s] %s\n", task.ID, task.Status, task.Elapsed().Round(time.Second), name)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// FormatSubagentTask renders one task in full: its result once finished, or
+// its partial output while running.
+func FormatSubagentTask(task *SubagentTask) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Task %s", task.ID)
+	if task.Label != "" {
+		fmt.Fprintf(&sb, " (%s)", task.Label)
+	}
+	fmt.Fprintf(&sb, "\nStatus: %s\nElapsed: %s\n", task.Status, task.Elapsed().Round(time.Second))
+	if task.AgentID != "" {
+		fmt.Fprintf(&sb, "Agent: %s\n", task.AgentID)
+	}
+	fmt.Fprintf(&sb, "\nTask:\n%s\n", task.Task)
+	if task.Result != "" {
+		fmt.Fprintf(&sb, "\nResult:\n%s\n", task.Result)
+	}
+	if task.Progress != "" && (task.Status == "running" || task.Status == "interrupted") {
+		fmt.Fprintf(&sb, "\nPartial output:\n%s\n", task.Progress)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// blockingProvider calls a tool once, then blocks until the request is
+// cancelled.
+type blockingProvider struct {
+	MockLLMProvider
+}
+
+func (p *blockingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	if len(messages) == 2 {
+		return &providers.LLMResponse{
+			Content:   "Looking around first.",
+			ToolCalls: []providers.ToolCall{{ID: "1", Name: "list_dir", Arguments: map[string]any{"path": "."}}},
+		}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func waitForTask(t *testing.T, sm *SubagentManager, id string, done func(*SubagentTask) bool) *SubagentTask {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if task, ok := sm.GetTask(id); ok && done(task) {
+			return task
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	task, _ := sm.GetTask(id)
+	t.Fatalf("task %s did not reach the expected state: %+v", id, task)
+	return nil
+}
+
+func TestTasksTool_ListGetCancel(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	manager := NewSubagentManager(&blockingProvider{}, "test-model", t.TempDir(), msgBus)
+	ctx := WithExecutionContext(context.Background(), ExecutionContext{Channel: "telegram", ChatID: "chat-1"})
+	if _, err := manager.Spawn(ctx, "research things", "research", "", "telegram", "chat-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitForTask(t, manager, "subagent-1", func(task *SubagentTask) bool { return task.Progress != "" })
+
+	tool := NewTasksTool(manager)
+	result := tool.Execute(ctx, map[string]any{})
+	if !strings.Contains(result.ForLLM, "subagent-1 [running") || !strings.Contains(result.ForLLM, "research") {
+		t.Errorf("expected the running task to be listed, got:\n%s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "get", "task_id": "subagent-1"})
+	if !strings.Contains(result.ForLLM, "Looking around first.") || !strings.Contains(result.ForLLM, "-> list_dir") {
+		t.Errorf("expected partial output, got:\n%s", result.ForLLM)
+	}
+
+	other := WithExecutionContext(context.Background(), ExecutionContext{Channel: "telegram", ChatID: "chat-2"})
+	if result = tool.Execute(other, map[string]any{}); result.ForLLM != "No subagent tasks." {
+		t.Errorf("expected tasks from other chats to be hidden, got:\n%s", result.ForLLM)
+	}
+	if result = tool.Execute(other, map[string]any{"action": "cancel", "task_id": "subagent-1"}); !result.IsError {
+		t.Errorf("expected cancelling another chat's task to fail, got:\n%s", result.ForLLM)
+	}
+
+	if result = tool.Execute(ctx, map[string]any{"action": "cancel", "task_id": "subagent-1"}); result.IsError {
+		t.Fatalf("cancel failed: %s", result.ForLLM)
+	}
+	task, _ := manager.GetTask("subagent-1")
+	if task.Status != "cancelled" {
+		t.Errorf("status = %q, want cancelled", task.Status)
+	}
+	if result = tool.Execute(ctx, map[string]any{"action": "cancel", "task_id": "subagent-1"}); !result.IsError {
+		t.Errorf("expected cancelling a finished task to fail, got:\n%s", result.ForLLM)
+	}
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if msg, ok := msgBus.ConsumeInbound(waitCtx); ok {
+		t.Errorf("expected no announcement for a cancelled task, got %+v", msg)
+	}
+}
+
+func TestSubagentManager_PersistsTasks(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "subagents", "main.json")
+	msgBus := bus.NewMessageBus()
+
+	manager := NewSubagentManager(&blockingProvider{}, "test-model", t.TempDir(), msgBus)
+	if err := manager.SetStorePath(store); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Spawn(context.Background(), "long research", "research", "", "telegram", "chat-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitForTask(t, manager, "subagent-1", func(task *SubagentTask) bool { return task.Progress != "" })
+
+	// Simulate a crash: a second process loads the store while the task runs.
+	restarted := NewSubagentManager(&MockLLMProvider{}, "test-model", t.TempDir(), msgBus)
+	if err := restarted.SetStorePath(store); err != nil {
+		t.Fatal(err)
+	}
+	manager.Shutdown()
+	// Let the old process's task wind down so it no longer writes the store.
+	waitForTask(t, manager, "subagent-1", func(task *SubagentTask) bool { return task.Status != "running" })
+
+	task, ok := restarted.GetTask("subagent-1")
+	if !ok || task.Status != "interrupted" {
+		t.Fatalf("expected the running task to be interrupted, got %+v", task)
+	}
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(waitCtx)
+	if !ok {
+		t.Fatal("expected the interrupted task to be reported")
+	}
+	if msg.ChatID != "telegram:chat-1" || !strings.Contains(msg.Content, "interrupted") ||
+		!strings.Contains(msg.Content, "Looking around first.") {
+		t.Errorf("unexpected announcement: %+v", msg)
+	}
+
+	spawned, err := restarted.Spawn(context.Background(), "next", "", "", "telegram", "chat-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForTask(t, restarted, "subagent-2", func(task *SubagentTask) bool { return task.Status == "completed" })
+	if !strings.Contains(spawned, "next") {
+		t.Errorf("unexpected spawn result: %s", spawned)
+	}
+
+	// A further restart neither reports the task again nor loses the result.
+	data, err := os.ReadFile(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Task completed: next") {
+		t.Errorf("expected the result to be stored, got:\n%s", data)
+	}
+	again := NewSubagentManager(&MockLLMProvider{}, "test-model", t.TempDir(), nil)
+	if err := again.SetStorePath(store); err != nil {
+		t.Fatal(err)
+	}
+	if tasks := again.ListTasks(); len(tasks) != 2 || tasks[1].Result != "Task completed: next" {
+		t.Errorf("expected both tasks to be restored, got %+v", tasks)
+	}
+}
+
+// ctxProvider fails once the request's context is cancelled and answers
+// otherwise.
+type ctxProvider struct {
+	MockLLMProvider
+}
+
+func (p *ctxProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func TestSubagentManager_TaskOutlivesSpawningTurn(t *testing.T) {
+	manager := NewSubagentManager(&ctxProvider{}, "test-model", t.TempDir(), nil)
+	defer manager.Shutdown()
+
+	// An HTTP request, say, that ends as soon as the spawn tool returns.
+	turn, endTurn := context.WithCancel(context.Background())
+	endTurn()
+	if _, err := manager.Spawn(turn, "research", "", "", "telegram", "chat-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	task := waitForTask(t, manager, "subagent-1", func(task *SubagentTask) bool { return task.Status != "running" })
+	if task.Status != "completed" || task.Result != "done" {
+		t.Errorf("expected the task to complete after its turn ended, got %+v", task)
+	}
+}
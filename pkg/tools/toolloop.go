@@ -16,13 +16,25 @@ import (
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// ChatFunc sends one LLM request, like LLMProvider.Chat.
+type ChatFunc func(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error)
+
 // ToolLoopConfig configures the tool execution loop.
 type ToolLoopConfig struct {
 	Provider      providers.LLMProvider
 	Model         string
+	Chat          ChatFunc // If set, used instead of Provider.Chat
 	Tools         *ToolRegistry
 	MaxIterations int
+	MaxParallel   int // Concurrency-safe tool calls run at once; 0 for the default
 	LLMOptions    map[string]any
+	Progress      func(note string) // If set, told what the model says and which tools it calls
 }
 
 // ToolLoopResult contains the result of running the tool loop.
@@ -37,7 +49,7 @@ func RunToolLoop(
 	ctx context.Context,
 	config ToolLoopConfig,
 	messages []providers.Message,
-	channel, chatID string,
+	execCtx ExecutionContext,
 ) (*ToolLoopResult, error) {
 	iteration := 0
 	var finalContent string
@@ -63,7 +75,11 @@ func RunToolLoop(
 			llmOpts = map[string]any{}
 		}
 		// 3. Call LLM
-		response, err := config.Provider.Chat(ctx, messages, providerToolDefs, config.Model, llmOpts)
+		chat := config.Chat
+		if chat == nil {
+			chat = config.Provider.Chat
+		}
+		response, err := chat(ctx, messages, providerToolDefs, config.Model, llmOpts)
 		if err != nil {
 			logger.ErrorCF("toolloop", "LLM call failed",
 				map[string]any{
@@ -121,8 +137,18 @@ func RunToolLoop(
 		}
 		messages = append(messages, assistantMsg)
 
-		// 7. Execute tool calls
-		for _, tc := range normalizedToolCalls {
+		if config.Progress != nil {
+			if response.Content != "" {
+				config.Progress(response.Content)
+			}
+			for _, tc := range normalizedToolCalls {
+				argsJSON, _ := json.Marshal(tc.Arguments)
+				config.Progress(fmt.Sprintf("-> %s(%s)", tc.Name, utils.Truncate(string(argsJSON), 120)))
+			}
+		}
+
+		// 7. Execute tool calls, concurrency-safe ones in parallel
+		execute := func(tc providers.ToolCall) *ToolResult {
 			argsJSON, _ := json.Marshal(tc.Arguments)
 			argsPreview := utils.Truncate(string(argsJSON), 200)
 			logger.InfoCF("toolloop", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
@@ -132,12 +158,22 @@ func RunToolLoop(
 				})
 
 			// Execute tool (no async callback for subagents - they run independently)
-			var toolResult *ToolResult
-			if config.Tools != nil {
-				toolResult = config.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, channel, chatID, nil)
-			} else {
-				toolResult = ErrorResult("No tools available")
+			if config.Tools == nil {
+				return ErrorResult("No tools available")
 			}
+			return config.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, execCtx, nil)
+		}
+		var results []*ToolResult
+		if config.Tools != nil {
+			results = config.Tools.ExecuteToolCalls(normalizedToolCalls, config.MaxParallel, execute)
+		} else {
+			for _, tc := range normalizedToolCalls {
+				results = append(results, execute(tc))
+			}
+		}
+
+		for i, tc := range normalizedToolCalls {
+			toolResult := results[i]
 
 			// Determine content for LLM
 			contentForLLM := toolResult.ForLLM
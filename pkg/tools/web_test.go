@@ -7,8 +7,19 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/egress"
 )
 
+// newLocalWebFetchTool returns a web_fetch tool allowed to reach the loopback
+// test servers, which the default egress policy blocks.
+func newLocalWebFetchTool(maxChars int) *WebFetchTool {
+	tool := NewWebFetchTool(maxChars)
+	tool.SetEgressPolicy(egress.NewPolicy(config.EgressConfig{AllowHosts: []string{"127.0.0.1"}}))
+	return tool
+}
+
 // TestWebTool_WebFetch_Success verifies successful URL fetching
 func TestWebTool_WebFetch_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -18,7 +29,7 @@ func TestWebTool_WebFetch_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLocalWebFetchTool(50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -54,7 +65,7 @@ func TestWebTool_WebFetch_JSON(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLocalWebFetchTool(50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -145,7 +156,7 @@ func TestWebTool_WebFetch_Truncation(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(1000) // Limit to 1000 chars
+	tool := newLocalWebFetchTool(1000) // Limit to 1000 chars
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -214,7 +225,7 @@ func TestWebTool_WebFetch_HTMLExtraction(t *testing.T) {
 	}))
 	defer server.Close()
 
-	tool := NewWebFetchTool(50000)
+	tool := newLocalWebFetchTool(50000)
 	ctx := context.Background()
 	args := map[string]any{
 		"url": server.URL,
@@ -405,3 +416,55 @@ func TestWebTool_TavilySearch_Success(t *testing.T) {
 		t.Errorf("Expected 'via Tavily' in output, got: %s", result.ForUser)
 	}
 }
+
+// TestWebTool_WebFetch_BlocksPrivateHosts verifies the default egress policy
+// refuses loopback and metadata addresses, also when reached by a redirect.
+func TestWebTool_WebFetch_BlocksPrivateHosts(t *testing.T) {
+	var hits int
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("internal"))
+	}))
+	defer target.Close()
+
+	tool := NewWebFetchTool(50000)
+	for _, u := range []string{target.URL, "http://169.254.169.254/latest/meta-data/", "http://[::1]/"} {
+		result := tool.Execute(context.Background(), map[string]any{"url": u})
+		if !result.IsError || !strings.Contains(result.ForLLM, "non-public") {
+			t.Errorf("Expected %s to be blocked, got: %s", u, result.ForLLM)
+		}
+	}
+
+	// A permitted host redirecting to a blocked one must not reach it.
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	tool = newLocalWebFetchTool(50000)
+	result := tool.Execute(context.Background(), map[string]any{"url": redirector.URL})
+	if !result.IsError {
+		t.Errorf("Expected redirect to the metadata address to be blocked, got: %s", result.ForLLM)
+	}
+	if hits != 0 {
+		t.Errorf("Expected blocked requests never to reach the target, got %d hits", hits)
+	}
+}
+
+// TestWebTool_WebFetch_MaxResponseBytes verifies oversized responses are refused.
+func TestWebTool_WebFetch_MaxResponseBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 2048)))
+	}))
+	defer server.Close()
+
+	tool := NewWebFetchTool(50000)
+	tool.SetEgressPolicy(egress.NewPolicy(config.EgressConfig{
+		AllowPrivateNetworks: true,
+		MaxResponseBytes:     1024,
+	}))
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	if !result.IsError || !strings.Contains(result.ForLLM, "too large") {
+		t.Errorf("Expected response size error, got: %s", result.ForLLM)
+	}
+}
@@ -33,6 +33,24 @@ func IsAudioFile(filename, contentType string) bool {
 	return false
 }
 
+// IsImageFile checks if a file is an image based on its filename extension and content type.
+// URL query strings and fragments are ignored when checking the extension.
+func IsImageFile(filename, contentType string) bool {
+	imageExtensions := []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+
+	name := strings.ToLower(filename)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	for _, ext := range imageExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+
+	return strings.HasPrefix(strings.ToLower(contentType), "image/")
+}
+
 // SanitizeFilename removes potentially dangerous characters from a filename
 // and returns a safe version for local filesystem storage.
 func SanitizeFilename(filename string) string {
@@ -65,7 +83,7 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 		opts.LoggerPrefix = "utils"
 	}
 
-	mediaDir := filepath.Join(os.TempDir(), "picoclaw_media")
+	mediaDir := MediaDir()
 	if err := os.MkdirAll(mediaDir, 0o700); err != nil {
 		logger.ErrorCF(opts.LoggerPrefix, "Failed to create media directory", map[string]any{
 			"error": err.Error(),
@@ -135,6 +153,28 @@ func DownloadFile(url, filename string, opts DownloadOptions) string {
 	return localPath
 }
 
+// MediaDir returns the temp directory that downloaded channel media is stored in.
+func MediaDir() string {
+	return filepath.Join(os.TempDir(), "picoclaw_media")
+}
+
+// RemoveMediaFiles deletes downloaded media once its consumer is done with it.
+// Paths outside MediaDir (for example remote URLs) are left untouched.
+func RemoveMediaFiles(paths []string) {
+	mediaDir := MediaDir() + string(filepath.Separator)
+	for _, path := range paths {
+		if !strings.HasPrefix(filepath.Clean(path), mediaDir) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.DebugCF("media", "Failed to cleanup media file", map[string]any{
+				"path":  path,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
 // DownloadFileSimple is a simplified version of DownloadFile without options
 func DownloadFileSimple(url, filename string) string {
 	return DownloadFile(url, filename, DownloadOptions{
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsImageFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		filename    string
+		contentType string
+		want        bool
+	}{
+		{"png extension", "shot.PNG", "", true},
+		{"url with query", "https://cdn.example.com/a/b.jpg?ex=123&is=456", "", true},
+		{"content type only", "file.bin", "image/webp", true},
+		{"audio", "voice.ogg", "audio/ogg", false},
+		{"document", "report.pdf", "application/pdf", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsImageFile(tt.filename, tt.contentType); got != tt.want {
+				t.Errorf("IsImageFile(%q, %q) = %v, want %v", tt.filename, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveMediaFiles_OnlyTouchesMediaDir(t *testing.T) {
+	if err := os.MkdirAll(MediaDir(), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	inside, err := os.CreateTemp(MediaDir(), "test-*.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inside.Close()
+	outside := filepath.Join(t.TempDir(), "keep.jpg")
+	if err := os.WriteFile(outside, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	RemoveMediaFiles([]string{inside.Name(), outside, "https://example.com/a.jpg"})
+
+	if _, err := os.Stat(inside.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", inside.Name())
+		os.Remove(inside.Name())
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("expected %s to be kept: %v", outside, err)
+	}
+}